@@ -0,0 +1,14 @@
+package main
+
+import "go.opentelemetry.io/otel/attribute"
+
+// attrPrefix namespaces the attributes this service defines itself, so
+// "demo." turns fib.n into demo.fib.n. It is set from ATTRIBUTE_PREFIX at
+// startup and empty by default. Semantic convention attributes are never
+// prefixed.
+var attrPrefix string
+
+// attrKey returns the key for one of this service's own attributes.
+func attrKey(name string) attribute.Key {
+	return attribute.Key(attrPrefix + name)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestAttributePrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "", want: []string{"fib.n", "fib.mode", "fib.cache_hit", "runtime.goroutines", "runtime.worker_id", "runtime.gomaxprocs", "runtime.num_cpu"}},
+		{prefix: "demo.", want: []string{"demo.fib.n", "demo.fib.mode", "demo.fib.cache_hit", "demo.runtime.goroutines", "demo.runtime.worker_id", "demo.runtime.gomaxprocs", "demo.runtime.num_cpu"}},
+	}
+	for _, tt := range tests {
+		t.Run("prefix "+tt.prefix, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &attrPrefix, tt.prefix)
+			h := newTestFibonacciHandler()
+			h.cache = newResultCache(1, nil)
+			m := newTestMiddleware(t)
+			m.runtimeStats, m.schedStats = true, true
+			m.Handle("/fibonacci", h).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fibonacci?n=5", nil))
+
+			keys := map[string]bool{}
+			for _, s := range rec.Ended() {
+				for _, kv := range s.Attributes() {
+					keys[string(kv.Key)] = true
+				}
+			}
+			for _, key := range tt.want {
+				if !keys[key] {
+					t.Errorf("no %s attribute in %v", key, keys)
+				}
+			}
+			if !keys[string(semconv.HTTPRouteKey)] {
+				t.Errorf("semantic convention %s was renamed: %v", semconv.HTTPRouteKey, keys)
+			}
+			for key := range keys {
+				if (strings.HasPrefix(key, "fib.") || strings.HasPrefix(key, "runtime.")) && tt.prefix != "" {
+					t.Errorf("%s set without the %q prefix", key, tt.prefix)
+				}
+			}
+		})
+	}
+}
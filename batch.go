@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// batchResult is the outcome for one n of a batch request.
+type batchResult struct {
+	N      uint64 `json:"n"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// fibonacciBatchHandler computes the fibonacci numbers for a JSON array of
+// n values under one parent span with a child span per value. A failing
+// value is reported in its own result and does not fail the batch.
+type fibonacciBatchHandler struct {
+	defaultMode fibMode
+	calls       prometheus.Observer
+	budget      time.Duration
+	overflow    *overflowWatch
+	// memory rejects items estimated to exceed its memory budget, nil
+	// disables it.
+	memory  *memoryGuard
+	maxSize int
+	// maxBytes caps the request body size.
+	maxBytes int64
+}
+
+func (s *fibonacciBatchHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		resp.Header().Set("Allow", http.MethodPost)
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ns, err := s.decode(http.MaxBytesReader(resp, req.Body, s.maxBytes))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, errBatchTooLarge):
+		resp.WriteHeader(http.StatusRequestEntityTooLarge)
+		resp.Write([]byte(fmt.Sprintf("batch exceeds the maximum of %d items", s.maxSize)))
+		return
+	case errors.As(err, &tooLarge):
+		resp.WriteHeader(http.StatusRequestEntityTooLarge)
+		resp.Write([]byte(fmt.Sprintf("body exceeds the maximum of %d bytes", tooLarge.Limit)))
+		return
+	case err != nil:
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte("body must be a JSON array of non-negative integers"))
+		return
+	}
+	mode := s.defaultMode
+	if m := req.URL.Query().Get("mode"); m != "" {
+		if mode, err = parseFibMode(m); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	ctx, counter := withCallCounter(req.Context())
+	ctx = withComputeBudget(ctx, s.budget)
+	ctx, span := tracer("fibonacci").Start(ctx, "fibonacci-batch", oteltrace.WithAttributes(
+		attrKey("fib.mode").String(string(mode)),
+		attrKey("fib.batch_size").Int(len(ns)),
+	))
+	results := make([]batchResult, len(ns))
+	failed := 0
+	for i, n := range ns {
+		itemCtx, itemSpan := tracer("fibonacci").Start(ctx, "fibonacci-batch-item",
+			oteltrace.WithAttributes(attrKey("fib.n").Int64(int64(n))))
+		s.overflow.Check(itemSpan, mode, n)
+		var ret string
+		err := s.memory.Check(itemSpan, mode, n)
+		if err == nil {
+			ret, err = computeFibonacci(itemCtx, mode, n)
+		}
+		results[i] = batchResult{N: n, Result: ret}
+		if err != nil {
+			recordComputeError(itemSpan, err)
+			results[i] = batchResult{N: n, Error: err.Error()}
+			failed++
+		}
+		itemSpan.End()
+	}
+	span.SetAttributes(attrKey("fib.batch_failed").Int(failed))
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d items failed", failed, len(ns)))
+	}
+	span.End()
+	s.calls.Observe(float64(counter.Calls()))
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	json.NewEncoder(resp).Encode(results)
+}
+
+// errBatchTooLarge is returned by decode for arrays of more than maxSize.
+var errBatchTooLarge = errors.New("batch too large")
+
+// decode reads the JSON array of n values from body one element at a
+// time, so an oversized batch is turned down as soon as it goes past
+// maxSize rather than after reading all of it.
+func (s *fibonacciBatchHandler) decode(body io.Reader) ([]uint64, error) {
+	dec := json.NewDecoder(body)
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('[') {
+		return nil, fmt.Errorf("expected a JSON array, got %v", tok)
+	}
+	var ns []uint64
+	for dec.More() {
+		if len(ns) == s.maxSize {
+			return nil, errBatchTooLarge
+		}
+		var n uint64
+		if err := dec.Decode(&n); err != nil {
+			return nil, err
+		}
+		ns = append(ns, n)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
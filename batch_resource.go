@@ -0,0 +1,44 @@
+package main
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// batchResource is a resource written once per export batch, for spans to
+// reference by ID instead of repeating it.
+type batchResource struct {
+	ID       string
+	Resource *resource.Resource
+}
+
+// batchResources returns the distinct resources of spans in order of first
+// appearance, and the ID of each span's resource. In practice every span of
+// a process shares one resource.
+func batchResources(spans []trace.ReadOnlySpan) (distinct []batchResource, ids []string) {
+	seen := map[string]bool{}
+	ids = make([]string, len(spans))
+	for i, s := range spans {
+		id := resourceID(s.Resource())
+		ids[i] = id
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, batchResource{ID: id, Resource: s.Resource()})
+		}
+	}
+	return distinct, ids
+}
+
+// resourceID identifies res by a hash of its schema URL and attributes, so
+// equal resources get the same ID across batches and restarts.
+func resourceID(res *resource.Resource) string {
+	h := fnv.New64a()
+	h.Write([]byte(res.SchemaURL()))
+	h.Write([]byte{0})
+	h.Write([]byte(res.Encoded(attribute.DefaultEncoder())))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
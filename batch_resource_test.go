@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// resourceSpans returns spans of one trace with span IDs from first, each
+// with the resource of service.
+func resourceSpans(first byte, services ...string) []trace.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, len(services))
+	for i, service := range services {
+		stubs[i] = tracetest.SpanStub{
+			Name:        "span",
+			SpanContext: testSpanContext(1, first+byte(i)),
+			Resource:    resource.NewSchemaless(attribute.String("service.name", service)),
+		}
+	}
+	return stubs.Snapshots()
+}
+
+// countResourceRefs checks that every span record references a resource
+// written earlier in the same batch, and returns the number of resource
+// records of each batch.
+func countResourceRefs(t *testing.T, batches [][]map[string]interface{}, spanRef func(map[string]interface{}) (ref string, isSpan bool), resourceID func(map[string]interface{}) (id string, isResource bool)) []int {
+	t.Helper()
+	var counts []int
+	for i, records := range batches {
+		ids := map[string]bool{}
+		for _, r := range records {
+			if id, ok := resourceID(r); ok {
+				ids[id] = true
+				continue
+			}
+			ref, ok := spanRef(r)
+			if !ok {
+				continue
+			}
+			if ref != "" && !ids[ref] {
+				t.Errorf("batch %d: span references resource %q not written before it", i, ref)
+			}
+		}
+		counts = append(counts, len(ids))
+	}
+	return counts
+}
+
+func TestNDJSONBatchResource(t *testing.T) {
+	tests := []struct {
+		name          string
+		batchResource bool
+		batches       [][]trace.ReadOnlySpan
+		// wantResources is the number of resource records of each batch.
+		wantResources []int
+	}{
+		{
+			name:          "off",
+			batches:       [][]trace.ReadOnlySpan{resourceSpans(1, "fib", "fib", "fib")},
+			wantResources: []int{0},
+		},
+		{
+			name:          "shared resource",
+			batchResource: true,
+			batches:       [][]trace.ReadOnlySpan{resourceSpans(1, "fib", "fib", "fib")},
+			wantResources: []int{1},
+		},
+		{
+			name:          "once per batch",
+			batchResource: true,
+			batches: [][]trace.ReadOnlySpan{
+				resourceSpans(1, "fib", "fib"),
+				resourceSpans(3, "fib", "fib", "fib"),
+			},
+			wantResources: []int{1, 1},
+		},
+		{
+			name:          "distinct resources",
+			batchResource: true,
+			batches:       [][]trace.ReadOnlySpan{resourceSpans(1, "fib", "other", "fib")},
+			wantResources: []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []ndjsonOption
+			if tt.batchResource {
+				opts = append(opts, withBatchResource())
+			}
+			var buf bytes.Buffer
+			e := newNDJSONExporter(&buf, opts...)
+			var batches [][]map[string]interface{}
+			for _, spans := range tt.batches {
+				buf.Reset()
+				if err := e.ExportSpans(context.Background(), spans); err != nil {
+					t.Fatal(err)
+				}
+				var records []map[string]interface{}
+				sc := bufio.NewScanner(&buf)
+				for sc.Scan() {
+					var r map[string]interface{}
+					if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+						t.Fatalf("%v: %s", err, sc.Bytes())
+					}
+					records = append(records, r)
+				}
+				if got, want := len(records), len(spans)+tt.wantResources[len(batches)]; got != want {
+					t.Errorf("batch %d: got %d records, want %d", len(batches), got, want)
+				}
+				batches = append(batches, records)
+			}
+
+			var refs int
+			got := countResourceRefs(t, batches,
+				func(r map[string]interface{}) (string, bool) {
+					ref, _ := r["resource_id"].(string)
+					if ref != "" {
+						refs++
+					}
+					return ref, true
+				},
+				func(r map[string]interface{}) (string, bool) {
+					if r["type"] != "resource" {
+						return "", false
+					}
+					id, _ := r["id"].(string)
+					return id, true
+				},
+			)
+			for i := range got {
+				if got[i] != tt.wantResources[i] {
+					t.Errorf("batch %d: %d resource records, want %d", i, got[i], tt.wantResources[i])
+				}
+			}
+			var wantRefs int
+			if tt.batchResource {
+				for _, b := range tt.batches {
+					wantRefs += len(b)
+				}
+			}
+			if refs != wantRefs {
+				t.Errorf("%d spans reference a resource, want %d", refs, wantRefs)
+			}
+		})
+	}
+}
+
+func TestChromeBatchResource(t *testing.T) {
+	tests := []struct {
+		name          string
+		batchResource bool
+		batches       [][]trace.ReadOnlySpan
+		wantResources []int
+	}{
+		{name: "off", batches: [][]trace.ReadOnlySpan{resourceSpans(1, "fib", "fib")}, wantResources: []int{0}},
+		{
+			name:          "once per batch",
+			batchResource: true,
+			batches: [][]trace.ReadOnlySpan{
+				resourceSpans(1, "fib", "fib"),
+				resourceSpans(3, "fib", "other", "fib"),
+			},
+			wantResources: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newChromeExporter(&buf)
+			e.batchResource = tt.batchResource
+			var batches [][]map[string]interface{}
+			for _, spans := range tt.batches {
+				buf.Reset()
+				if len(batches) > 0 {
+					// Only the first batch opens the array.
+					buf.WriteString("[\n")
+				}
+				if err := e.ExportSpans(context.Background(), spans); err != nil {
+					t.Fatal(err)
+				}
+				var records []map[string]interface{}
+				for _, ev := range parseChromeEvents(t, buf.String()) {
+					records = append(records, map[string]interface{}{"ph": ev.Phase, "args": ev.Args})
+				}
+				batches = append(batches, records)
+			}
+
+			got := countResourceRefs(t, batches,
+				func(r map[string]interface{}) (string, bool) {
+					if r["ph"] != "B" {
+						return "", false
+					}
+					ref, _ := r["args"].(map[string]interface{})["resource_id"].(string)
+					if (ref != "") != tt.batchResource {
+						t.Errorf("begin event resource_id = %q, want one %v", ref, tt.batchResource)
+					}
+					return ref, true
+				},
+				func(r map[string]interface{}) (string, bool) {
+					if r["ph"] != "M" {
+						return "", false
+					}
+					id, _ := r["args"].(map[string]interface{})["resource_id"].(string)
+					return id, true
+				},
+			)
+			for i := range got {
+				if got[i] != tt.wantResources[i] {
+					t.Errorf("batch %d: %d resource events, want %d", i, got[i], tt.wantResources[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResourceID(t *testing.T) {
+	fib := resource.NewSchemaless(attribute.String("service.name", "fib"))
+	tests := []struct {
+		name     string
+		a, b     *resource.Resource
+		wantSame bool
+	}{
+		{name: "equal resources", a: fib, b: resource.NewSchemaless(attribute.String("service.name", "fib")), wantSame: true},
+		{name: "different attributes", a: fib, b: resource.NewSchemaless(attribute.String("service.name", "other"))},
+		{name: "different schema", a: fib, b: resource.NewWithAttributes("https://opentelemetry.io/schemas/1.17.0", attribute.String("service.name", "fib"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := resourceID(tt.a) == resourceID(tt.b); same != tt.wantSame {
+				t.Errorf("resourceID %s vs %s: same = %v, want %v", resourceID(tt.a), resourceID(tt.b), same, tt.wantSame)
+			}
+		})
+	}
+}
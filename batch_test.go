@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func newTestBatchHandler() *fibonacciBatchHandler {
+	return &fibonacciBatchHandler{
+		defaultMode: fibModeIter,
+		calls:       prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_batch_calls"}),
+		overflow:    newOverflowWatch(0),
+		maxSize:     3,
+		maxBytes:    1 << 10,
+	}
+}
+
+func TestFibonacciBatchHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		wantStatus  int
+		wantResults []batchResult
+		wantFailed  bool
+	}{
+		{
+			name:        "all valid",
+			body:        `[1, 10]`,
+			wantStatus:  http.StatusOK,
+			wantResults: []batchResult{{N: 1, Result: "1"}, {N: 10, Result: "55"}},
+		},
+		{
+			name:       "mixed valid and overflow",
+			body:       `[10, 94, 2]`,
+			wantStatus: http.StatusOK,
+			wantResults: []batchResult{
+				{N: 10, Result: "55"},
+				{N: 94, Error: errFibonacciOverflow.Error()},
+				{N: 2, Result: "1"},
+			},
+			wantFailed: true,
+		},
+		{name: "too many items", body: `[1, 2, 3, 4]`, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "body too large", body: "[" + strings.Repeat("1,", 1<<10) + "1]", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "negative n", body: `[-1]`, wantStatus: http.StatusBadRequest},
+		{name: "not an array", body: `{"n": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "method not allowed", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			resp := httptest.NewRecorder()
+			newTestBatchHandler().ServeHTTP(resp, httptest.NewRequest(method, "/fibonacci/batch", strings.NewReader(tt.body)))
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if tt.wantResults == nil {
+				return
+			}
+			var got []batchResult
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.wantResults) {
+				t.Errorf("results = %+v, want %+v", got, tt.wantResults)
+			}
+
+			var items, parents int
+			for _, s := range rec.Ended() {
+				switch s.Name() {
+				case "fibonacci-batch-item":
+					items++
+				case "fibonacci-batch":
+					parents++
+					if failed := s.Status().Code == codes.Error; failed != tt.wantFailed {
+						t.Errorf("batch span status = %v, want error %v", s.Status(), tt.wantFailed)
+					}
+				}
+			}
+			if parents != 1 || items != len(tt.wantResults) {
+				t.Errorf("got %d batch and %d item spans, want 1 and %d", parents, items, len(tt.wantResults))
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// errBreakerOpen is returned for exports refused by an open breaker.
+var errBreakerOpen = errors.New("exporter circuit breaker open")
+
+// Circuit breaker states, as reported by the otel_exporter_breaker_state
+// gauge.
+const (
+	breakerClosed   = 0
+	breakerOpen     = 1
+	breakerHalfOpen = 2
+)
+
+var (
+	breakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "otel_exporter_breaker_state",
+		Help: "Circuit breaker state per exporter: 0 closed, 1 open, 2 half-open.",
+	}, []string{"exporter"})
+	breakerDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "otel_exporter_breaker_dropped_spans_total",
+		Help: "Spans dropped without an export attempt while the breaker was open.",
+	}, []string{"exporter"})
+)
+
+// breakerExporter stops calling next for cooldown after threshold
+// consecutive failed exports, dropping spans instead of letting a dead
+// collector stall the batch processor. After the cooldown a single export
+// is let through as a probe: success closes the breaker, failure reopens it.
+type breakerExporter struct {
+	next      trace.SpanExporter
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+var _ trace.SpanExporter = (*breakerExporter)(nil)
+
+func newBreakerExporter(next trace.SpanExporter, name string, threshold int, cooldown time.Duration) *breakerExporter {
+	breakerState.WithLabelValues(name).Set(breakerClosed)
+	return &breakerExporter{next: next, name: name, threshold: threshold, cooldown: cooldown}
+}
+
+func (e *breakerExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if !e.allow() {
+		breakerDropped.WithLabelValues(e.name).Add(float64(len(spans)))
+		return errBreakerOpen
+	}
+	err := e.next.ExportSpans(ctx, spans)
+	e.record(err)
+	return err
+}
+
+func (e *breakerExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
+
+// allow reports whether an export may go through, moving an open breaker
+// whose cooldown has passed to half-open.
+func (e *breakerExporter) allow() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch e.state {
+	case breakerOpen:
+		if time.Since(e.openedAt) < e.cooldown {
+			return false
+		}
+		e.setState(breakerHalfOpen)
+		return true
+	case breakerHalfOpen:
+		// A probe is already in flight.
+		return false
+	default:
+		return true
+	}
+}
+
+func (e *breakerExporter) record(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		e.failures = 0
+		if e.state != breakerClosed {
+			log.Printf("exporter %s recovered, closing circuit breaker", e.name)
+			e.setState(breakerClosed)
+		}
+		return
+	}
+	e.failures++
+	if e.state == breakerHalfOpen || e.failures >= e.threshold {
+		log.Printf("exporter %s failed %d time(s), opening circuit breaker for %s: %v", e.name, e.failures, e.cooldown, err)
+		e.openedAt = time.Now()
+		e.setState(breakerOpen)
+	}
+}
+
+func (e *breakerExporter) setState(state int) {
+	e.state = state
+	breakerState.WithLabelValues(e.name).Set(float64(state))
+}
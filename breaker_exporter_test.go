@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBreakerExporter(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	unavailable := errors.New("collector unavailable")
+	type step struct {
+		// wait lets the cooldown pass before exporting.
+		wait      bool
+		fail      bool
+		wantErr   error
+		wantState int
+	}
+	tests := []struct {
+		name        string
+		steps       []step
+		wantCalls   int
+		wantDropped float64
+	}{
+		{
+			name: "opens after threshold",
+			steps: []step{
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerOpen},
+				{wantErr: errBreakerOpen, wantState: breakerOpen},
+			},
+			wantCalls:   3,
+			wantDropped: 1,
+		},
+		{
+			name: "success resets failure count",
+			steps: []step{
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+			},
+			wantCalls: 4,
+		},
+		{
+			name: "half-open probe closes on success",
+			steps: []step{
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerOpen},
+				{wait: true, wantState: breakerClosed},
+				{wantState: breakerClosed},
+			},
+			wantCalls: 5,
+		},
+		{
+			name: "half-open probe reopens on failure",
+			steps: []step{
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerClosed},
+				{fail: true, wantErr: unavailable, wantState: breakerOpen},
+				{wait: true, fail: true, wantErr: unavailable, wantState: breakerOpen},
+				{wantErr: errBreakerOpen, wantState: breakerOpen},
+			},
+			wantCalls:   4,
+			wantDropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubExporter{}
+			e := newBreakerExporter(stub, t.Name(), 3, cooldown)
+			// The counter is global and outlives the test under -count.
+			droppedBefore := testutil.ToFloat64(breakerDropped.WithLabelValues(t.Name()))
+			for i, s := range tt.steps {
+				if s.wait {
+					time.Sleep(cooldown)
+				}
+				stub.setErr(nil)
+				if s.fail {
+					stub.setErr(unavailable)
+				}
+				if err := e.ExportSpans(context.Background(), testSpans(1)); err != s.wantErr {
+					t.Errorf("step %d: err = %v, want %v", i, err, s.wantErr)
+				}
+				if got := testutil.ToFloat64(breakerState.WithLabelValues(t.Name())); got != float64(s.wantState) {
+					t.Errorf("step %d: state = %v, want %d", i, got, s.wantState)
+				}
+			}
+			if stub.calls != tt.wantCalls {
+				t.Errorf("next called %d times, want %d", stub.calls, tt.wantCalls)
+			}
+			if got := testutil.ToFloat64(breakerDropped.WithLabelValues(t.Name())) - droppedBefore; got != tt.wantDropped {
+				t.Errorf("dropped = %v, want %v", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestBreakerExporterHalfOpenSingleProbe(t *testing.T) {
+	stub := &stubExporter{err: errors.New("collector unavailable")}
+	e := newBreakerExporter(stub, t.Name(), 1, time.Millisecond)
+	e.ExportSpans(context.Background(), testSpans(1))
+	time.Sleep(time.Millisecond)
+
+	if !e.allow() {
+		t.Fatal("probe refused after cooldown")
+	}
+	if got := testutil.ToFloat64(breakerState.WithLabelValues(t.Name())); got != breakerHalfOpen {
+		t.Errorf("state = %v, want half-open", got)
+	}
+	if e.allow() {
+		t.Error("second export allowed while the probe is in flight")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// errComputeBudgetExceeded aborts a computation that ran past its budget.
+var errComputeBudgetExceeded = errors.New("fibonacci compute budget exceeded")
+
+type computeDeadlineKey struct{}
+
+// withComputeBudget limits computations run with the returned context to
+// budget of wall-clock time. A budget <= 0 means unlimited. The budget is
+// independent of the request context's own deadline.
+func withComputeBudget(ctx context.Context, budget time.Duration) context.Context {
+	if budget <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, computeDeadlineKey{}, time.Now().Add(budget))
+}
+
+// checkComputeBudget returns errComputeBudgetExceeded once the budget set by
+// withComputeBudget has run out, or the context's error once its own
+// deadline (e.g. one propagated from upstream) has passed.
+func checkComputeBudget(ctx context.Context) error {
+	deadline, ok := ctx.Value(computeDeadlineKey{}).(time.Time)
+	if ok && time.Now().After(deadline) {
+		return errComputeBudgetExceeded
+	}
+	return ctx.Err()
+}
+
+// recordComputeError marks span as failed with err. A passed deadline is
+// reported with the deadline_exceeded status description.
+func recordComputeError(span oteltrace.Span, err error) {
+	span.RecordError(err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		span.SetStatus(codes.Error, "deadline_exceeded")
+		return
+	}
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// requestDeadline returns the deadline an upstream caller put on req through
+// the Grpc-Timeout header. Deadlines already on req's context need no
+// parsing; they are honoured through checkComputeBudget.
+func requestDeadline(req *http.Request) (time.Time, bool) {
+	d, ok := parseGRPCTimeout(req.Header.Get("Grpc-Timeout"))
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Now().Add(d), true
+}
+
+// parseGRPCTimeout parses a gRPC timeout value: up to 8 digits followed by
+// one of the units H, M, S, m, u or n.
+func parseGRPCTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// computeErrorStatus maps a computation error to the HTTP status reported
+// to the client.
+func computeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errFibonacciOverflow):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errRecursionTooDeep):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestComputeBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    fibMode
+		n       uint64
+		budget  time.Duration
+		wantErr error
+	}{
+		{name: "recursive aborted", mode: fibModeRecursive, n: 40, budget: time.Millisecond, wantErr: errComputeBudgetExceeded},
+		{name: "iter aborted", mode: fibModeIter, n: 90, budget: time.Nanosecond, wantErr: errComputeBudgetExceeded},
+		{name: "memo aborted", mode: fibModeMemo, n: 90, budget: time.Nanosecond, wantErr: errComputeBudgetExceeded},
+		{name: "big aborted", mode: fibModeBig, n: 100000, budget: time.Nanosecond, wantErr: errComputeBudgetExceeded},
+		{name: "matrix aborted", mode: fibModeMatrix, n: 90, budget: time.Nanosecond, wantErr: errComputeBudgetExceeded},
+		{name: "within budget", mode: fibModeRecursive, n: 10, budget: time.Minute},
+		{name: "unlimited", mode: fibModeIter, n: 90, budget: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			ctx := withComputeBudget(context.Background(), tt.budget)
+			time.Sleep(time.Microsecond)
+
+			_, err := computeFibonacci(ctx, tt.mode, tt.n)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("computeFibonacci = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			var failed int
+			for _, s := range rec.Ended() {
+				if s.Status().Code == codes.Error {
+					failed++
+				}
+			}
+			if failed == 0 {
+				t.Error("no span marked as failed")
+			}
+		})
+	}
+}
+
+func TestComputeBudgetHonoursContextDeadline(t *testing.T) {
+	useTestTracerProvider(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := computeFibonacci(ctx, fibModeRecursive, 40); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("computeFibonacci = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   time.Duration
+		wantOK bool
+	}{
+		{in: "1H", want: time.Hour, wantOK: true},
+		{in: "2M", want: 2 * time.Minute, wantOK: true},
+		{in: "3S", want: 3 * time.Second, wantOK: true},
+		{in: "100m", want: 100 * time.Millisecond, wantOK: true},
+		{in: "5u", want: 5 * time.Microsecond, wantOK: true},
+		{in: "99999999n", want: 99999999, wantOK: true},
+		{in: "", wantOK: false},
+		{in: "S", wantOK: false},
+		{in: "100", wantOK: false},
+		{in: "1x", wantOK: false},
+		{in: "-1S", wantOK: false},
+		{in: "123456789S", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := parseGRPCTimeout(tt.in)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseGRPCTimeout(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFibonacciHandlerUpstreamDeadline(t *testing.T) {
+	tests := []struct {
+		name       string
+		n          string
+		timeout    string
+		wantStatus int
+	}{
+		{name: "tight grpc-timeout", n: "40", timeout: "1m", wantStatus: http.StatusGatewayTimeout},
+		{name: "generous grpc-timeout", n: "15", timeout: "1M", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			req := httptest.NewRequest(http.MethodGet, "/fibonacci?mode=recursive&n="+tt.n, nil)
+			req.Header.Set("Grpc-Timeout", tt.timeout)
+			ctx, root := tracer("test").Start(req.Context(), "/fibonacci")
+			resp := httptest.NewRecorder()
+			newTestFibonacciHandler().ServeHTTP(resp, req.WithContext(ctx))
+			root.End()
+
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.Code, tt.wantStatus)
+			}
+			var timedOut int
+			for _, s := range rec.Ended() {
+				if s.Status().Code == codes.Error && s.Status().Description == "deadline_exceeded" {
+					timedOut++
+				}
+			}
+			if wantTimedOut := tt.wantStatus == http.StatusGatewayTimeout; (timedOut > 0) != wantTimedOut {
+				t.Errorf("%d spans record deadline_exceeded, want some: %v", timedOut, wantTimedOut)
+			}
+		})
+	}
+}
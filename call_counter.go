@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// callCounter counts fibonacci invocations made on behalf of one request,
+// and the spans they created.
+type callCounter struct {
+	calls atomic.Uint64
+	spans atomic.Uint64
+}
+
+type callCounterKey struct{}
+
+// withCallCounter returns a context carrying a fresh callCounter.
+func withCallCounter(ctx context.Context) (context.Context, *callCounter) {
+	c := &callCounter{}
+	return context.WithValue(ctx, callCounterKey{}, c), c
+}
+
+// countCall records one fibonacci invocation on the counter in ctx, if any.
+func countCall(ctx context.Context) {
+	countCalls(ctx, 1)
+}
+
+// countCalls records n fibonacci invocations on the counter in ctx, if any.
+func countCalls(ctx context.Context, n uint64) {
+	if c, ok := ctx.Value(callCounterKey{}).(*callCounter); ok {
+		c.calls.Add(n)
+	}
+}
+
+// countSpan records one fibonacci span on the counter in ctx, if any.
+func countSpan(ctx context.Context) {
+	if c, ok := ctx.Value(callCounterKey{}).(*callCounter); ok {
+		c.spans.Add(1)
+	}
+}
+
+// Spans returns the number of spans counted so far.
+func (c *callCounter) Spans() uint64 {
+	return c.spans.Load()
+}
+
+// Calls returns the number of invocations counted so far.
+func (c *callCounter) Calls() uint64 {
+	return c.calls.Load()
+}
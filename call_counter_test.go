@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCallCounterRecursive(t *testing.T) {
+	// calls(n) = calls(n-1) + calls(n-2) + 1 with calls(0) = calls(1) = 1.
+	tests := []struct {
+		n    uint64
+		want uint64
+	}{
+		{0, 1}, {1, 1}, {2, 3}, {3, 5}, {4, 9}, {5, 15}, {6, 25}, {10, 177},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatUint(tt.n, 10), func(t *testing.T) {
+			ctx, counter := withCallCounter(context.Background())
+			if _, err := computeFibonacci(ctx, fibModeRecursive, tt.n); err != nil {
+				t.Fatal(err)
+			}
+			if got := counter.Calls(); got != tt.want {
+				t.Errorf("calls(%d) = %d, want %d", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFibonacciHandlerObservesCalls(t *testing.T) {
+	tests := []struct {
+		target string
+		want   float64
+	}{
+		{target: "/fibonacci?n=5&mode=recursive", want: 15},
+		{target: "/fibonacci?n=10&mode=recursive", want: 177},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			calls := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "fibonacci_calls"})
+			h.calls = calls
+			serveFibonacci(t, h, tt.target)
+
+			var m dto.Metric
+			if err := calls.Write(&m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetHistogram().GetSampleCount(); got != 1 {
+				t.Errorf("observed %d requests, want 1", got)
+			}
+			if got := m.GetHistogram().GetSampleSum(); got != tt.want {
+				t.Errorf("observed %v calls, want %v", got, tt.want)
+			}
+		})
+	}
+}
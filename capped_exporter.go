@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// cappedExporter forwards spans to next until limit spans have been exported
+// over the life of the process; everything after that is dropped and
+// counted. It protects a collector from a single runaway request.
+type cappedExporter struct {
+	next  trace.SpanExporter
+	limit uint64
+
+	// onDrop, if set, is told about every span dropped by the cap, so
+	// counters further down the chain don't miss them.
+	onDrop func(n uint64)
+
+	mu       sync.Mutex
+	exported uint64
+	dropped  uint64
+}
+
+var _ trace.SpanExporter = (*cappedExporter)(nil)
+
+func newCappedExporter(next trace.SpanExporter, limit uint64) *cappedExporter {
+	return &cappedExporter{next: next, limit: limit}
+}
+
+func (e *cappedExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	allowed := uint64(len(spans))
+	if remaining := e.limit - e.exported; allowed > remaining {
+		if e.dropped == 0 {
+			log.Printf("span export cap of %d reached, dropping further spans", e.limit)
+		}
+		e.dropped += allowed - remaining
+		allowed = remaining
+	}
+	e.exported += allowed
+	e.mu.Unlock()
+	if dropped := uint64(len(spans)) - allowed; dropped > 0 && e.onDrop != nil {
+		e.onDrop(dropped)
+	}
+
+	if allowed == 0 {
+		return nil
+	}
+	return e.next.ExportSpans(ctx, spans[:allowed])
+}
+
+func (e *cappedExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
+
+// Collectors returns Prometheus collectors reporting the cap and the
+// exported/dropped totals.
+func (e *cappedExporter) Collectors() []prometheus.Collector {
+	read := func(v *uint64) func() float64 {
+		return func() float64 {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			return float64(*v)
+		}
+	}
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "otel_span_export_cap",
+			Help: "Maximum number of spans this process will export.",
+		}, func() float64 { return float64(e.limit) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "otel_span_export_capped_exported_total",
+			Help: "Spans exported while counting towards the export cap.",
+		}, read(&e.exported)),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "otel_span_export_capped_dropped_total",
+			Help: "Spans dropped because the export cap was reached.",
+		}, read(&e.dropped)),
+	}
+}
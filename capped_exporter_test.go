@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testSpans returns n ended spans of one trace.
+func testSpans(n int) []trace.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, n)
+	for i := range stubs {
+		stubs[i] = tracetest.SpanStub{Name: "span", SpanContext: testSpanContext(1, byte(i+1))}
+	}
+	return stubs.Snapshots()
+}
+
+func TestCappedExporter(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        uint64
+		batches      []int
+		wantExported int
+		wantDropped  uint64
+	}{
+		{name: "under the cap", limit: 10, batches: []int{3, 3}, wantExported: 6},
+		{name: "batch crossing the cap", limit: 5, batches: []int{3, 3}, wantExported: 5, wantDropped: 1},
+		{name: "batches after the cap", limit: 2, batches: []int{2, 4, 1}, wantExported: 2, wantDropped: 5},
+		{name: "zero cap", limit: 0, batches: []int{3}, wantDropped: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := tracetest.NewInMemoryExporter()
+			e := newCappedExporter(next, tt.limit)
+			var reported uint64
+			e.onDrop = func(n uint64) { reported += n }
+
+			for _, n := range tt.batches {
+				if err := e.ExportSpans(context.Background(), testSpans(n)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := len(next.GetSpans()); got != tt.wantExported {
+				t.Errorf("exported %d spans, want %d", got, tt.wantExported)
+			}
+			if reported != tt.wantDropped {
+				t.Errorf("onDrop got %d spans, want %d", reported, tt.wantDropped)
+			}
+			want := []float64{float64(tt.limit), float64(tt.wantExported), float64(tt.wantDropped)}
+			for i, c := range e.Collectors() {
+				if got := testutil.ToFloat64(c); got != want[i] {
+					t.Errorf("collector %d = %v, want %v", i, got, want[i])
+				}
+			}
+		})
+	}
+}
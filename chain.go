@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// chainHandler forwards the request to a downstream fibonacci endpoint with
+// an instrumented client, so the trace contains a CLIENT span whose context
+// is propagated to the downstream SERVER span.
+type chainHandler struct {
+	client     *http.Client
+	downstream string
+}
+
+func (s *chainHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	span := oteltrace.SpanFromContext(req.Context())
+
+	u, err := url.Parse(s.downstream)
+	if err != nil {
+		resp.WriteHeader(http.StatusInternalServerError)
+		resp.Write([]byte("invalid downstream url"))
+		return
+	}
+	q := u.Query()
+	for _, key := range []string{"n", "mode"} {
+		if v := req.URL.Query().Get(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	downReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		resp.WriteHeader(http.StatusInternalServerError)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+	downResp, err := s.client.Do(downReq)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		resp.WriteHeader(http.StatusBadGateway)
+		resp.Write([]byte("downstream request failed"))
+		return
+	}
+	defer downResp.Body.Close()
+
+	if downResp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, fmt.Sprintf("downstream returned %d", downResp.StatusCode))
+	}
+	resp.WriteHeader(downResp.StatusCode)
+	io.Copy(resp, downResp.Body)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// useTestPropagator installs p as the global propagator until the test
+// finishes.
+func useTestPropagator(t *testing.T, p propagation.TextMapPropagator) {
+	t.Helper()
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(p)
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+}
+
+func TestChainHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		down       bool
+		wantStatus int
+		wantError  bool
+	}{
+		{name: "downstream ok", status: http.StatusOK, wantStatus: http.StatusOK},
+		{name: "downstream error", status: http.StatusServiceUnavailable, wantStatus: http.StatusServiceUnavailable, wantError: true},
+		{name: "downstream unreachable", down: true, wantStatus: http.StatusBadGateway, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			useTestPropagator(t, propagation.TraceContext{})
+
+			var gotHeader, gotQuery string
+			downstream := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				gotHeader = req.Header.Get("traceparent")
+				gotQuery = req.URL.RawQuery
+				resp.WriteHeader(tt.status)
+				io.WriteString(resp, "55")
+			}))
+			defer downstream.Close()
+			if tt.down {
+				downstream.Close()
+			}
+
+			h := &chainHandler{
+				client:     &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+				downstream: downstream.URL + "/fibonacci",
+			}
+			ctx, root := tracer("test").Start(context.Background(), "/chain")
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/chain?n=10&mode=iter", nil).WithContext(ctx))
+			root.End()
+
+			if resp.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.Code, tt.wantStatus)
+			}
+			var client, server oteltrace.SpanContext
+			var clientFailed, serverFailed bool
+			for _, s := range rec.Ended() {
+				switch {
+				case s.SpanKind() == oteltrace.SpanKindClient:
+					client = s.SpanContext()
+					clientFailed = s.Status().Code == codes.Error
+				case s.Name() == "/chain":
+					server = s.SpanContext()
+					serverFailed = s.Status().Code == codes.Error
+				}
+			}
+			if !client.IsValid() || client.TraceID() != server.TraceID() {
+				t.Fatalf("client span %v is not in the trace %v", client, server)
+			}
+			if serverFailed != tt.wantError {
+				t.Errorf("span failed = %v, want %v", serverFailed, tt.wantError)
+			}
+			if tt.down {
+				if !clientFailed {
+					t.Error("client span not marked as failed")
+				}
+				return
+			}
+			if !strings.Contains(gotHeader, client.TraceID().String()+"-"+client.SpanID().String()) {
+				t.Errorf("traceparent = %q, want the client span %s", gotHeader, client.SpanID())
+			}
+			if gotQuery != "mode=iter&n=10" {
+				t.Errorf("downstream query = %q, want n and mode forwarded", gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// childAttrsProcessor copies the listed attributes of a span onto its
+// in-process parent when the span ends, so they are visible on the parent
+// in backends that don't drill into children. To keep the parent's
+// attribute cardinality bounded a key stops being copied once it has been
+// seen with maxValues distinct values.
+type childAttrsProcessor struct {
+	keys      map[attribute.Key]bool
+	maxValues int
+
+	mu     sync.Mutex
+	open   map[oteltrace.SpanID]trace.ReadWriteSpan
+	values map[attribute.Key]map[string]struct{}
+}
+
+var _ trace.SpanProcessor = (*childAttrsProcessor)(nil)
+
+func newChildAttrsProcessor(keys []string, maxValues int) *childAttrsProcessor {
+	p := &childAttrsProcessor{
+		keys:      make(map[attribute.Key]bool, len(keys)),
+		maxValues: maxValues,
+		open:      make(map[oteltrace.SpanID]trace.ReadWriteSpan),
+		values:    make(map[attribute.Key]map[string]struct{}, len(keys)),
+	}
+	for _, k := range keys {
+		p.keys[attribute.Key(k)] = true
+		p.values[attribute.Key(k)] = make(map[string]struct{})
+	}
+	return p
+}
+
+func (p *childAttrsProcessor) OnStart(_ context.Context, s trace.ReadWriteSpan) {
+	p.mu.Lock()
+	p.open[s.SpanContext().SpanID()] = s
+	p.mu.Unlock()
+}
+
+func (p *childAttrsProcessor) OnEnd(s trace.ReadOnlySpan) {
+	p.mu.Lock()
+	delete(p.open, s.SpanContext().SpanID())
+	parent, ok := p.open[s.Parent().SpanID()]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	var copied []attribute.KeyValue
+	for _, kv := range s.Attributes() {
+		if p.keys[kv.Key] && p.allow(kv) {
+			copied = append(copied, kv)
+		}
+	}
+	p.mu.Unlock()
+	if len(copied) > 0 {
+		parent.SetAttributes(copied...)
+	}
+}
+
+// allow records kv's value and reports whether it may be copied: values
+// already seen always are, new ones only while the key is under the
+// distinct value limit. p.mu must be held.
+func (p *childAttrsProcessor) allow(kv attribute.KeyValue) bool {
+	seen := p.values[kv.Key]
+	v := kv.Value.Emit()
+	if _, ok := seen[v]; ok {
+		return true
+	}
+	if len(seen) >= p.maxValues {
+		return false
+	}
+	seen[v] = struct{}{}
+	if len(seen) == p.maxValues {
+		log.Printf("attribute %s reached %d distinct values, new values are no longer copied to parent spans", kv.Key, p.maxValues)
+	}
+	return true
+}
+
+func (p *childAttrsProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *childAttrsProcessor) ForceFlush(context.Context) error { return nil }
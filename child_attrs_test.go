@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestChildAttrsProcessor(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		maxValues int
+		requests  int
+		// want tells, per request, whether its parent carries childId.
+		want []bool
+	}{
+		{name: "copied", keys: []string{"childId"}, maxValues: 100, requests: 2, want: []bool{true, true}},
+		{name: "not configured", keys: []string{"other"}, maxValues: 100, requests: 1, want: []bool{false}},
+		{name: "cardinality capped", keys: []string{"childId"}, maxValues: 2, requests: 3, want: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			rec := useTestTracerProvider(t, trace.WithSpanProcessor(newChildAttrsProcessor(tt.keys, tt.maxValues)))
+			for i := 0; i < tt.requests; i++ {
+				(&nestedSpanHandler{}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nested", nil))
+			}
+
+			var parents int
+			var childID string
+			for _, s := range rec.Ended() {
+				switch s.Name() {
+				case "child":
+					childID = spanAttr(s, attrKey("childId"))
+				case "parent":
+					got := spanAttr(s, attrKey("childId"))
+					if want := tt.want[parents]; (got != "") != want {
+						t.Errorf("request %d: parent childId = %q, want copied %v", parents, got, want)
+					} else if want && got != childID {
+						t.Errorf("request %d: parent childId = %s, child has %s", parents, got, childID)
+					}
+					parents++
+				}
+			}
+			if parents != tt.requests {
+				t.Errorf("got %d parent spans, want %d", parents, tt.requests)
+			}
+		})
+	}
+}
+
+func TestChildAttrsProcessorKnownValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxValues int
+		// values are the childId values of successive children.
+		values []string
+		// want tells, per child, whether its parent carries its childId.
+		want []bool
+	}{
+		{name: "under cap", maxValues: 3, values: []string{"a", "b", "a"}, want: []bool{true, true, true}},
+		{name: "known value after cap", maxValues: 2, values: []string{"a", "b", "c", "a", "b"}, want: []bool{true, true, false, true, true}},
+		{name: "new value after cap", maxValues: 2, values: []string{"a", "b", "a", "d"}, want: []bool{true, true, true, false}},
+		{name: "exactly at cap", maxValues: 1, values: []string{"a", "a", "a"}, want: []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			tp := trace.NewTracerProvider(trace.WithSpanProcessor(newChildAttrsProcessor([]string{"childId"}, tt.maxValues)))
+			defer tp.Shutdown(context.Background())
+			tr := tp.Tracer("test")
+			for i, v := range tt.values {
+				ctx, parent := tr.Start(context.Background(), "parent")
+				_, child := tr.Start(ctx, "child")
+				child.SetAttributes(attribute.String("childId", v))
+				child.End()
+
+				got := spanAttr(parent.(trace.ReadOnlySpan), "childId")
+				parent.End()
+				if copied := got == v; copied != tt.want[i] {
+					t.Errorf("child %d (%s): parent childId = %q, want copied %v", i, v, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// chromeEvent is one entry of the Chrome Trace Event Format, as read by
+// chrome://tracing and Perfetto.
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"`
+	TS    float64                `json:"ts"`
+	PID   int                    `json:"pid"`
+	TID   uint32                 `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// chromeExporter writes every span as a begin ("B") and end ("E") event
+// pair in the JSON array flavour of the Chrome Trace Event Format. Each
+// trace gets its own thread id so its spans nest as a flame graph. The
+// array is never closed, which the format explicitly allows, so the file
+// stays loadable however the process stops.
+type chromeExporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pid     int
+	started bool
+	stopped bool
+	// batchResource writes each batch's resource as a metadata ("M")
+	// event that span events reference by resource_id.
+	batchResource bool
+}
+
+var _ trace.SpanExporter = (*chromeExporter)(nil)
+
+func newChromeExporter(w io.Writer) *chromeExporter {
+	return &chromeExporter{w: w, pid: os.Getpid()}
+}
+
+func (e *chromeExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil
+	}
+	if !e.started {
+		if _, err := io.WriteString(e.w, "[\n"); err != nil {
+			return err
+		}
+		e.started = true
+	}
+	var resourceIDs []string
+	if e.batchResource {
+		var resources []batchResource
+		resources, resourceIDs = batchResources(spans)
+		for _, r := range resources {
+			args := map[string]interface{}{"resource_id": r.ID}
+			for _, kv := range r.Resource.Attributes() {
+				args[string(kv.Key)] = jsonValue(kv.Value)
+			}
+			if err := e.write(chromeEvent{Name: "resource", Phase: "M", PID: e.pid, Args: args}); err != nil {
+				return err
+			}
+		}
+	}
+	for i, s := range spans {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		begin, end := e.events(s)
+		if resourceIDs != nil {
+			begin.Args["resource_id"] = resourceIDs[i]
+		}
+		for _, ev := range []chromeEvent{begin, end} {
+			if err := e.write(ev); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write appends ev to the array.
+func (e *chromeExporter) write(ev chromeEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(b, ",\n"...))
+	return err
+}
+
+func (e *chromeExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopped = true
+	return ctx.Err()
+}
+
+// events returns the begin and end events of s. Attributes and ids go into
+// the begin event's args.
+func (e *chromeExporter) events(s trace.ReadOnlySpan) (begin, end chromeEvent) {
+	traceID := s.SpanContext().TraceID()
+	h := fnv.New32a()
+	h.Write(traceID[:])
+	args := map[string]interface{}{
+		"trace_id": traceID.String(),
+		"span_id":  s.SpanContext().SpanID().String(),
+	}
+	for _, kv := range s.Attributes() {
+		args[string(kv.Key)] = jsonValue(kv.Value)
+	}
+	begin = chromeEvent{
+		Name:  s.Name(),
+		Cat:   s.InstrumentationScope().Name,
+		Phase: "B",
+		TS:    chromeTimestamp(s.StartTime()),
+		PID:   e.pid,
+		TID:   h.Sum32(),
+		Args:  args,
+	}
+	end = begin
+	end.Phase, end.TS, end.Args = "E", chromeTimestamp(s.EndTime()), nil
+	return begin, end
+}
+
+// chromeTimestamp converts t to the format's microseconds.
+func chromeTimestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e3
+}
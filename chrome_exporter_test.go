@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// parseChromeEvents closes the open array the exporter writes and decodes
+// its events.
+func parseChromeEvents(t *testing.T, out string) []chromeEvent {
+	t.Helper()
+	if !strings.HasPrefix(out, "[\n") {
+		t.Fatalf("output does not open an array: %q", out)
+	}
+	var events []chromeEvent
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(out, ",\n")+"]"), &events); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	return events
+}
+
+// chromeThreadSpan pairs an end event, which has no args, with its begin
+// event.
+type chromeThreadSpan struct {
+	tid  uint32
+	name string
+}
+
+func TestChromeExporter(t *testing.T) {
+	start := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	stub := func(name string, traceID, spanID byte, from, to time.Duration) tracetest.SpanStub {
+		return tracetest.SpanStub{
+			Name:        name,
+			SpanContext: testSpanContext(traceID, spanID),
+			StartTime:   start.Add(from),
+			EndTime:     start.Add(to),
+			Attributes:  []attribute.KeyValue{attribute.String("span", name)},
+			Resource:    resource.NewSchemaless(attribute.String("service.name", "fibonacci")),
+		}
+	}
+	tests := []struct {
+		name          string
+		spans         tracetest.SpanStubs
+		batchResource bool
+		wantMeta      int
+	}{
+		{name: "single span", spans: tracetest.SpanStubs{stub("root", 1, 1, 0, time.Millisecond)}},
+		{
+			name: "nested recursion",
+			spans: tracetest.SpanStubs{
+				stub("fib(1)", 1, 3, 20*time.Microsecond, 30*time.Microsecond),
+				stub("fib(2)", 1, 2, 10*time.Microsecond, 40*time.Microsecond),
+				stub("root", 1, 1, 0, time.Millisecond),
+			},
+		},
+		{
+			name: "two traces",
+			spans: tracetest.SpanStubs{
+				stub("a", 1, 1, 0, time.Millisecond),
+				stub("b", 2, 1, 0, 2*time.Millisecond),
+			},
+		},
+		{
+			name:          "batch resource",
+			spans:         tracetest.SpanStubs{stub("a", 1, 1, 0, time.Millisecond), stub("b", 1, 2, 0, time.Millisecond)},
+			batchResource: true,
+			wantMeta:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newChromeExporter(&buf)
+			e.batchResource = tt.batchResource
+			if err := e.ExportSpans(context.Background(), tt.spans.Snapshots()); err != nil {
+				t.Fatal(err)
+			}
+
+			begins := map[string]chromeEvent{}
+			ends := map[chromeThreadSpan]chromeEvent{}
+			var meta int
+			for _, ev := range parseChromeEvents(t, buf.String()) {
+				switch ev.Phase {
+				case "B":
+					id, _ := ev.Args["span_id"].(string)
+					begins[ev.Args["trace_id"].(string)+"/"+id] = ev
+				case "E":
+					ends[chromeThreadSpan{ev.TID, ev.Name}] = ev
+				case "M":
+					meta++
+				default:
+					t.Errorf("unexpected phase %q", ev.Phase)
+				}
+			}
+			if len(begins) != len(tt.spans) || len(ends) != len(tt.spans) {
+				t.Fatalf("got %d begin and %d end events, want %d of each", len(begins), len(ends), len(tt.spans))
+			}
+			if meta != tt.wantMeta {
+				t.Errorf("got %d metadata events, want %d", meta, tt.wantMeta)
+			}
+			for _, s := range tt.spans {
+				key := s.SpanContext.TraceID().String() + "/" + s.SpanContext.SpanID().String()
+				begin, ok := begins[key]
+				if !ok {
+					t.Errorf("no begin event for %s", s.Name)
+					continue
+				}
+				end, ok := ends[chromeThreadSpan{begin.TID, s.Name}]
+				if !ok {
+					t.Errorf("no end event for %s", s.Name)
+					continue
+				}
+				if begin.TS != chromeTimestamp(s.StartTime) || end.TS != chromeTimestamp(s.EndTime) {
+					t.Errorf("%s: ts = [%v, %v], want [%v, %v]", s.Name, begin.TS, end.TS, chromeTimestamp(s.StartTime), chromeTimestamp(s.EndTime))
+				}
+				if begin.PID != end.PID {
+					t.Errorf("%s: pid %d != %d", s.Name, begin.PID, end.PID)
+				}
+				if begin.Args["span"] != s.Name {
+					t.Errorf("%s: args = %v, want span attribute", s.Name, begin.Args)
+				}
+				if _, ok := begin.Args["resource_id"]; ok != tt.batchResource {
+					t.Errorf("%s: resource_id present %v, want %v", s.Name, ok, tt.batchResource)
+				}
+			}
+		})
+	}
+}
+
+func TestChromeExporterSameTraceSameThread(t *testing.T) {
+	tests := []struct {
+		name     string
+		traceIDs [2]byte
+		wantSame bool
+	}{
+		{name: "same trace", traceIDs: [2]byte{1, 1}, wantSame: true},
+		{name: "different traces", traceIDs: [2]byte{1, 2}, wantSame: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newChromeExporter(nil)
+			spans := tracetest.SpanStubs{
+				{Name: "a", SpanContext: testSpanContext(tt.traceIDs[0], 1)},
+				{Name: "b", SpanContext: testSpanContext(tt.traceIDs[1], 2)},
+			}.Snapshots()
+			a, _ := e.events(spans[0])
+			b, _ := e.events(spans[1])
+			if (a.TID == b.TID) != tt.wantSame {
+				t.Errorf("tids %d and %d, want same %v", a.TID, b.TID, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestChromeExporterShutdown(t *testing.T) {
+	var buf bytes.Buffer
+	e := newChromeExporter(&buf)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "late", SpanContext: testSpanContext(1, 1)}}.Snapshots()
+	if err := e.ExportSpans(context.Background(), spans); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q after shutdown", buf.String())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Configuration errors returned by the setup functions. Match them with
+// errors.Is; the returned errors carry the offending value and, where there
+// is one, the underlying cause.
+var (
+	ErrInvalidExporterType = errors.New("invalid exporter type")
+	ErrInvalidTraceFormat  = errors.New("invalid trace format")
+	ErrUnreachableEndpoint = errors.New("unreachable endpoint")
+	ErrInvalidPropagator   = errors.New("invalid propagator")
+	ErrInvalidSpanTag      = errors.New("invalid span tag")
+	ErrInvalidSampleRatio  = errors.New("invalid sample ratio")
+	ErrInvalidTraceState   = errors.New("invalid tracestate entry")
+)
+
+// configError is a configuration failure of a given kind (one of the
+// sentinels above) with a descriptive message and optional cause.
+type configError struct {
+	kind  error
+	msg   string
+	cause error
+}
+
+func newConfigError(kind, cause error, format string, args ...interface{}) error {
+	return &configError{kind: kind, msg: fmt.Sprintf(format, args...), cause: cause}
+}
+
+func (e *configError) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+	return e.msg + ": " + e.cause.Error()
+}
+
+func (e *configError) Is(target error) bool { return target == e.kind }
+
+func (e *configError) Unwrap() error { return e.cause }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestConfigErrors(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	exporter := func(env map[string]string) func(t *testing.T) error {
+		return func(t *testing.T) error {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			_, err := newConfiguredExporter(context.Background(), io.Discard)
+			return err
+		}
+	}
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) error
+		want  error
+	}{
+		{name: "exporter type", setup: exporter(map[string]string{"EXPORTER_TYPE": "kafka"}), want: ErrInvalidExporterType},
+		{name: "exporter list entry", setup: exporter(map[string]string{"EXPORTERS": "file,kafka"}), want: ErrInvalidExporterType},
+		{name: "otlp protocol", setup: exporter(map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"}), want: ErrInvalidExporterType},
+		{
+			name:  "otlp protocol with fallback",
+			setup: exporter(map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json", "EXPORTER_FALLBACK": "stdout"}),
+			want:  ErrInvalidExporterType,
+		},
+		{name: "trace format", setup: exporter(map[string]string{"TRACE_FORMAT": "xml"}), want: ErrInvalidTraceFormat},
+		{name: "exporter list format", setup: exporter(map[string]string{"EXPORTERS": "file:xml"}), want: ErrInvalidTraceFormat},
+		{
+			name:  "unreachable grpc endpoint",
+			setup: exporter(map[string]string{"EXPORTER_TYPE": "otlpgrpc", "OTLP_PRECHECK": "true", "OTLP_ENDPOINT": closedAddr}),
+			want:  ErrUnreachableEndpoint,
+		},
+		{
+			name:  "unreachable http endpoint",
+			setup: exporter(map[string]string{"EXPORTER_TYPE": "otlphttp", "OTLP_PRECHECK": "true", "OTLP_ENDPOINT": closedAddr}),
+			want:  ErrUnreachableEndpoint,
+		},
+		{
+			name:  "missing unix socket",
+			setup: exporter(map[string]string{"EXPORTER_TYPE": "otlpgrpc", "OTLP_ENDPOINT": "unix:///nonexistent/otlp.sock"}),
+			want:  ErrUnreachableEndpoint,
+		},
+		{
+			name: "propagator",
+			setup: func(*testing.T) error {
+				_, err := newPropagator([]string{"xray"})
+				return err
+			},
+			want: ErrInvalidPropagator,
+		},
+		{
+			name: "span tag",
+			setup: func(*testing.T) error {
+				_, err := parseSpanTags("team")
+				return err
+			},
+			want: ErrInvalidSpanTag,
+		},
+		{
+			name: "sample ratio",
+			setup: func(*testing.T) error {
+				_, err := newRatioSampler(2)
+				return err
+			},
+			want: ErrInvalidSampleRatio,
+		},
+		{
+			name: "tenant sample ratio",
+			setup: func(*testing.T) error {
+				_, err := newTenantSampler(trace.AlwaysSample(), []string{"acme=lots"})
+				return err
+			},
+			want: ErrInvalidSampleRatio,
+		},
+		{
+			name: "tracestate entry",
+			setup: func(*testing.T) error {
+				_, err := newTraceStateSampler(trace.AlwaysSample(), "fibdemo")
+				return err
+			},
+			want: ErrInvalidTraceState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.setup(t)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			for _, other := range []error{ErrInvalidExporterType, ErrInvalidTraceFormat, ErrUnreachableEndpoint, ErrInvalidPropagator, ErrInvalidSpanTag, ErrInvalidSampleRatio, ErrInvalidTraceState} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("err = %v also matches %v", err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigErrorCause(t *testing.T) {
+	cause := errors.New("connection refused")
+	tests := []struct {
+		name    string
+		cause   error
+		wantMsg string
+	}{
+		{name: "without cause", wantMsg: "otlp endpoint x"},
+		{name: "with cause", cause: cause, wantMsg: "otlp endpoint x: connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newConfigError(ErrUnreachableEndpoint, tt.cause, "otlp endpoint %s", "x")
+			if err.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantMsg)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("%v does not wrap its cause", err)
+			}
+		})
+	}
+}
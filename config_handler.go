@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// runtimeConfig holds the settings that can be tuned while running. Fields
+// left out of a PUT body keep their current value.
+type runtimeConfig struct {
+	SampleRatio  *float64        `json:"sample_ratio,omitempty"`
+	TracedRoutes map[string]bool `json:"traced_routes,omitempty"`
+}
+
+// configHandler serves the effective runtimeConfig on GET and replaces it on
+// PUT. A PUT body is validated as a whole, unknown fields included, and is
+// either applied completely or rejected without changing anything.
+type configHandler struct {
+	mu      sync.Mutex
+	sampler *ratioSampler
+	routes  *routeToggle
+	// changed, if set, is called after a PUT was applied.
+	changed func()
+}
+
+func (s *configHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg runtimeConfig
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte("invalid config: " + err.Error()))
+			return
+		}
+		if err := s.apply(cfg); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte("invalid config: " + err.Error()))
+			return
+		}
+		if s.changed != nil {
+			s.changed()
+		}
+	default:
+		resp.Header().Set("Allow", "GET, PUT")
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(s.effective())
+}
+
+// apply validates every field of cfg before changing any setting.
+func (s *configHandler) apply(cfg runtimeConfig) error {
+	if r := cfg.SampleRatio; r != nil && !(*r >= 0 && *r <= 1) {
+		return fmt.Errorf("sample_ratio %g out of range [0, 1]", *r)
+	}
+	for route := range cfg.TracedRoutes {
+		if !s.routes.IsKnown(route) {
+			return fmt.Errorf("traced_routes: unknown route %q", route)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cfg.SampleRatio != nil {
+		if err := s.sampler.SetRatio(*cfg.SampleRatio); err != nil {
+			return err
+		}
+	}
+	for route, on := range cfg.TracedRoutes {
+		s.routes.Set(route, on)
+	}
+	return nil
+}
+
+func (s *configHandler) effective() runtimeConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ratio := s.sampler.Ratio()
+	routes := make(map[string]bool)
+	for _, route := range s.routes.Known() {
+		routes[route] = s.routes.Enabled(route)
+	}
+	return runtimeConfig{SampleRatio: &ratio, TracedRoutes: routes}
+}
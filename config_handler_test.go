@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		// wantRatio and wantRoutes are the settings afterwards, starting
+		// from ratio 0.5 with /fibonacci and /nested traced.
+		wantRatio  float64
+		wantRoutes map[string]bool
+	}{
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantRatio:  0.5,
+			wantRoutes: map[string]bool{"/fibonacci": true, "/nested": true},
+		},
+		{
+			name:       "valid payload",
+			method:     http.MethodPut,
+			body:       `{"sample_ratio": 0.1, "traced_routes": {"/nested": false}}`,
+			wantStatus: http.StatusOK,
+			wantRatio:  0.1,
+			wantRoutes: map[string]bool{"/fibonacci": true, "/nested": false},
+		},
+		{
+			name:       "partial payload keeps other settings",
+			method:     http.MethodPut,
+			body:       `{"traced_routes": {"/fibonacci": false}}`,
+			wantStatus: http.StatusOK,
+			wantRatio:  0.5,
+			wantRoutes: map[string]bool{"/fibonacci": false, "/nested": true},
+		},
+		{
+			name:       "invalid ratio",
+			method:     http.MethodPut,
+			body:       `{"sample_ratio": 2}`,
+			wantStatus: http.StatusBadRequest,
+			wantRatio:  0.5,
+			wantRoutes: map[string]bool{"/fibonacci": true, "/nested": true},
+		},
+		{
+			name:       "unknown field",
+			method:     http.MethodPut,
+			body:       `{"sample_ratio": 0.1, "log_level": "debug"}`,
+			wantStatus: http.StatusBadRequest,
+			wantRatio:  0.5,
+			wantRoutes: map[string]bool{"/fibonacci": true, "/nested": true},
+		},
+		{
+			name:       "one invalid field rejects the whole payload",
+			method:     http.MethodPut,
+			body:       `{"sample_ratio": 0.1, "traced_routes": {"/nested": false, "/unknown": true}}`,
+			wantStatus: http.StatusBadRequest,
+			wantRatio:  0.5,
+			wantRoutes: map[string]bool{"/fibonacci": true, "/nested": true},
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodPost,
+			body:       `{}`,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantRatio:  0.5,
+			wantRoutes: map[string]bool{"/fibonacci": true, "/nested": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := newRatioSampler(0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			routes := newRouteToggle(nil)
+			routes.Register("/fibonacci")
+			routes.Register("/nested")
+			var changed int
+			h := &configHandler{sampler: sampler, routes: routes, changed: func() { changed++ }}
+
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, httptest.NewRequest(tt.method, "/debug/config", strings.NewReader(tt.body)))
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if resp.Code == http.StatusOK {
+				var got runtimeConfig
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.SampleRatio == nil || *got.SampleRatio != tt.wantRatio || !reflect.DeepEqual(got.TracedRoutes, tt.wantRoutes) {
+					t.Errorf("response = %s, want ratio %g and routes %v", resp.Body, tt.wantRatio, tt.wantRoutes)
+				}
+			}
+
+			if got := sampler.Ratio(); got != tt.wantRatio {
+				t.Errorf("ratio = %g, want %g", got, tt.wantRatio)
+			}
+			for route, want := range tt.wantRoutes {
+				if got := routes.Enabled(route); got != want {
+					t.Errorf("%s traced = %v, want %v", route, got, want)
+				}
+			}
+			if wantChanged := tt.method == http.MethodPut && tt.wantStatus == http.StatusOK; (changed > 0) != wantChanged {
+				t.Errorf("changed called %d times, want called %v", changed, wantChanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowMethods = "GET, POST, OPTIONS"
+	corsAllowHeaders = "Content-Type, traceparent, tracestate, baggage"
+	corsMaxAge       = "600"
+)
+
+// corsHandler sets CORS headers for allowed origins. Preflight requests are
+// answered here and never reach the wrapped (traced) handlers.
+type corsHandler struct {
+	next     http.Handler
+	allowAll bool
+	origins  map[string]struct{}
+}
+
+// newCORSHandler wraps next with CORS support for the given origins. "*"
+// allows any origin. With no origins, next is returned unchanged.
+func newCORSHandler(next http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	h := &corsHandler{next: next, origins: make(map[string]struct{}, len(origins))}
+	for _, o := range origins {
+		if o == "*" {
+			h.allowAll = true
+		}
+		h.origins[o] = struct{}{}
+	}
+	return h
+}
+
+func (h *corsHandler) allowed(origin string) bool {
+	if h.allowAll {
+		return true
+	}
+	_, ok := h.origins[origin]
+	return ok
+}
+
+func (h *corsHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		h.next.ServeHTTP(resp, req)
+		return
+	}
+	resp.Header().Add("Vary", "Origin")
+
+	preflight := req.Method == http.MethodOptions &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+	if !h.allowed(origin) {
+		if preflight {
+			resp.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.next.ServeHTTP(resp, req)
+		return
+	}
+
+	resp.Header().Set("Access-Control-Allow-Origin", origin)
+	if !preflight {
+		h.next.ServeHTTP(resp, req)
+		return
+	}
+
+	resp.Header().Add("Vary", "Access-Control-Request-Method")
+	resp.Header().Add("Vary", "Access-Control-Request-Headers")
+	resp.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+	if reqHeaders := req.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" && h.allowAll {
+		resp.Header().Set("Access-Control-Allow-Headers", strings.TrimSpace(reqHeaders))
+	} else {
+		resp.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+	}
+	resp.Header().Set("Access-Control-Max-Age", corsMaxAge)
+	resp.WriteHeader(http.StatusNoContent)
+}
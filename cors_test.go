@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		method  string
+		header  map[string]string
+
+		wantStatus      int
+		wantNext        bool
+		wantAllowOrigin string
+		wantAllowHdrs   string
+	}{
+		{
+			name:    "preflight from allowed origin",
+			origins: []string{"https://demo.example"},
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://demo.example",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "traceparent",
+			},
+			wantStatus:      http.StatusNoContent,
+			wantAllowOrigin: "https://demo.example",
+			wantAllowHdrs:   corsAllowHeaders,
+		},
+		{
+			name:    "preflight from other origin",
+			origins: []string{"https://demo.example"},
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                        "https://evil.example",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "preflight with wildcard echoes requested headers",
+			origins: []string{"*"},
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://any.example",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": " X-Custom ",
+			},
+			wantStatus:      http.StatusNoContent,
+			wantAllowOrigin: "https://any.example",
+			wantAllowHdrs:   "X-Custom",
+		},
+		{
+			name:            "simple request from allowed origin",
+			origins:         []string{"https://demo.example"},
+			method:          http.MethodGet,
+			header:          map[string]string{"Origin": "https://demo.example"},
+			wantStatus:      http.StatusOK,
+			wantNext:        true,
+			wantAllowOrigin: "https://demo.example",
+		},
+		{
+			name:       "simple request from other origin",
+			origins:    []string{"https://demo.example"},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://evil.example"},
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+		{
+			name:       "same-origin request",
+			origins:    []string{"https://demo.example"},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+		{
+			name:    "disabled",
+			origins: nil,
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                        "https://demo.example",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			next := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				called = true
+			})
+			req := httptest.NewRequest(tt.method, "/fibonacci?n=3", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			newCORSHandler(next, tt.origins).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantNext {
+				t.Errorf("next called = %v, want %v", called, tt.wantNext)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != tt.wantAllowHdrs {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, tt.wantAllowHdrs)
+			}
+		})
+	}
+}
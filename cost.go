@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// costMeter meters the work each request costs, one unit per fibonacci
+// call, per tenant. Only allowlisted tenants get their own label value to
+// keep cardinality bounded; others are metered as "other", requests
+// without a tenant as "none".
+type costMeter struct {
+	total   *prometheus.CounterVec
+	tenants map[string]bool
+}
+
+func newCostMeter(tenants []string) *costMeter {
+	m := &costMeter{
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fibonacci_compute_cost_total",
+			Help: "Compute cost of fibonacci requests in fibonacci calls, by tenant.",
+		}, []string{"tenant"}),
+		tenants: make(map[string]bool, len(tenants)),
+	}
+	for _, t := range tenants {
+		m.tenants[t] = true
+	}
+	return m
+}
+
+// Record sets fib.cost on span and adds calls to the tenant of ctx. Cache
+// hits and coalesced requests did no work of their own and cost nothing.
+func (m *costMeter) Record(ctx context.Context, span oteltrace.Span, calls uint64) {
+	span.SetAttributes(attrKey("fib.cost").Int64(int64(calls)))
+	tenant := tenantFromContext(ctx)
+	switch {
+	case tenant == "":
+		tenant = "none"
+	case !m.tenants[tenant]:
+		tenant = "other"
+	}
+	m.total.WithLabelValues(tenant).Add(float64(calls))
+}
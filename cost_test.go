@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCostMeter(t *testing.T) {
+	type request struct {
+		tenant string
+		target string
+		// cost is the number of calls the request makes.
+		cost float64
+	}
+	tests := []struct {
+		name     string
+		requests []request
+		want     map[string]float64
+	}{
+		{
+			name:     "proportional to calls",
+			requests: []request{{"acme", "/fibonacci?n=5&mode=recursive", 15}, {"acme", "/fibonacci?n=10&mode=recursive", 177}},
+			want:     map[string]float64{"acme": 192},
+		},
+		{
+			name: "per tenant",
+			requests: []request{
+				{"acme", "/fibonacci?n=10&mode=recursive", 177},
+				{"globex", "/fibonacci?n=6&mode=recursive", 25},
+				{"globex", "/fibonacci?n=10", 1},
+			},
+			want: map[string]float64{"acme": 177, "globex": 26},
+		},
+		{
+			name:     "unlisted tenant",
+			requests: []request{{"initech", "/fibonacci?n=4&mode=recursive", 9}, {"hooli", "/fibonacci?n=2&mode=recursive", 3}},
+			want:     map[string]float64{"other": 12, "initech": 0},
+		},
+		{
+			name:     "no tenant",
+			requests: []request{{"", "/fibonacci?n=3&mode=recursive", 5}},
+			want:     map[string]float64{"none": 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			h.cost = newCostMeter([]string{"acme", "globex"})
+			handler := newTestMiddleware(t).Handle("/fibonacci", h)
+			for i, r := range tt.requests {
+				req := httptest.NewRequest(http.MethodGet, r.target, nil)
+				if r.tenant != "" {
+					req.Header.Set("X-Tenant-ID", r.tenant)
+				}
+				resp := httptest.NewRecorder()
+				handler.ServeHTTP(resp, req)
+				if resp.Code != http.StatusOK {
+					t.Fatalf("%s: status = %d: %s", r.target, resp.Code, resp.Body)
+				}
+				ended := rec.Ended()
+				root := ended[len(ended)-1]
+				if got, want := spanAttr(root, attrKey("fib.cost")), strconv.FormatFloat(r.cost, 'f', -1, 64); got != want {
+					t.Errorf("request %d: fib.cost = %q, want %q", i, got, want)
+				}
+			}
+			for tenant, want := range tt.want {
+				if got := testutil.ToFloat64(h.cost.total.WithLabelValues(tenant)); got != want {
+					t.Errorf("fibonacci_compute_cost_total{tenant=%q} = %v, want %v", tenant, got, want)
+				}
+			}
+		})
+	}
+}
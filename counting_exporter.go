@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// countingExporter keeps cheap running totals of what passes through to
+// next: spans exported, spans lost in failed exports and failed export calls.
+type countingExporter struct {
+	next trace.SpanExporter
+
+	exported atomic.Uint64
+	dropped  atomic.Uint64
+	errors   atomic.Uint64
+}
+
+var _ trace.SpanExporter = (*countingExporter)(nil)
+
+func newCountingExporter(next trace.SpanExporter) *countingExporter {
+	return &countingExporter{next: next}
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if err := e.next.ExportSpans(ctx, spans); err != nil {
+		e.errors.Add(1)
+		e.dropped.Add(uint64(len(spans)))
+		return err
+	}
+	e.exported.Add(uint64(len(spans)))
+	return nil
+}
+
+// AddDropped counts n spans dropped before they reached e, e.g. by
+// cappedExporter.
+func (e *countingExporter) AddDropped(n uint64) {
+	e.dropped.Add(n)
+}
+
+func (e *countingExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
+
+// spanCounts is a snapshot of a countingExporter's totals.
+type spanCounts struct {
+	Exported     uint64 `json:"exported"`
+	Dropped      uint64 `json:"dropped"`
+	ExportErrors uint64 `json:"export_errors"`
+}
+
+// Counts returns the current totals, zeroing them when reset is set.
+func (e *countingExporter) Counts(reset bool) spanCounts {
+	if reset {
+		return spanCounts{
+			Exported:     e.exported.Swap(0),
+			Dropped:      e.dropped.Swap(0),
+			ExportErrors: e.errors.Swap(0),
+		}
+	}
+	return spanCounts{
+		Exported:     e.exported.Load(),
+		Dropped:      e.dropped.Load(),
+		ExportErrors: e.errors.Load(),
+	}
+}
+
+// spanCountHandler renders a countingExporter's totals as JSON. The reset
+// query param zeroes them after reading.
+type spanCountHandler struct {
+	counter *countingExporter
+}
+
+func (s *spanCountHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	reset, _ := strconv.ParseBool(req.URL.Query().Get("reset"))
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(s.counter.Counts(reset))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSpanCountHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		// batches are exported in order; a negative size fails the export.
+		batches []int
+		// limit caps the spans exported, with the cap's drops counted.
+		limit uint64
+		want  spanCounts
+	}{
+		{
+			name:    "exported",
+			batches: []int{2, 3},
+			limit:   100,
+			want:    spanCounts{Exported: 5},
+		},
+		{
+			name:    "failed export",
+			batches: []int{2, -3},
+			limit:   100,
+			want:    spanCounts{Exported: 2, Dropped: 3, ExportErrors: 1},
+		},
+		{
+			name:    "capped",
+			batches: []int{2, 3},
+			limit:   4,
+			want:    spanCounts{Exported: 4, Dropped: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &stubExporter{}
+			counter := newCountingExporter(next)
+			capped := newCappedExporter(counter, tt.limit)
+			capped.onDrop = counter.AddDropped
+			for _, n := range tt.batches {
+				next.setErr(nil)
+				if n < 0 {
+					n = -n
+					next.setErr(errors.New("export failed"))
+				}
+				capped.ExportSpans(context.Background(), testSpans(n))
+			}
+
+			h := &spanCountHandler{counter: counter}
+			for _, step := range []struct {
+				target string
+				want   spanCounts
+			}{
+				{target: "/debug/spans/count", want: tt.want},
+				{target: "/debug/spans/count?reset=true", want: tt.want},
+				{target: "/debug/spans/count", want: spanCounts{}},
+			} {
+				resp := httptest.NewRecorder()
+				h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, step.target, nil))
+				var got spanCounts
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatalf("%s: %v: %s", step.target, err, resp.Body)
+				}
+				if got != step.want {
+					t.Errorf("%s = %+v, want %+v", step.target, got, step.want)
+				}
+			}
+		})
+	}
+}
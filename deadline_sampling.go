@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// spansSkipped counts root spans deliberately left unrecorded, by reason.
+var spansSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "otel_spans_skipped_total",
+	Help: "Root spans created non-recording to save overhead, by reason.",
+}, []string{"sampling_skipped"})
+
+type tightDeadlineKey struct{}
+
+// withTightDeadline marks ctx as serving a request whose deadline is too
+// close for tracing to be worth its overhead.
+func withTightDeadline(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tightDeadlineKey{}, true)
+}
+
+// hasTightDeadline reports whether req must complete within min, going by
+// its Grpc-Timeout header or its context deadline.
+func hasTightDeadline(req *http.Request, min time.Duration) bool {
+	deadline, ok := requestDeadline(req)
+	if ctxDeadline, hasCtx := req.Context().Deadline(); hasCtx && (!ok || ctxDeadline.Before(deadline)) {
+		deadline, ok = ctxDeadline, true
+	}
+	return ok && time.Until(deadline) < min
+}
+
+// deadlineSampler drops root spans of requests marked withTightDeadline,
+// counting them as sampling_skipped="deadline". Requests continuing a
+// remote trace follow their parent as usual. Everything else goes to next.
+type deadlineSampler struct {
+	next trace.Sampler
+}
+
+var _ trace.Sampler = deadlineSampler{}
+
+func (s deadlineSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if p.ParentContext != nil && p.ParentContext.Value(tightDeadlineKey{}) != nil &&
+		!oteltrace.SpanContextFromContext(p.ParentContext).IsValid() {
+		spansSkipped.WithLabelValues("deadline").Inc()
+		return trace.SamplingResult{
+			Decision:   trace.Drop,
+			Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s deadlineSampler) Description() string {
+	return s.next.Description()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestDeadlineSampler(t *testing.T) {
+	tests := []struct {
+		name        string
+		minDeadline time.Duration
+		deadline    time.Duration
+		grpcTimeout string
+		remote      bool
+		wantRecord  bool
+	}{
+		{name: "no deadline", minDeadline: 100 * time.Millisecond, wantRecord: true},
+		{name: "roomy deadline", minDeadline: 100 * time.Millisecond, deadline: time.Minute, wantRecord: true},
+		{name: "tight deadline", minDeadline: 100 * time.Millisecond, deadline: 10 * time.Millisecond},
+		{name: "tight grpc-timeout", minDeadline: 100 * time.Millisecond, grpcTimeout: "10m"},
+		{name: "disabled", deadline: 10 * time.Millisecond, wantRecord: true},
+		{name: "remote parent followed", minDeadline: 100 * time.Millisecond, deadline: 10 * time.Millisecond, remote: true, wantRecord: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestTracerProvider(t, trace.WithSampler(deadlineSampler{next: trace.ParentBased(trace.AlwaysSample())}))
+			useTestPropagator(t, propagation.TraceContext{})
+			m := newTestMiddleware(t)
+			m.minDeadline = tt.minDeadline
+
+			var recording bool
+			handler := m.Handle("/fibonacci", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				recording = oteltrace.SpanFromContext(req.Context()).IsRecording()
+			}))
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			req := httptest.NewRequest(http.MethodGet, "/fibonacci?n=1", nil).WithContext(ctx)
+			if tt.grpcTimeout != "" {
+				req.Header.Set("Grpc-Timeout", tt.grpcTimeout)
+			}
+			if tt.remote {
+				req.Header.Set("traceparent", "00-"+testTraceID+"-"+testSpanID+"-01")
+			}
+
+			skipped := testutil.ToFloat64(spansSkipped.WithLabelValues("deadline"))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if recording != tt.wantRecord {
+				t.Errorf("recording = %v, want %v", recording, tt.wantRecord)
+			}
+			wantSkipped := 1.0
+			if tt.wantRecord {
+				wantSkipped = 0
+			}
+			if got := testutil.ToFloat64(spansSkipped.WithLabelValues("deadline")) - skipped; got != wantSkipped {
+				t.Errorf("sampling_skipped=deadline grew by %v, want %v", got, wantSkipped)
+			}
+		})
+	}
+}
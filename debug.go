@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// flushHandler forces the tracer provider to export everything it has
+// buffered, instead of waiting for the batcher's schedule delay.
+type flushHandler struct {
+	tp *trace.TracerProvider
+}
+
+func (s *flushHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		resp.Header().Set("Allow", http.MethodPost)
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+	defer cancel()
+	if err := s.tp.ForceFlush(ctx); err != nil {
+		resp.WriteHeader(http.StatusInternalServerError)
+		resp.Write([]byte("flush failed: " + err.Error()))
+		return
+	}
+	resp.WriteHeader(http.StatusOK)
+	resp.Write([]byte("flushed"))
+}
+
+// shutdownHandler starts the same graceful shutdown as SIGTERM. When token
+// is set, requests must carry it as a bearer token.
+type shutdownHandler struct {
+	token   string
+	trigger func()
+}
+
+func (s *shutdownHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		resp.Header().Set("Allow", http.MethodPost)
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.token != "" {
+		got := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			resp.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	resp.WriteHeader(http.StatusAccepted)
+	resp.Write([]byte("shutting down"))
+	s.trigger()
+}
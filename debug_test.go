@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFlushHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		wantStatus int
+		wantSpans  int
+	}{
+		{name: "post flushes", method: http.MethodPost, wantStatus: http.StatusOK, wantSpans: 1},
+		{name: "get rejected", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed, wantSpans: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := tracetest.NewInMemoryExporter()
+			tp := trace.NewTracerProvider(trace.WithBatcher(exp, trace.WithBatchTimeout(time.Hour)))
+			defer tp.Shutdown(context.Background())
+
+			_, span := tp.Tracer("test").Start(context.Background(), "buffered")
+			span.End()
+			if n := len(exp.GetSpans()); n != 0 {
+				t.Fatalf("%d spans exported before the flush", n)
+			}
+
+			rec := httptest.NewRecorder()
+			(&flushHandler{tp: tp}).ServeHTTP(rec, httptest.NewRequest(tt.method, "/debug/flush", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if n := len(exp.GetSpans()); n != tt.wantSpans {
+				t.Errorf("exported %d spans, want %d", n, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestShutdownHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		auth       string
+		wantStatus int
+	}{
+		{name: "no token", method: http.MethodPost, wantStatus: http.StatusAccepted},
+		{name: "valid token", method: http.MethodPost, token: "s3cret", auth: "Bearer s3cret", wantStatus: http.StatusAccepted},
+		{name: "wrong token", method: http.MethodPost, token: "s3cret", auth: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "missing token", method: http.MethodPost, token: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "get rejected", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := lis.Addr().String()
+			lis.Close()
+
+			// slow stays in flight until released, to check it is drained.
+			started, release := make(chan struct{}), make(chan struct{})
+			mux := http.NewServeMux()
+			mux.HandleFunc("/slow", func(resp http.ResponseWriter, req *http.Request) {
+				close(started)
+				<-release
+				resp.Write([]byte("done"))
+			})
+			ctx, trigger := context.WithCancel(context.Background())
+			defer trigger()
+			mux.Handle("/debug/shutdown", &shutdownHandler{token: tt.token, trigger: trigger})
+			srv := newHTTPServer(addr, mux)
+			served := make(chan error, 1)
+			go func() { served <- runServer(ctx, srv, 0) }()
+			waitListening(t, addr)
+
+			slow := make(chan string, 1)
+			go func() {
+				resp, err := http.Get("http://" + addr + "/slow")
+				if err != nil {
+					slow <- err.Error()
+					return
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				slow <- string(body)
+			}()
+			<-started
+
+			req, _ := http.NewRequest(tt.method, "http://"+addr+"/debug/shutdown", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusAccepted {
+				select {
+				case err := <-served:
+					t.Errorf("server stopped without a valid request: %v", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+				trigger()
+			}
+			select {
+			case err := <-served:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("server still running")
+			}
+
+			// Shut down as main does after runServer returns: the in-flight
+			// request is drained before Shutdown returns.
+			shut := make(chan error, 1)
+			go func() { shut <- srv.Shutdown(context.Background()) }()
+			select {
+			case <-shut:
+				t.Fatal("shutdown returned with a request in flight")
+			case <-time.After(50 * time.Millisecond):
+			}
+			close(release)
+			if err := <-shut; err != nil {
+				t.Fatal(err)
+			}
+			if got := <-slow; got != "done" {
+				t.Errorf("in-flight request got %q, want done", got)
+			}
+			if _, err := net.Dial("tcp", addr); err == nil {
+				t.Error("server still accepting connections")
+			}
+		})
+	}
+}
+
+// waitListening waits until addr accepts connections.
+func waitListening(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s not listening", addr)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// timedExporter records how long each ExportSpans call on next takes and
+// how many spans it carried. Results and errors are passed through as is.
+type timedExporter struct {
+	next      trace.SpanExporter
+	duration  *prometheus.HistogramVec
+	batchSize prometheus.Gauge
+}
+
+var _ trace.SpanExporter = (*timedExporter)(nil)
+
+func newTimedExporter(next trace.SpanExporter) *timedExporter {
+	return &timedExporter{
+		next: next,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "otel_span_export_duration_seconds",
+			Help:    "Duration of span export calls by result.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"result"}),
+		batchSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "otel_span_export_batch_size",
+			Help: "Number of spans in the most recent export call.",
+		}),
+	}
+}
+
+func (e *timedExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	e.batchSize.Set(float64(len(spans)))
+	start := time.Now()
+	err := e.next.ExportSpans(ctx, spans)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	e.duration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (e *timedExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
+
+// Register registers the exporter's collectors with reg, switching to
+// already registered instances on a re-init.
+func (e *timedExporter) Register(reg prometheus.Registerer) (err error) {
+	if e.duration, err = registerOrReuse(reg, e.duration); err != nil {
+		return err
+	}
+	e.batchSize, err = registerOrReuse(reg, e.batchSize)
+	return err
+}
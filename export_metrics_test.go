@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTimedExporter(t *testing.T) {
+	failed := errors.New("collector unavailable")
+	tests := []struct {
+		name       string
+		err        error
+		spans      int
+		wantResult string
+	}{
+		{name: "ok", spans: 3, wantResult: "ok"},
+		{name: "error passed through", err: failed, spans: 2, wantResult: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubExporter{err: tt.err}
+			e := newTimedExporter(stub)
+			reg := prometheus.NewRegistry()
+			defer unregisterCollectors(reg)
+			if err := e.Register(reg); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := e.ExportSpans(context.Background(), testSpans(tt.spans)); err != tt.err {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && len(stub.spans()) != tt.spans {
+				t.Errorf("next got %d spans, want %d", len(stub.spans()), tt.spans)
+			}
+			var m dto.Metric
+			if err := e.duration.WithLabelValues(tt.wantResult).(prometheus.Histogram).Write(&m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetHistogram().GetSampleCount(); got != 1 {
+				t.Errorf("%s duration observations = %d, want 1", tt.wantResult, got)
+			}
+			if got := testutil.ToFloat64(e.batchSize); got != float64(tt.spans) {
+				t.Errorf("batch size = %v, want %d", got, tt.spans)
+			}
+		})
+	}
+}
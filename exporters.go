@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+// precheckTimeout bounds the connectivity check against an OTLP endpoint.
+const precheckTimeout = 3 * time.Second
+
+// newConfiguredExporter builds the span exporter(s) listed in EXPORTERS,
+// falling back to the single EXPORTER_TYPE. Each entry is "file" (writes to
+// w, optionally "file:pretty", "file:ndjson" or "file:chrome" to override
+// TRACE_FORMAT), "otlpgrpc" or "otlphttp" (ships spans to OTLP_ENDPOINT),
+// or "otlp", which picks one of the two by the standard
+// OTEL_EXPORTER_OTLP_PROTOCOL. Setting only that variable selects "otlp".
+// More than one entry exports every span to each of them, in parallel up to
+// EXPORTERS_CONCURRENCY and bounded per child by EXPORTERS_TIMEOUT. There is
+// only one trace file, so at most one entry may be "file"; two formats
+// interleaved in it could not be parsed.
+func newConfiguredExporter(ctx context.Context, w io.Writer) (trace.SpanExporter, error) {
+	specs := envList("EXPORTERS")
+	if len(specs) == 0 {
+		typ := "file"
+		if os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL") != "" {
+			typ = "otlp"
+		}
+		specs = []string{envString("EXPORTER_TYPE", typ)}
+	}
+	children := make([]trace.SpanExporter, 0, len(specs))
+	var files int
+	for _, spec := range specs {
+		if typ, _, _ := strings.Cut(spec, ":"); typ == "file" {
+			if files++; files > 1 {
+				return nil, newConfigError(ErrInvalidExporterType, nil, "EXPORTERS lists more than one file exporter: %q", specs)
+			}
+		}
+		exp, err := newExporterFromSpec(ctx, spec, w)
+		if err != nil {
+			if exp, err = fallbackExporter(spec, err); err != nil {
+				return nil, err
+			}
+		}
+		children = append(children, exp)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	// EXPORTERS_CONCURRENCY 同时导出的exporter数量, 0为全部并行
+	// EXPORTERS_TIMEOUT 单个exporter的导出超时, 0为不限
+	return newMultiExporter(
+		int(envUint("EXPORTERS_CONCURRENCY", 0)),
+		envDuration("EXPORTERS_TIMEOUT", 0),
+		children...,
+	), nil
+}
+
+// newExporterFromSpec builds one exporter from a "type[:format]" entry.
+func newExporterFromSpec(ctx context.Context, spec string, w io.Writer) (trace.SpanExporter, error) {
+	precheck := envBool("OTLP_PRECHECK", false)
+	insecure := envBool("OTLP_INSECURE", true)
+	typ, format, _ := strings.Cut(spec, ":")
+	if typ == "otlp" {
+		var err error
+		if typ, err = otlpExporterType(envString("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")); err != nil {
+			return nil, err
+		}
+	}
+	switch typ {
+	case "file":
+		if format == "" {
+			format = envString("TRACE_FORMAT", "pretty")
+		}
+		return newFileExporter(w, format)
+	case "otlpgrpc":
+		exp, err := newOTLPGRPCExporter(ctx, envString("OTLP_ENDPOINT", "localhost:4317"), insecure, precheck)
+		return withReplay(withBreaker(exp, typ), typ), err
+	case "otlphttp":
+		exp, err := newOTLPHTTPExporter(ctx, envString("OTLP_ENDPOINT", "localhost:4318"), insecure, precheck)
+		return withReplay(withBreaker(exp, typ), typ), err
+	default:
+		return nil, newConfigError(ErrInvalidExporterType, nil, "unknown exporter type %q", typ)
+	}
+}
+
+// otlpExporterType maps an OTEL_EXPORTER_OTLP_PROTOCOL value to the OTLP
+// exporter type implementing it. The SDK has no http/json exporter.
+func otlpExporterType(protocol string) (string, error) {
+	switch protocol {
+	case "grpc":
+		return "otlpgrpc", nil
+	case "http/protobuf":
+		return "otlphttp", nil
+	default:
+		return "", newConfigError(ErrInvalidExporterType, nil, "unsupported OTLP protocol %q, want grpc or http/protobuf", protocol)
+	}
+}
+
+// fallbackExporter handles a failure to build the exporter for spec. With
+// EXPORTER_FALLBACK=stdout an OTLP exporter is replaced by pretty printing to
+// stdout so the service stays up with local traces; otherwise, and for
+// invalid settings, err is returned.
+func fallbackExporter(spec string, err error) (trace.SpanExporter, error) {
+	typ, _, _ := strings.Cut(spec, ":")
+	if envString("EXPORTER_FALLBACK", "") != "stdout" || (typ != "otlp" && typ != "otlpgrpc" && typ != "otlphttp") ||
+		errors.Is(err, ErrInvalidExporterType) {
+		return nil, err
+	}
+	log.Printf("%s exporter unavailable, falling back to stdout: %v", typ, err)
+	return newExporter(os.Stdout)
+}
+
+// withBreaker wraps exp in a circuit breaker when OTLP_BREAKER_THRESHOLD
+// is set.
+func withBreaker(exp trace.SpanExporter, name string) trace.SpanExporter {
+	threshold := envUint("OTLP_BREAKER_THRESHOLD", 0)
+	if exp == nil || threshold == 0 {
+		return exp
+	}
+	return newBreakerExporter(exp, name, int(threshold), envDuration("OTLP_BREAKER_COOLDOWN", 30*time.Second))
+}
+
+// withReplay buffers up to OTLP_REPLAY_BUFFER spans from failed exports
+// and replays them once exp accepts spans again.
+func withReplay(exp trace.SpanExporter, name string) trace.SpanExporter {
+	limit := envUint("OTLP_REPLAY_BUFFER", 0)
+	if exp == nil || limit == 0 {
+		return exp
+	}
+	return newReplayExporter(exp, name, int(limit))
+}
+
+// newFileExporter writes spans to w in the given format, "pretty",
+// "ndjson" or "chrome" (Chrome Trace Event Format).
+func newFileExporter(w io.Writer, format string) (trace.SpanExporter, error) {
+	switch format {
+	case "pretty":
+		return newExporter(w)
+	case "ndjson":
+		var opts []ndjsonOption
+		// TRACE_BATCH_RESOURCE=true 时resource每批只输出一次, span通过resource_id引用
+		if envBool("TRACE_BATCH_RESOURCE", false) {
+			opts = append(opts, withBatchResource())
+		}
+		// TRACE_TYPED_ATTRIBUTES=true 时输出带类型的属性
+		if envBool("TRACE_TYPED_ATTRIBUTES", false) {
+			opts = append(opts, withTypedAttributes())
+		}
+		// TRACE_RELATIVE_EVENT_TIMES=true 时事件时间输出为相对span开始的偏移
+		if envBool("TRACE_RELATIVE_EVENT_TIMES", false) {
+			opts = append(opts, withRelativeEventTimes())
+		}
+		return newNDJSONExporter(w, opts...), nil
+	case "chrome":
+		exp := newChromeExporter(w)
+		exp.batchResource = envBool("TRACE_BATCH_RESOURCE", false)
+		return exp, nil
+	default:
+		return nil, newConfigError(ErrInvalidTraceFormat, nil, "unknown trace format %q", format)
+	}
+}
+
+// newOTLPGRPCExporter returns an OTLP/gRPC exporter for endpoint, either
+// host:port or unix:///path/to/socket for a collector listening on a Unix
+// domain socket (the sidecar agent pattern). With precheck set the endpoint
+// is dialed first, so a wrong address fails here instead of silently inside
+// the batcher.
+func newOTLPGRPCExporter(ctx context.Context, endpoint string, insecure, precheck bool) (trace.SpanExporter, error) {
+	network, address := "tcp", endpoint
+	if strings.HasPrefix(endpoint, "unix://") {
+		path := strings.TrimPrefix(endpoint, "unix://")
+		network, address = "unix", path
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, newConfigError(ErrUnreachableEndpoint, err, "otlp unix socket %s", path)
+		}
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, newConfigError(ErrUnreachableEndpoint, nil, "otlp unix socket %s: not a socket", path)
+		}
+	}
+	if precheck {
+		if err := checkEndpoint(network, address); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(address)}
+	if network == "unix" {
+		opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithContextDialer(
+			func(ctx context.Context, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", address)
+			},
+		)))
+		// A local socket carries no TLS.
+		insecure = true
+	}
+	if insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// newOTLPHTTPExporter returns an OTLP/HTTP exporter for endpoint (host:port).
+// precheck behaves as for newOTLPGRPCExporter.
+func newOTLPHTTPExporter(ctx context.Context, endpoint string, insecure, precheck bool) (trace.SpanExporter, error) {
+	if precheck {
+		if err := checkEndpoint("tcp", endpoint); err != nil {
+			return nil, err
+		}
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// checkEndpoint opens and closes a connection to address.
+func checkEndpoint(network, address string) error {
+	conn, err := net.DialTimeout(network, address, precheckTimeout)
+	if err != nil {
+		return newConfigError(ErrUnreachableEndpoint, err, "otlp endpoint %s is unreachable (set OTLP_PRECHECK=false if the collector starts later)", address)
+	}
+	return conn.Close()
+}
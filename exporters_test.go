@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// stubExporter records the batches it is given and fails them with err.
+// With block set it waits for the context to end instead.
+type stubExporter struct {
+	mu       sync.Mutex
+	err      error
+	block    bool
+	calls    int
+	exported []trace.ReadOnlySpan
+	shutdown bool
+}
+
+var _ trace.SpanExporter = (*stubExporter)(nil)
+
+func (e *stubExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	e.calls++
+	block, err := e.block, e.err
+	if !block && err == nil {
+		e.exported = append(e.exported, spans...)
+	}
+	e.mu.Unlock()
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return err
+}
+
+func (e *stubExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+// setErr makes later exports fail with err, or succeed when it is nil.
+func (e *stubExporter) setErr(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = err
+}
+
+// spans returns the spans exported successfully so far.
+func (e *stubExporter) spans() []trace.ReadOnlySpan {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]trace.ReadOnlySpan(nil), e.exported...)
+}
+
+func TestOTLPPrecheck(t *testing.T) {
+	up, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := closed.Addr().String()
+	closed.Close()
+
+	constructors := map[string]func(ctx context.Context, endpoint string, insecure, precheck bool) (trace.SpanExporter, error){
+		"grpc": newOTLPGRPCExporter,
+		"http": newOTLPHTTPExporter,
+	}
+	tests := []struct {
+		name     string
+		endpoint string
+		precheck bool
+		wantErr  bool
+	}{
+		{name: "reachable", endpoint: up.Addr().String(), precheck: true},
+		{name: "unreachable", endpoint: down, precheck: true, wantErr: true},
+		{name: "unreachable without precheck", endpoint: down, precheck: false},
+	}
+	for proto, newExporter := range constructors {
+		for _, tt := range tests {
+			t.Run(proto+"/"+tt.name, func(t *testing.T) {
+				exp, err := newExporter(context.Background(), tt.endpoint, true, tt.precheck)
+				if !tt.wantErr {
+					if err != nil {
+						t.Fatal(err)
+					}
+					exp.Shutdown(context.Background())
+					return
+				}
+				if !errors.Is(err, ErrUnreachableEndpoint) {
+					t.Fatalf("err = %v, want ErrUnreachableEndpoint", err)
+				}
+				if !strings.Contains(err.Error(), tt.endpoint) {
+					t.Errorf("error %q does not name the endpoint %s", err, tt.endpoint)
+				}
+			})
+		}
+	}
+}
+
+// traceCollector is an OTLP trace service counting the spans it receives.
+type traceCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+	received chan int
+}
+
+func (c *traceCollector) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	var n int
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			n += len(ss.GetSpans())
+		}
+	}
+	c.received <- n
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestOTLPUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "otlp.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	collector := &traceCollector{received: make(chan int, 1)}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, collector)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	notSocket := filepath.Join(dir, "plain")
+	if err := os.WriteFile(notSocket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  bool
+	}{
+		{name: "socket", endpoint: "unix://" + socket},
+		{name: "missing path", endpoint: "unix://" + filepath.Join(dir, "missing.sock"), wantErr: true},
+		{name: "not a socket", endpoint: "unix://" + notSocket, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			exp, err := newOTLPGRPCExporter(ctx, tt.endpoint, false, false)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnreachableEndpoint) {
+					t.Fatalf("err = %v, want ErrUnreachableEndpoint", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer exp.Shutdown(context.Background())
+
+			if err := exp.ExportSpans(ctx, testSpans(3)); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case n := <-collector.received:
+				if n != 3 {
+					t.Errorf("collector received %d spans, want 3", n)
+				}
+			case <-ctx.Done():
+				t.Fatal("collector received nothing")
+			}
+		})
+	}
+}
+
+func TestExporterFallback(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantFallback bool
+		wantErr      error
+	}{
+		{
+			name:    "no fallback",
+			env:     map[string]string{"EXPORTER_TYPE": "otlpgrpc", "OTLP_ENDPOINT": down},
+			wantErr: ErrUnreachableEndpoint,
+		},
+		{
+			name:         "grpc falls back",
+			env:          map[string]string{"EXPORTER_TYPE": "otlpgrpc", "OTLP_ENDPOINT": down, "EXPORTER_FALLBACK": "stdout"},
+			wantFallback: true,
+		},
+		{
+			name:         "http falls back",
+			env:          map[string]string{"EXPORTER_TYPE": "otlphttp", "OTLP_ENDPOINT": down, "EXPORTER_FALLBACK": "stdout"},
+			wantFallback: true,
+		},
+		{
+			name:    "invalid type does not fall back",
+			env:     map[string]string{"EXPORTER_TYPE": "kafka", "EXPORTER_FALLBACK": "stdout"},
+			wantErr: ErrInvalidExporterType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTLP_PRECHECK", "true")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			logs := captureLog(t)
+			exp, err := newConfiguredExporter(context.Background(), io.Discard)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer exp.Shutdown(context.Background())
+			if _, ok := exp.(*stdouttrace.Exporter); ok != tt.wantFallback {
+				t.Errorf("exporter = %T, want stdout fallback %v", exp, tt.wantFallback)
+			}
+			if !strings.Contains(logs.String(), "falling back to stdout") {
+				t.Errorf("no fallback warning logged: %q", logs)
+			}
+		})
+	}
+}
+
+// otlpCollector serves OTLP over both gRPC and HTTP on one h2c listener and
+// reports which protocol each export arrived over.
+func otlpCollector(t *testing.T) (endpoint string, received chan string) {
+	t.Helper()
+	received = make(chan string, 1)
+	grpcCollector := &traceCollector{received: make(chan int, 1)}
+	grpcSrv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(grpcSrv, grpcCollector)
+	go func() {
+		for range grpcCollector.received {
+			received <- "grpc"
+		}
+	}()
+
+	srv := newHTTPServer("127.0.0.1:0", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+			grpcSrv.ServeHTTP(resp, req)
+			return
+		}
+		io.Copy(io.Discard, req.Body)
+		if req.URL.Path == "/v1/traces" && req.Header.Get("Content-Type") == "application/x-protobuf" {
+			received <- "http/protobuf"
+		}
+		resp.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	enableH2C(srv)
+	lis, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		srv.Close()
+		grpcSrv.Stop()
+		close(grpcCollector.received)
+	})
+	return lis.Addr().String(), received
+}
+
+func TestOTLPProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		// want is the protocol the spans arrive over.
+		want    string
+		wantErr error
+	}{
+		{name: "grpc", env: map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, want: "grpc"},
+		{name: "http/protobuf", env: map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf"}, want: "http/protobuf"},
+		{name: "otlp defaults to grpc", env: map[string]string{"EXPORTER_TYPE": "otlp"}, want: "grpc"},
+		{
+			name: "otlp in EXPORTERS",
+			env:  map[string]string{"EXPORTERS": "otlp", "OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf"},
+			want: "http/protobuf",
+		},
+		{
+			name: "EXPORTER_TYPE wins",
+			env:  map[string]string{"EXPORTER_TYPE": "otlphttp", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			want: "http/protobuf",
+		},
+		{
+			name:    "unsupported protocol",
+			env:     map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json", "EXPORTER_FALLBACK": "stdout"},
+			wantErr: ErrInvalidExporterType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, received := otlpCollector(t)
+			t.Setenv("OTLP_ENDPOINT", endpoint)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			exp, err := newConfiguredExporter(ctx, io.Discard)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer exp.Shutdown(context.Background())
+
+			if err := exp.ExportSpans(ctx, testSpans(1)); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-received:
+				if got != tt.want {
+					t.Errorf("spans arrived over %s, want %s", got, tt.want)
+				}
+			case <-ctx.Done():
+				t.Fatal("collector received nothing")
+			}
+		})
+	}
+}
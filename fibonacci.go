@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// fibMode selects the algorithm used to compute a fibonacci number.
+type fibMode string
+
+const (
+	fibModeRecursive fibMode = "recursive"
+	fibModeIter      fibMode = "iter"
+	fibModeMemo      fibMode = "memo"
+	fibModeBig       fibMode = "big"
+	fibModeMatrix    fibMode = "matrix"
+)
+
+// parseFibMode validates s as one of the known modes.
+func parseFibMode(s string) (fibMode, error) {
+	switch m := fibMode(s); m {
+	case fibModeRecursive, fibModeIter, fibModeMemo, fibModeBig, fibModeMatrix:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown fibonacci mode %q", s)
+}
+
+// legacySpanNames restores the old per-n span names such as "fibonacci-40".
+// They make every n a distinct span name, which trace backends index badly.
+var legacySpanNames bool
+
+// complexityAttrs adds fib.algorithm and fib.complexity to every
+// fibonacci span, so a trace explains why latency differs between modes.
+var complexityAttrs bool
+
+// fibSpanNames are the stable span names of each mode.
+var fibSpanNames = map[fibMode]string{
+	fibModeRecursive: "fibonacci",
+	fibModeIter:      "fibonacci-iter",
+	fibModeMemo:      "fibonacci-memo",
+	fibModeBig:       "fibonacci-big",
+	fibModeMatrix:    "fibonacci-matrix",
+}
+
+// fibComplexity is the time complexity of each mode. Big mode performs n
+// additions of numbers up to O(n) bits long.
+var fibComplexity = map[fibMode]string{
+	fibModeRecursive: "O(2^n)",
+	fibModeIter:      "O(n)",
+	fibModeMemo:      "O(n)",
+	fibModeBig:       "O(n^2)",
+	fibModeMatrix:    "O(log n)",
+}
+
+// fibMaxAttrs, when non-zero, caps the number of attributes a fibonacci
+// span carries. Optional attributes beyond it are dropped and the span is
+// flagged with attributes.capped=true instead.
+var fibMaxAttrs int
+
+// fibSpan is a fibonacci span that keeps count of its attributes.
+type fibSpan struct {
+	oteltrace.Span
+	attrs  int
+	capped bool
+}
+
+// SetOptional sets kvs as far as fibMaxAttrs allows.
+func (s *fibSpan) SetOptional(kvs ...attribute.KeyValue) {
+	if fibMaxAttrs > 0 && s.attrs+len(kvs) > fibMaxAttrs {
+		room := fibMaxAttrs - s.attrs
+		if room < 0 {
+			room = 0
+		}
+		kvs = kvs[:room]
+		if !s.capped {
+			s.capped = true
+			s.Span.SetAttributes(attrKey("attributes.capped").Bool(true))
+		}
+	}
+	s.attrs += len(kvs)
+	s.Span.SetAttributes(kvs...)
+}
+
+// startFibSpan starts the span for one fibonacci step of mode. The span name
+// is the mode's stable name and n goes into the fib.n attribute, unless
+// legacySpanNames is set.
+func startFibSpan(ctx context.Context, mode fibMode, n uint64) (context.Context, *fibSpan) {
+	name := fibSpanNames[mode]
+	if legacySpanNames {
+		name = fmt.Sprintf("%s-%d", name, n)
+	}
+	attrs := []attribute.KeyValue{attrKey("fib.n").Int64(int64(n))}
+	if complexityAttrs {
+		attrs = append(attrs,
+			attrKey("fib.algorithm").String(string(mode)),
+			attrKey("fib.complexity").String(fibComplexity[mode]),
+		)
+	}
+	ctx, span := tracer("fibonacci").Start(ctx, name, oteltrace.WithAttributes(attrs...))
+	countSpan(ctx)
+	return ctx, &fibSpan{Span: span, attrs: len(attrs)}
+}
+
+// fibCrossover, when non-zero, makes the recursive algorithm solve
+// subproblems with n below it iteratively instead of recursing further. The
+// recursion tree, and the span count with it, shrinks accordingly.
+var fibCrossover uint64
+
+// fibMaxDepth, when non-zero, caps the recursion depth of the recursive
+// algorithm; deeper calls fail with errRecursionTooDeep. It guards against
+// an n limit configured higher than the stack or span volume can take.
+var fibMaxDepth uint64
+
+// fibAggregateBelow, when non-zero, makes the recursive algorithm compute n
+// below it without per call spans, reporting the whole recursion on one
+// span instead. For such small n the span tree costs more than it tells.
+var fibAggregateBelow uint64
+
+// errRecursionTooDeep is returned when the recursion exceeds fibMaxDepth.
+var errRecursionTooDeep = errors.New("fibonacci recursion too deep")
+
+// fibRecursionDepth returns the depth the recursive algorithm reaches
+// computing fib(n), as counted against fibMaxDepth: calls recurse down to
+// n=2, or to fibCrossover when that is higher. The aggregated recursion
+// below fibAggregateBelow has no crossover.
+func fibRecursionDepth(n uint64) uint64 {
+	floor := uint64(2)
+	if n >= fibAggregateBelow && fibCrossover > floor {
+		floor = fibCrossover
+	}
+	if n < floor {
+		return 1
+	}
+	return n - floor + 2
+}
+
+// checkFibonacci returns the error computeFibonacci is bound to fail with
+// for mode and n, if any, without computing anything: an overflow, or a
+// recursion deeper than fibMaxDepth.
+func checkFibonacci(mode fibMode, n uint64) error {
+	if mode != fibModeBig && n > maxUint64FibN {
+		return errFibonacciOverflow
+	}
+	if mode == fibModeRecursive && fibMaxDepth > 0 && fibRecursionDepth(n) > fibMaxDepth {
+		return fmt.Errorf("%w: limit is %d", errRecursionTooDeep, fibMaxDepth)
+	}
+	return nil
+}
+
+// fibUint64 computes fib(n) iteratively without tracing. It is meant for
+// small n only and does not check the compute budget.
+func fibUint64(n uint64) uint64 {
+	var a, b uint64 = 0, 1
+	for i := uint64(0); i < n; i++ {
+		a, b = b, a+b
+	}
+	return a
+}
+
+// maxUint64FibN is the largest n whose fibonacci number fits in a uint64.
+const maxUint64FibN = 93
+
+// errFibonacciOverflow is returned when fib(n) does not fit in a uint64.
+var errFibonacciOverflow = fmt.Errorf("result overflows uint64 for n > %d, use mode=big", maxUint64FibN)
+
+// budgetCheckInterval is how many loop iterations the iterative algorithms
+// run between compute budget checks.
+const budgetCheckInterval = 1 << 12
+
+// computeFibonacci computes fib(n) with the given mode and returns it in
+// decimal. Modes other than big fail with errFibonacciOverflow for n beyond
+// maxUint64FibN.
+func computeFibonacci(ctx context.Context, mode fibMode, n uint64) (string, error) {
+	if mode != fibModeBig && n > maxUint64FibN {
+		return "", errFibonacciOverflow
+	}
+	switch mode {
+	case fibModeIter:
+		v, err := fibonacciIter(ctx, n)
+		return fmt.Sprint(v), err
+	case fibModeMemo:
+		v, err := fibonacciMemo(ctx, n, map[uint64]uint64{})
+		return fmt.Sprint(v), err
+	case fibModeMatrix:
+		v, err := fibonacciMatrix(ctx, n)
+		return fmt.Sprint(v), err
+	case fibModeBig:
+		v, err := fibonacciBig(ctx, n)
+		if err != nil {
+			return "", err
+		}
+		return v.String(), nil
+	default:
+		if n < fibAggregateBelow {
+			v, err := fibonacciAggregated(ctx, n)
+			return fmt.Sprint(v), err
+		}
+		v, err := fibonacci(ctx, n, 1)
+		return fmt.Sprint(v), err
+	}
+}
+
+// fibonacciAggregated runs the recursive algorithm for a small n untraced
+// and summarizes it on a single span with the result and the number of
+// calls made. It honours fibMaxDepth and, every budgetCheckInterval calls,
+// the compute budget like the traced recursion.
+func fibonacciAggregated(ctx context.Context, n uint64) (uint64, error) {
+	_, span := startFibSpan(ctx, fibModeRecursive, n)
+	defer span.End()
+
+	if fibMaxDepth > 0 && fibRecursionDepth(n) > fibMaxDepth {
+		err := fmt.Errorf("%w: limit is %d", errRecursionTooDeep, fibMaxDepth)
+		recordComputeError(span, err)
+		return 0, err
+	}
+	var calls uint64
+	var err error
+	var rec func(n uint64) uint64
+	rec = func(n uint64) uint64 {
+		if err != nil {
+			return 0
+		}
+		calls++
+		if calls%budgetCheckInterval == 0 {
+			if err = checkComputeBudget(ctx); err != nil {
+				return 0
+			}
+		}
+		if n <= 1 {
+			return n
+		}
+		return rec(n-1) + rec(n-2)
+	}
+	v := rec(n)
+	countCalls(ctx, calls)
+	if err != nil {
+		recordComputeError(span, err)
+		return 0, err
+	}
+	span.SetOptional(
+		attrKey("fib.result").Int64(int64(v)),
+		attrKey("fib.calls").Int64(int64(calls)),
+	)
+	return v, nil
+}
+
+// fibonacciIter computes fib(n) iteratively inside a single span.
+func fibonacciIter(ctx context.Context, n uint64) (uint64, error) {
+	_, span := startFibSpan(ctx, fibModeIter, n)
+	countCall(ctx)
+	defer span.End()
+
+	var a, b uint64 = 0, 1
+	for i := uint64(0); i < n; i++ {
+		if i%budgetCheckInterval == 0 {
+			if err := checkComputeBudget(ctx); err != nil {
+				recordComputeError(span, err)
+				return 0, err
+			}
+		}
+		a, b = b, a+b
+	}
+	span.SetOptional(attrKey("fib.iterations").Int64(int64(n)))
+	return a, nil
+}
+
+// fibonacciMemo is the recursive algorithm with memoization; only calls that
+// miss the memo produce a span.
+func fibonacciMemo(ctx context.Context, n uint64, memo map[uint64]uint64) (uint64, error) {
+	if v, ok := memo[n]; ok {
+		return v, nil
+	}
+	ctx, span := startFibSpan(ctx, fibModeMemo, n)
+	countCall(ctx)
+	defer span.End()
+
+	if err := checkComputeBudget(ctx); err != nil {
+		recordComputeError(span, err)
+		return 0, err
+	}
+	if n <= 1 {
+		memo[n] = n
+		return n, nil
+	}
+	a, err := fibonacciMemo(ctx, n-1, memo)
+	if err != nil {
+		return 0, err
+	}
+	b, err := fibonacciMemo(ctx, n-2, memo)
+	if err != nil {
+		return 0, err
+	}
+	memo[n] = a + b
+	return a + b, nil
+}
+
+// fibonacciBig computes fib(n) iteratively with arbitrary precision, so it
+// never overflows.
+func fibonacciBig(ctx context.Context, n uint64) (*big.Int, error) {
+	_, span := startFibSpan(ctx, fibModeBig, n)
+	countCall(ctx)
+	defer span.End()
+
+	a, b := big.NewInt(0), big.NewInt(1)
+	for i := uint64(0); i < n; i++ {
+		if i%budgetCheckInterval == 0 {
+			if err := checkComputeBudget(ctx); err != nil {
+				recordComputeError(span, err)
+				return nil, err
+			}
+		}
+		a.Add(a, b)
+		a, b = b, a
+	}
+	span.SetOptional(attrKey("fib.result_bits").Int(a.BitLen()))
+	return a, nil
+}
+
+// fibMatrix is the 2x2 matrix {{a, b}, {c, d}}.
+type fibMatrix [4]uint64
+
+func (m fibMatrix) mul(o fibMatrix) fibMatrix {
+	return fibMatrix{
+		m[0]*o[0] + m[1]*o[2], m[0]*o[1] + m[1]*o[3],
+		m[2]*o[0] + m[3]*o[2], m[2]*o[1] + m[3]*o[3],
+	}
+}
+
+// fibonacciMatrix computes fib(n) as the top right entry of {{1, 1}, {1, 0}}^n
+// by exponentiation by squaring, with one child span per bit of n, so the
+// trace shows the O(log n) steps. Entries other than fib(n) may wrap around
+// for n close to maxUint64FibN, which leaves fib(n) itself exact since the
+// arithmetic is modulo 2^64.
+func fibonacciMatrix(ctx context.Context, n uint64) (uint64, error) {
+	ctx, span := startFibSpan(ctx, fibModeMatrix, n)
+	defer span.End()
+
+	result, base := fibMatrix{1, 0, 0, 1}, fibMatrix{1, 1, 1, 0}
+	var steps int64
+	for rest := n; rest > 0; rest >>= 1 {
+		if err := checkComputeBudget(ctx); err != nil {
+			recordComputeError(span, err)
+			return 0, err
+		}
+		_, step := tracer("fibonacci").Start(ctx, "fibonacci-matrix-step", oteltrace.WithAttributes(
+			attrKey("fib.step").Int64(steps),
+			attrKey("fib.multiply").Bool(rest&1 == 1),
+		))
+		countCall(ctx)
+		countSpan(ctx)
+		if rest&1 == 1 {
+			result = result.mul(base)
+		}
+		if rest > 1 {
+			base = base.mul(base)
+		}
+		step.End()
+		steps++
+	}
+	span.SetOptional(attrKey("fib.steps").Int64(steps))
+	return result[1], nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// setFibVar sets a fibonacci tuning variable for the rest of the test.
+func setFibVar[T any](t *testing.T, v *T, value T) {
+	t.Helper()
+	prev := *v
+	*v = value
+	t.Cleanup(func() { *v = prev })
+}
+
+func TestFibSpanNames(t *testing.T) {
+	tests := []struct {
+		mode     fibMode
+		legacy   bool
+		wantName string
+	}{
+		{mode: fibModeRecursive, wantName: "fibonacci"},
+		{mode: fibModeRecursive, legacy: true, wantName: "fibonacci-7"},
+		{mode: fibModeIter, wantName: "fibonacci-iter"},
+		{mode: fibModeIter, legacy: true, wantName: "fibonacci-iter-7"},
+		{mode: fibModeMemo, wantName: "fibonacci-memo"},
+		{mode: fibModeBig, wantName: "fibonacci-big"},
+		{mode: fibModeMatrix, wantName: "fibonacci-matrix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantName, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &legacySpanNames, tt.legacy)
+
+			if _, err := computeFibonacci(context.Background(), tt.mode, 7); err != nil {
+				t.Fatal(err)
+			}
+			var found bool
+			for _, s := range rec.Ended() {
+				if s.Name() == tt.wantName && spanAttr(s, attrKey("fib.n")) == "7" {
+					found = true
+				}
+				if !tt.legacy && strings.ContainsAny(s.Name(), "0123456789") {
+					t.Errorf("span name %q varies with n", s.Name())
+				}
+			}
+			if !found {
+				t.Errorf("no span named %q with fib.n=7", tt.wantName)
+			}
+		})
+	}
+}
+
+func TestFibCrossover(t *testing.T) {
+	tests := []struct {
+		crossover uint64
+		n         uint64
+		// wantStrategy is fib.strategy on the top level span.
+		wantStrategy string
+	}{
+		{crossover: 0, n: 20, wantStrategy: ""},
+		{crossover: 10, n: 9, wantStrategy: "iterative"},
+		{crossover: 10, n: 10, wantStrategy: "recursive"},
+		{crossover: 10, n: 11, wantStrategy: "recursive"},
+		{crossover: 10, n: 20, wantStrategy: "recursive"},
+		{crossover: 2, n: 20, wantStrategy: "recursive"},
+		{crossover: 100, n: 90, wantStrategy: "iterative"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("crossover=%d/n=%d", tt.crossover, tt.n), func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &fibCrossover, tt.crossover)
+
+			got, err := fibonacci(context.Background(), tt.n, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := fibUint64(tt.n); got != want {
+				t.Errorf("fib(%d) = %d, want %d", tt.n, got, want)
+			}
+			for _, s := range rec.Ended() {
+				if spanAttr(s, attrKey("fib.n")) != fmt.Sprint(tt.n) {
+					continue
+				}
+				if got := spanAttr(s, attrKey("fib.strategy")); got != tt.wantStrategy {
+					t.Errorf("fib.strategy = %q, want %q", got, tt.wantStrategy)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFibCrossover(b *testing.B) {
+	tp := trace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	for _, crossover := range []uint64{0, 5, 10, 15, 20} {
+		b.Run(fmt.Sprintf("crossover=%d", crossover), func(b *testing.B) {
+			prev := fibCrossover
+			fibCrossover = crossover
+			defer func() { fibCrossover = prev }()
+			for i := 0; i < b.N; i++ {
+				if _, err := fibonacci(context.Background(), 20, 1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFibComplexityAttributes(t *testing.T) {
+	tests := []struct {
+		mode    fibMode
+		enabled bool
+		want    string
+	}{
+		{mode: fibModeRecursive, enabled: true, want: "O(2^n)"},
+		{mode: fibModeIter, enabled: true, want: "O(n)"},
+		{mode: fibModeMemo, enabled: true, want: "O(n)"},
+		{mode: fibModeBig, enabled: true, want: "O(n^2)"},
+		{mode: fibModeMatrix, enabled: true, want: "O(log n)"},
+		{mode: fibModeIter, enabled: false, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%v", tt.mode, tt.enabled), func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &complexityAttrs, tt.enabled)
+
+			if _, err := computeFibonacci(context.Background(), tt.mode, 7); err != nil {
+				t.Fatal(err)
+			}
+			wantAlgorithm := ""
+			if tt.enabled {
+				wantAlgorithm = string(tt.mode)
+			}
+			for _, s := range rec.Ended() {
+				if spanAttr(s, attrKey("fib.n")) == "" {
+					continue
+				}
+				if got := spanAttr(s, attrKey("fib.complexity")); got != tt.want {
+					t.Errorf("%s fib.complexity = %q, want %q", s.Name(), got, tt.want)
+				}
+				if got := spanAttr(s, attrKey("fib.algorithm")); got != wantAlgorithm {
+					t.Errorf("%s fib.algorithm = %q, want %q", s.Name(), got, wantAlgorithm)
+				}
+			}
+		})
+	}
+}
+
+func TestFibMaxDepth(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxDepth   uint64
+		target     string
+		wantStatus int
+	}{
+		{name: "unlimited", target: "/fibonacci?n=10&mode=recursive", wantStatus: http.StatusOK},
+		{name: "within limit", maxDepth: 3, target: "/fibonacci?n=3&mode=recursive", wantStatus: http.StatusOK},
+		{name: "too deep", maxDepth: 3, target: "/fibonacci?n=4&mode=recursive", wantStatus: http.StatusBadRequest},
+		{name: "iterative unaffected", maxDepth: 3, target: "/fibonacci?n=10", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &fibMaxDepth, tt.maxDepth)
+			resp, _ := serveFibonacci(t, newTestFibonacciHandler(), tt.target)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+
+			var failed []string
+			for _, s := range rec.Ended() {
+				if s.Status().Code == codes.Error {
+					failed = append(failed, s.Name())
+					if !strings.Contains(s.Status().Description, errRecursionTooDeep.Error()) {
+						t.Errorf("%s status = %q, want %q", s.Name(), s.Status().Description, errRecursionTooDeep)
+					}
+				}
+			}
+			if wantFailed := tt.wantStatus != http.StatusOK; (len(failed) > 0) != wantFailed {
+				t.Errorf("failed spans %v, want failure recorded %v", failed, wantFailed)
+			}
+		})
+	}
+}
+
+func TestFibMaxAttrs(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       fibMode
+		maxAttrs   int
+		complexity bool
+		wantCapped bool
+		// wantAttrs is the number of attributes on the top fibonacci span,
+		// the cap flag included.
+		wantAttrs int
+	}{
+		{name: "uncapped", mode: fibModeRecursive, wantAttrs: 2},
+		{name: "within cap", mode: fibModeRecursive, maxAttrs: 2, wantAttrs: 2},
+		{name: "optional dropped", mode: fibModeRecursive, maxAttrs: 1, wantCapped: true, wantAttrs: 2},
+		{name: "crowded by complexity", mode: fibModeRecursive, maxAttrs: 3, complexity: true, wantCapped: true, wantAttrs: 4},
+		{name: "iterative", mode: fibModeIter, maxAttrs: 1, wantCapped: true, wantAttrs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &fibMaxAttrs, tt.maxAttrs)
+			setFibVar(t, &complexityAttrs, tt.complexity)
+			if _, err := computeFibonacci(context.Background(), tt.mode, 5); err != nil {
+				t.Fatal(err)
+			}
+
+			var top trace.ReadOnlySpan
+			for _, s := range rec.Ended() {
+				if spanAttr(s, attrKey("fib.n")) == "5" {
+					top = s
+				}
+			}
+			if top == nil {
+				t.Fatal("no span for n=5")
+			}
+			if got := spanAttr(top, attrKey("attributes.capped")) == "true"; got != tt.wantCapped {
+				t.Errorf("attributes.capped = %v, want %v", got, tt.wantCapped)
+			}
+			if got := len(top.Attributes()); got != tt.wantAttrs {
+				t.Errorf("%d attributes %v, want %d", got, top.Attributes(), tt.wantAttrs)
+			}
+		})
+	}
+}
+
+func TestFibAggregateBelow(t *testing.T) {
+	tests := []struct {
+		name           string
+		aggregateBelow uint64
+		n              uint64
+		want           string
+		wantSpans      int
+		// wantCalls is the number of calls counted, which is also fib.calls
+		// on a summary span.
+		wantCalls uint64
+	}{
+		{name: "disabled", n: 4, want: "3", wantSpans: 9, wantCalls: 9},
+		{name: "small input summarized", aggregateBelow: 5, n: 4, want: "3", wantSpans: 1, wantCalls: 9},
+		{name: "trivial input summarized", aggregateBelow: 5, n: 1, want: "1", wantSpans: 1, wantCalls: 1},
+		{name: "at threshold traced", aggregateBelow: 5, n: 5, want: "5", wantSpans: 15, wantCalls: 15},
+		{name: "large input traced", aggregateBelow: 5, n: 7, want: "13", wantSpans: 41, wantCalls: 41},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			setFibVar(t, &fibAggregateBelow, tt.aggregateBelow)
+			ctx, counter := withCallCounter(context.Background())
+			got, err := computeFibonacci(ctx, fibModeRecursive, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("fib(%d) = %s, want %s", tt.n, got, tt.want)
+			}
+			if calls := counter.calls.Load(); calls != tt.wantCalls {
+				t.Errorf("counted %d calls, want %d", calls, tt.wantCalls)
+			}
+
+			ended := rec.Ended()
+			if len(ended) != tt.wantSpans {
+				t.Fatalf("got %d spans, want %d", len(ended), tt.wantSpans)
+			}
+			summary := spanAttr(ended[0], attrKey("fib.calls")) != ""
+			if wantSummary := tt.wantSpans == 1; summary != wantSummary {
+				t.Fatalf("summary span = %v, want %v", summary, wantSummary)
+			}
+			if !summary {
+				return
+			}
+			for key, want := range map[string]string{
+				"fib.n":      fmt.Sprint(tt.n),
+				"fib.result": tt.want,
+				"fib.calls":  fmt.Sprint(tt.wantCalls),
+			} {
+				if got := spanAttr(ended[0], attrKey(key)); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFibonacciMatrix(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want uint64
+		// wantSteps is the number of squaring step spans, one per bit of n.
+		wantSteps int
+	}{
+		{n: 0, want: 0, wantSteps: 0},
+		{n: 1, want: 1, wantSteps: 1},
+		{n: 2, want: 1, wantSteps: 2},
+		{n: 10, want: 55, wantSteps: 4},
+		{n: 50, want: 12586269025, wantSteps: 6},
+		{n: 64, want: 10610209857723, wantSteps: 7},
+		{n: 90, want: 2880067194370816120, wantSteps: 7},
+		{n: maxUint64FibN, want: 12200160415121876738, wantSteps: 7},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			got, err := fibonacciMatrix(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("fibonacciMatrix(%d) = %d, want %d", tt.n, got, tt.want)
+			}
+			if iter, _ := fibonacciIter(context.Background(), tt.n); got != iter {
+				t.Errorf("fibonacciMatrix(%d) = %d, iterative mode gives %d", tt.n, got, iter)
+			}
+
+			var steps int
+			for _, s := range rec.Ended() {
+				if s.Name() == "fibonacci-matrix-step" {
+					steps++
+				}
+			}
+			if steps != tt.wantSteps {
+				t.Errorf("got %d step spans, want %d", steps, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func BenchmarkFibMatrixVsIter(b *testing.B) {
+	tp := trace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	for _, mode := range []fibMode{fibModeIter, fibModeMatrix} {
+		for _, n := range []uint64{10, 50, maxUint64FibN} {
+			b.Run(fmt.Sprintf("mode=%s/n=%d", mode, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := computeFibonacci(context.Background(), mode, n); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// runFlushLoop flushes tp every interval until ctx is done, so spans reach
+// the trace file at a predictable pace instead of whenever the batcher's
+// schedule delay or batch size says. Each flush may take up to interval.
+func runFlushLoop(ctx context.Context, wg *sync.WaitGroup, tp interface{ ForceFlush(context.Context) error }, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			flushCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := tp.ForceFlush(flushCtx); err != nil && ctx.Err() == nil {
+				log.Printf("periodic span flush: %v", err)
+			}
+			cancel()
+		}
+	}()
+}
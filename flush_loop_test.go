@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRunFlushLoop(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		// wantWritten is whether the span reaches the file within a few
+		// intervals; the batcher alone would hold it for an hour.
+		wantWritten bool
+	}{
+		{name: "no loop", wantWritten: false},
+		{name: "50ms", interval: 50 * time.Millisecond, wantWritten: true},
+		{name: "200ms", interval: 200 * time.Millisecond, wantWritten: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.ndjson")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			tp := trace.NewTracerProvider(trace.WithBatcher(newNDJSONExporter(f), trace.WithBatchTimeout(time.Hour)))
+			defer tp.Shutdown(context.Background())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			if tt.interval > 0 {
+				runFlushLoop(ctx, &wg, tp, tt.interval)
+			}
+			_, span := tp.Tracer("test").Start(context.Background(), "span")
+			span.End()
+
+			wait := 3 * tt.interval
+			if wait == 0 {
+				wait = 200 * time.Millisecond
+			}
+			start := time.Now()
+			var written bool
+			for !written && time.Since(start) < wait {
+				time.Sleep(10 * time.Millisecond)
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				written = bytes.Contains(data, []byte(`"span"`))
+			}
+			if written != tt.wantWritten {
+				t.Errorf("span written within %s = %v, want %v", wait, written, tt.wantWritten)
+			}
+
+			// The loop stops once its context is done.
+			cancel()
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("flush loop still running after cancel")
+			}
+		})
+	}
+}
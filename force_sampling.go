@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type forceSampleKey struct{}
+
+// withForcedSampling marks ctx so its root span is always sampled.
+func withForcedSampling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceSampleKey{}, true)
+}
+
+// forceSampler samples root spans whose context was marked with
+// withForcedSampling and leaves every other decision to next. Spans with a
+// parent keep following it so traces aren't torn apart.
+type forceSampler struct {
+	next trace.Sampler
+}
+
+var _ trace.Sampler = forceSampler{}
+
+func (s forceSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if p.ParentContext != nil && p.ParentContext.Value(forceSampleKey{}) != nil {
+		parent := oteltrace.SpanContextFromContext(p.ParentContext)
+		if !parent.IsValid() {
+			return trace.SamplingResult{
+				Decision:   trace.RecordAndSample,
+				Tracestate: parent.TraceState(),
+			}
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s forceSampler) Description() string {
+	return "ForceSampler{" + s.next.Description() + "}"
+}
+
+// largeFibonacciN reports whether a /fibonacci request asks for n of at
+// least threshold, so the expensive requests are always traced.
+func largeFibonacciN(threshold uint64) func(*http.Request) bool {
+	return func(req *http.Request) bool {
+		n, err := strconv.ParseUint(req.URL.Query().Get("n"), 10, 64)
+		return err == nil && n >= threshold
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestForceSamplingLargeN(t *testing.T) {
+	const (
+		requests = 1000
+		ratio    = 0.1
+	)
+	tests := []struct {
+		name   string
+		target string
+		// want is the expected fraction of sampled requests.
+		want float64
+	}{
+		{name: "large n always sampled", target: "/fibonacci?n=40", want: 1},
+		{name: "at threshold", target: "/fibonacci?n=30", want: 1},
+		{name: "small n follows ratio", target: "/fibonacci?n=5", want: ratio},
+		{name: "invalid n follows ratio", target: "/fibonacci?n=abc", want: ratio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t, trace.WithSampler(trace.ParentBased(forceSampler{next: trace.TraceIDRatioBased(ratio)})))
+			m := newTestMiddleware(t)
+			m.forceSampling = map[string]func(*http.Request) bool{"/fibonacci": largeFibonacciN(30)}
+			handler := m.Handle("/fibonacci", http.NotFoundHandler())
+			for i := 0; i < requests; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+			}
+
+			got := float64(len(rec.Ended())) / requests
+			// Five standard deviations of the binomial distribution.
+			tolerance := 5 * math.Sqrt(tt.want*(1-tt.want)/requests)
+			if math.Abs(got-tt.want) > tolerance {
+				t.Errorf("sampled fraction = %.3f, want %.3f±%.3f", got, tt.want, tolerance)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cpuQuotaFunc reports the CPU quota of the process in cores, false when
+// there is none.
+type cpuQuotaFunc func() (float64, bool)
+
+// cgroupCPUQuota reads the CPU quota from cgroup v2's cpu.max, falling back
+// to cgroup v1's cfs quota and period. It assumes the conventional mount at
+// /sys/fs/cgroup with the process at the root of its namespace, as inside a
+// container.
+func cgroupCPUQuota() (float64, bool) {
+	if b, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) == 2 && fields[0] != "max" {
+			return cpuQuota(fields[0], fields[1])
+		}
+		return 0, false
+	}
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuQuota divides a cgroup quota by its period; a negative quota means
+// unlimited.
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// adjustGOMAXPROCS lowers GOMAXPROCS to the CPU quota reported by quota,
+// rounded down but at least 1, as the runtime sizes it by the host's CPUs
+// and a throttled container then loses time to descheduled threads. A
+// GOMAXPROCS environment variable wins, as does a quota above the current
+// value. It returns the value in effect and where it came from.
+func adjustGOMAXPROCS(quota cpuQuotaFunc) (int, string) {
+	current := runtime.GOMAXPROCS(0)
+	if os.Getenv("GOMAXPROCS") != "" {
+		return current, "GOMAXPROCS env"
+	}
+	cores, ok := quota()
+	if !ok {
+		return current, "runtime default"
+	}
+	procs := int(math.Max(1, math.Floor(cores)))
+	if procs >= current {
+		return current, "runtime default"
+	}
+	runtime.GOMAXPROCS(procs)
+	return procs, "CPU quota"
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestAdjustGOMAXPROCS(t *testing.T) {
+	const start = 8
+	tests := []struct {
+		name  string
+		env   string
+		quota cpuQuotaFunc
+		want  int
+		// wantSource is where the value in effect came from.
+		wantSource string
+	}{
+		{name: "no quota", quota: func() (float64, bool) { return 0, false }, want: start, wantSource: "runtime default"},
+		{name: "whole cores", quota: func() (float64, bool) { return 2, true }, want: 2, wantSource: "CPU quota"},
+		{name: "rounded down", quota: func() (float64, bool) { return 3.7, true }, want: 3, wantSource: "CPU quota"},
+		{name: "at least one", quota: func() (float64, bool) { return 0.5, true }, want: 1, wantSource: "CPU quota"},
+		{name: "quota above current", quota: func() (float64, bool) { return 16, true }, want: start, wantSource: "runtime default"},
+		{name: "env wins", env: "8", quota: func() (float64, bool) { return 2, true }, want: start, wantSource: "GOMAXPROCS env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOMAXPROCS", tt.env)
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(start))
+
+			got, source := adjustGOMAXPROCS(tt.quota)
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("adjustGOMAXPROCS = %d (%s), want %d (%s)", got, source, tt.want, tt.wantSource)
+			}
+			if procs := runtime.GOMAXPROCS(0); procs != tt.want {
+				t.Errorf("GOMAXPROCS = %d, want %d", procs, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUQuota(t *testing.T) {
+	tests := []struct {
+		quota, period string
+		want          float64
+		wantOK        bool
+	}{
+		{quota: "200000", period: "100000", want: 2, wantOK: true},
+		{quota: "150000", period: "100000", want: 1.5, wantOK: true},
+		{quota: "-1", period: "100000"},
+		{quota: "50000", period: "0"},
+		{quota: "lots", period: "100000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.quota+"/"+tt.period, func(t *testing.T) {
+			got, ok := cpuQuota(tt.quota, tt.period)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("cpuQuota(%s, %s) = %v, %v, want %v, %v", tt.quota, tt.period, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fibonacciService is the fib.Fibonacci gRPC service. It uses the protobuf
+// wrapper types as messages so no generated code is needed: the request is
+// n as a UInt64Value, the reply fib(n) in decimal as a StringValue.
+type fibonacciService interface {
+	Fibonacci(ctx context.Context, n *wrapperspb.UInt64Value) (*wrapperspb.StringValue, error)
+}
+
+var fibonacciServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fib.Fibonacci",
+	HandlerType: (*fibonacciService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Fibonacci",
+		Handler:    fibonacciRPCHandler,
+	}},
+	Metadata: "fibonacci.proto",
+}
+
+func fibonacciRPCHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.UInt64Value)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(fibonacciService).Fibonacci(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fib.Fibonacci/Fibonacci"}
+	return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(fibonacciService).Fibonacci(ctx, req.(*wrapperspb.UInt64Value))
+	})
+}
+
+// fibonacciGRPCServer computes fibonacci numbers for gRPC clients the same
+// way fibonacciHandler does for HTTP ones.
+type fibonacciGRPCServer struct {
+	mode   fibMode
+	budget time.Duration
+	cache  *resultCache
+	// memory rejects computations estimated to exceed its memory budget,
+	// nil disables it.
+	memory *memoryGuard
+}
+
+var _ fibonacciService = (*fibonacciGRPCServer)(nil)
+
+func (s *fibonacciGRPCServer) Fibonacci(ctx context.Context, req *wrapperspb.UInt64Value) (*wrapperspb.StringValue, error) {
+	key := cacheKey{mode: s.mode, n: req.GetValue()}
+	if ret, ok := s.cache.Get(key); ok {
+		return wrapperspb.String(ret), nil
+	}
+	if err := s.memory.Check(oteltrace.SpanFromContext(ctx), s.mode, key.n); err != nil {
+		return nil, status.Error(computeErrorCode(err), err.Error())
+	}
+	ret, err := computeFibonacci(withComputeBudget(ctx, s.budget), s.mode, key.n)
+	if err != nil {
+		return nil, status.Error(computeErrorCode(err), err.Error())
+	}
+	s.cache.Add(ctx, key, ret)
+	return wrapperspb.String(ret), nil
+}
+
+// computeErrorCode is computeErrorStatus for gRPC.
+func computeErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, errFibonacciOverflow), errors.Is(err, errRecursionTooDeep), errors.Is(err, errMemoryBudget):
+		return codes.InvalidArgument
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	default:
+		return codes.Unavailable
+	}
+}
+
+// newGRPCServer returns a gRPC server offering fib.Fibonacci, traced with
+// the global tracer provider and propagator. It uses otelgrpc's unary
+// interceptor: the stats handler (otelgrpc.NewServerHandler) only exists
+// from otelgrpc v0.45.0, which needs otel v1.19 and the stable metric API
+// this module hasn't moved to yet. Switch once it has; the interceptor
+// traces the same unary RPCs.
+func newGRPCServer(srv *fibonacciGRPCServer) *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()))
+	s.RegisterService(&fibonacciServiceDesc, srv)
+	return s
+}
+
+// stopGRPCServer stops s gracefully, cutting remaining RPCs off when ctx
+// ends first.
+func stopGRPCServer(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGRPCFibonacci(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        uint64
+		want     string
+		wantCode codes.Code
+	}{
+		{name: "result", n: 10, want: "55", wantCode: codes.OK},
+		{name: "overflow", n: 94, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			useTestPropagator(t, propagation.TraceContext{})
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := newGRPCServer(&fibonacciGRPCServer{mode: fibModeIter})
+			go srv.Serve(lis)
+			defer srv.Stop()
+
+			conn, err := grpc.Dial(lis.Addr().String(),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			ctx, parent := tracer("test").Start(context.Background(), "caller")
+			out := new(wrapperspb.StringValue)
+			err = conn.Invoke(ctx, "/fib.Fibonacci/Fibonacci", wrapperspb.UInt64(tt.n), out)
+			parent.End()
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", got, tt.wantCode, err)
+			}
+			if out.GetValue() != tt.want {
+				t.Errorf("result = %q, want %q", out.GetValue(), tt.want)
+			}
+
+			var client, server oteltrace.SpanContext
+			var serverParent oteltrace.SpanContext
+			for _, s := range rec.Ended() {
+				switch s.SpanKind() {
+				case oteltrace.SpanKindClient:
+					client = s.SpanContext()
+				case oteltrace.SpanKindServer:
+					server, serverParent = s.SpanContext(), s.Parent()
+				}
+			}
+			if !server.IsValid() {
+				t.Fatal("no server span")
+			}
+			if server.TraceID() != parent.SpanContext().TraceID() {
+				t.Errorf("server span trace = %s, want the caller's %s", server.TraceID(), parent.SpanContext().TraceID())
+			}
+			if !serverParent.IsRemote() || serverParent.SpanID() != client.SpanID() {
+				t.Errorf("server span parent = %s (remote %v), want client span %s", serverParent.SpanID(), serverParent.IsRemote(), client.SpanID())
+			}
+		})
+	}
+}
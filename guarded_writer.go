@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errWriterDisabled is returned once a guardedWriter has given up.
+var errWriterDisabled = errors.New("trace writer disabled after repeated write failures")
+
+// guardedWriter reports failed and short writes (e.g. a full disk) instead
+// of letting them pass silently, and stops writing altogether after
+// maxFailures consecutive failures so a broken disk does not turn into a
+// tight error loop.
+type guardedWriter struct {
+	w           io.Writer
+	maxFailures int
+	errCount    prometheus.Counter
+
+	mu       sync.Mutex
+	failures int
+	disabled bool
+}
+
+func newGuardedWriter(w io.Writer, maxFailures int, errCount prometheus.Counter) *guardedWriter {
+	return &guardedWriter{w: w, maxFailures: maxFailures, errCount: errCount}
+}
+
+func (g *guardedWriter) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.disabled {
+		return 0, errWriterDisabled
+	}
+
+	n, err := g.w.Write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err == nil {
+		g.failures = 0
+		return n, nil
+	}
+
+	g.errCount.Inc()
+	g.failures++
+	log.Printf("trace write failed (%d/%d bytes written): %v", n, len(p), err)
+	if g.failures >= g.maxFailures {
+		g.disabled = true
+		log.Printf("disabling trace writes after %d consecutive failures", g.failures)
+	}
+	return n, err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyWriter plays back results: 's' writes half of p, 'e' fails, anything
+// else writes all of p. It writes all of p once results run out.
+type flakyWriter struct {
+	results string
+	buf     bytes.Buffer
+}
+
+var errDiskFull = errors.New("no space left on device")
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	var r byte
+	if w.results != "" {
+		r, w.results = w.results[0], w.results[1:]
+	}
+	switch r {
+	case 's':
+		return w.buf.Write(p[:len(p)/2])
+	case 'e':
+		return 0, errDiskFull
+	}
+	return w.buf.Write(p)
+}
+
+// captureLog redirects the standard logger until the test finishes.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func TestGuardedWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     string
+		writes      int
+		maxFailures int
+		wantErrs    []error
+		wantCount   float64
+		wantLog     string
+	}{
+		{
+			name: "ok", writes: 2, maxFailures: 2,
+			wantErrs: []error{nil, nil},
+		},
+		{
+			name: "short write", results: "s.", writes: 2, maxFailures: 2,
+			wantErrs:  []error{io.ErrShortWrite, nil},
+			wantCount: 1, wantLog: "trace write failed (2/4 bytes written): short write",
+		},
+		{
+			name: "failure resets after success", results: "e.e", writes: 3, maxFailures: 2,
+			wantErrs:  []error{errDiskFull, nil, errDiskFull},
+			wantCount: 2, wantLog: "no space left on device",
+		},
+		{
+			name: "disabled after repeated failures", results: "se", writes: 4, maxFailures: 2,
+			wantErrs:  []error{io.ErrShortWrite, errDiskFull, errWriterDisabled, errWriterDisabled},
+			wantCount: 2, wantLog: "disabling trace writes after 2 consecutive failures",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_write_errors"})
+			w := newGuardedWriter(&flakyWriter{results: tt.results}, tt.maxFailures, counter)
+			for i := 0; i < tt.writes; i++ {
+				if _, err := w.Write([]byte("span")); err != tt.wantErrs[i] {
+					t.Errorf("write %d: err = %v, want %v", i, err, tt.wantErrs[i])
+				}
+			}
+			if got := testutil.ToFloat64(counter); got != tt.wantCount {
+				t.Errorf("error count = %v, want %v", got, tt.wantCount)
+			}
+			if !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log %q does not contain %q", logs, tt.wantLog)
+			}
+		})
+	}
+}
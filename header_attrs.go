@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sensitiveHeaders are never copied onto spans, even when listed in
+// SPAN_HEADER_ATTRS.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// parseHeaderAttrs canonicalizes the header names to record on spans,
+// dropping sensitive ones with a warning.
+func parseHeaderAttrs(names []string) []string {
+	var out []string
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		if sensitiveHeaders[name] {
+			log.Printf("not recording sensitive header %s on spans", name)
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// headerAttributes returns the http.request.header.<name> attributes for
+// those of names present on req, with all of each header's values.
+func headerAttributes(req *http.Request, names []string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, name := range names {
+		if values := req.Header.Values(name); len(values) > 0 {
+			key := "http.request.header." + strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+			attrs = append(attrs, attribute.StringSlice(key, values))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestParseHeaderAttrs(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+		// wantLog is logged for a dropped header; empty means nothing is.
+		wantLog string
+	}{
+		{name: "empty", names: nil, want: nil},
+		{name: "canonicalized", names: []string{"x-tenant-id", "X-REQUEST-ID"}, want: []string{"X-Tenant-Id", "X-Request-Id"}},
+		{name: "authorization blocked", names: []string{"X-Tenant-ID", "authorization"}, want: []string{"X-Tenant-Id"}, wantLog: "Authorization"},
+		{name: "cookie blocked", names: []string{"Cookie"}, want: nil, wantLog: "Cookie"},
+		{name: "api key blocked", names: []string{"x-api-key", "Accept"}, want: []string{"Accept"}, wantLog: "X-Api-Key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged := captureLog(t)
+			if got := parseHeaderAttrs(tt.names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHeaderAttrs(%q) = %q, want %q", tt.names, got, tt.want)
+			}
+			if tt.wantLog == "" && logged.Len() != 0 {
+				t.Errorf("unexpected log: %s", logged)
+			}
+			if tt.wantLog != "" && !strings.Contains(logged.String(), tt.wantLog) {
+				t.Errorf("log = %q, want a warning about %s", logged, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestTracingMiddlewareHeaderAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config []string
+		header http.Header
+		want   map[attribute.Key][]string
+		// absent must not be recorded.
+		absent []attribute.Key
+	}{
+		{
+			name:   "none configured",
+			header: http.Header{"X-Tenant-Id": {"acme"}},
+			absent: []attribute.Key{"http.request.header.x_tenant_id"},
+		},
+		{
+			name:   "allowed header",
+			config: []string{"X-Tenant-ID"},
+			header: http.Header{"X-Tenant-Id": {"acme"}, "X-Other": {"x"}},
+			want:   map[attribute.Key][]string{"http.request.header.x_tenant_id": {"acme"}},
+			absent: []attribute.Key{"http.request.header.x_other"},
+		},
+		{
+			name:   "repeated values",
+			config: []string{"accept"},
+			header: http.Header{"Accept": {"text/plain", "application/json"}},
+			want:   map[attribute.Key][]string{"http.request.header.accept": {"text/plain", "application/json"}},
+		},
+		{
+			name:   "configured but missing",
+			config: []string{"X-Tenant-ID"},
+			header: http.Header{},
+			absent: []attribute.Key{"http.request.header.x_tenant_id"},
+		},
+		{
+			name:   "sensitive headers blocked",
+			config: []string{"X-Tenant-ID", "Authorization", "Cookie"},
+			header: http.Header{
+				"X-Tenant-Id":   {"acme"},
+				"Authorization": {"Bearer secret"},
+				"Cookie":        {"session=secret"},
+			},
+			want: map[attribute.Key][]string{"http.request.header.x_tenant_id": {"acme"}},
+			absent: []attribute.Key{
+				"http.request.header.authorization",
+				"http.request.header.cookie",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.headers = parseHeaderAttrs(tt.config)
+
+			req := httptest.NewRequest(http.MethodGet, "/fibonacci", nil)
+			req.Header = tt.header
+			m.Handle("/fibonacci", http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			got := map[attribute.Key][]string{}
+			for _, kv := range ended[0].Attributes() {
+				if strings.HasPrefix(string(kv.Key), "http.request.header.") {
+					got[kv.Key] = kv.Value.AsStringSlice()
+				}
+			}
+			for key, want := range tt.want {
+				if !reflect.DeepEqual(got[key], want) {
+					t.Errorf("%s = %q, want %q", key, got[key], want)
+				}
+			}
+			for _, key := range tt.absent {
+				if v, ok := got[key]; ok {
+					t.Errorf("%s = %q, want it not recorded", key, v)
+				}
+			}
+		})
+	}
+}
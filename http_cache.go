@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// fibCacheControl lets HTTP caches keep fibonacci results forever: fib(n)
+// never changes for a given mode.
+const fibCacheControl = "public, max-age=31536000, immutable"
+
+// fibETag is the entity tag of the result of fib(n) in mode.
+func fibETag(mode fibMode, n uint64) string {
+	return `"` + string(mode) + "-" + strconv.FormatUint(n, 10) + `"`
+}
+
+// setCacheHeaders marks the response as the cacheable representation etag.
+func setCacheHeaders(resp http.ResponseWriter, etag string) {
+	resp.Header().Set("Cache-Control", fibCacheControl)
+	resp.Header().Set("ETag", etag)
+}
+
+// notModified answers req with 304 Not Modified when its If-None-Match
+// already holds etag, recording the validation hit on span. It returns
+// false, doing nothing, otherwise. Callers must only pass the etag of a
+// result that would be served with 200.
+func notModified(resp http.ResponseWriter, req *http.Request, span oteltrace.Span, etag string) bool {
+	if !etagMatches(req.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	span.AddEvent("http.cache.not_modified", oteltrace.WithAttributes(
+		attrKey("http.etag").String(etag),
+	))
+	setCacheHeaders(resp, etag)
+	resp.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether the If-None-Match header value header lists
+// etag, using the weak comparison RFC 9110 prescribes for it. "*" is not
+// honored: the result is computed on demand, there is no stored
+// representation it could refer to.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
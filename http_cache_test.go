@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := fibETag(fibModeIter, 10)
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: etag, want: true},
+		{header: "W/" + etag, want: true},
+		{header: `"other", ` + etag, want: true},
+		{header: `"iter-11"`, want: false},
+		{header: `"recursive-10"`, want: false},
+		{header: "iter-10", want: false},
+		{header: "*", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := etagMatches(tt.header, etag); got != tt.want {
+				t.Errorf("etagMatches(%q, %s) = %v, want %v", tt.header, etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFibonacciHandlerNotModified(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		ifNoneMatch string
+		wantStatus  int
+		wantETag    string
+		// wantEvent is whether the validation hit is recorded on the span.
+		wantEvent bool
+	}{
+		{name: "no validator", target: "/fibonacci?n=10", wantStatus: http.StatusOK, wantETag: `"iter-10"`},
+		{name: "matching etag", target: "/fibonacci?n=10", ifNoneMatch: `"iter-10"`, wantStatus: http.StatusNotModified, wantETag: `"iter-10"`, wantEvent: true},
+		{name: "weak etag", target: "/fibonacci?n=10", ifNoneMatch: `W/"iter-10"`, wantStatus: http.StatusNotModified, wantETag: `"iter-10"`, wantEvent: true},
+		{name: "other n", target: "/fibonacci?n=10", ifNoneMatch: `"iter-11"`, wantStatus: http.StatusOK, wantETag: `"iter-10"`},
+		{name: "other mode", target: "/fibonacci?n=10&mode=memo", ifNoneMatch: `"iter-10"`, wantStatus: http.StatusOK, wantETag: `"memo-10"`},
+		{name: "wildcard", target: "/fibonacci?n=10", ifNoneMatch: "*", wantStatus: http.StatusOK, wantETag: `"iter-10"`},
+		{name: "invalid n not revalidated", target: "/fibonacci?n=100", ifNoneMatch: `"iter-100"`, wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			ctx, span := tracer("test").Start(context.Background(), "/fibonacci")
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil).WithContext(ctx)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			resp := httptest.NewRecorder()
+			newTestFibonacciHandler().ServeHTTP(resp, req)
+			span.End()
+
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if got := resp.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			if tt.wantETag != "" && resp.Header().Get("Cache-Control") != fibCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", resp.Header().Get("Cache-Control"), fibCacheControl)
+			}
+			if resp.Code == http.StatusNotModified && resp.Body.Len() != 0 {
+				t.Errorf("304 with body %q", resp.Body)
+			}
+
+			var event bool
+			for _, s := range rec.Ended() {
+				for _, ev := range s.Events() {
+					event = event || ev.Name == "http.cache.not_modified"
+				}
+			}
+			if event != tt.wantEvent {
+				t.Errorf("not_modified event = %v, want %v", event, tt.wantEvent)
+			}
+		})
+	}
+}
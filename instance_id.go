@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+var (
+	instanceIDOnce sync.Once
+	instanceID     string
+)
+
+// serviceInstanceID returns SERVICE_INSTANCE_ID, or a random UUID generated
+// on first use and kept for the life of the process, so every resource
+// built by this process names the same instance.
+func serviceInstanceID() string {
+	instanceIDOnce.Do(func() {
+		if instanceID = os.Getenv("SERVICE_INSTANCE_ID"); instanceID != "" {
+			return
+		}
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			log.Fatalf("generate service instance id: %v", err)
+		}
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		instanceID = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	})
+	return instanceID
+}
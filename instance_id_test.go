@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// resetInstanceID forgets the process instance id until the test finishes,
+// so the next serviceInstanceID call reads the environment again.
+func resetInstanceID(t *testing.T) {
+	t.Helper()
+	prev := instanceID
+	instanceIDOnce, instanceID = sync.Once{}, ""
+	t.Cleanup(func() {
+		instanceIDOnce, instanceID = sync.Once{}, prev
+		if prev != "" {
+			instanceIDOnce.Do(func() {})
+		}
+	})
+}
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestServiceInstanceID(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		// want is the expected id; empty means a generated UUID.
+		want string
+	}{
+		{name: "configured", env: "pod-7f9c", want: "pod-7f9c"},
+		{name: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SERVICE_INSTANCE_ID", tt.env)
+			resetInstanceID(t)
+			rec := useTestTracerProvider(t, trace.WithResource(newResource()))
+			for i := 0; i < 3; i++ {
+				_, span := tracer("test").Start(context.Background(), "span")
+				span.End()
+			}
+			// A resource built later in the same process names the same
+			// instance.
+			later, _ := newResource().Set().Value(semconv.ServiceInstanceIDKey)
+
+			ended := rec.Ended()
+			first, ok := ended[0].Resource().Set().Value(semconv.ServiceInstanceIDKey)
+			if !ok {
+				t.Fatalf("resource has no %s: %v", semconv.ServiceInstanceIDKey, ended[0].Resource())
+			}
+			id := first.AsString()
+			if tt.want != "" && id != tt.want {
+				t.Errorf("%s = %q, want %q", semconv.ServiceInstanceIDKey, id, tt.want)
+			}
+			if tt.want == "" && !uuidV4.MatchString(id) {
+				t.Errorf("%s = %q, want a version 4 UUID", semconv.ServiceInstanceIDKey, id)
+			}
+			for _, s := range ended[1:] {
+				if v, _ := s.Resource().Set().Value(semconv.ServiceInstanceIDKey); v.AsString() != id {
+					t.Errorf("span has %s %q, want %q", semconv.ServiceInstanceIDKey, v.AsString(), id)
+				}
+			}
+			if later.AsString() != id {
+				t.Errorf("later resource has %s %q, want %q", semconv.ServiceInstanceIDKey, later.AsString(), id)
+			}
+		})
+	}
+}
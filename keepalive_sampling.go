@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type connRequestsKey struct{}
+
+// connRequests counts the traced requests served on one connection.
+type connRequests struct {
+	n atomic.Uint64
+}
+
+// withConnRequests is an http.Server ConnContext giving every connection
+// its own request count, see reusedConnection.
+func withConnRequests(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connRequestsKey{}, &connRequests{})
+}
+
+// reusedConnection counts req on its connection and reports whether an
+// earlier request already came over it, i.e. the connection was kept alive.
+// It is always false for servers without withConnRequests.
+func reusedConnection(req *http.Request) bool {
+	c, ok := req.Context().Value(connRequestsKey{}).(*connRequests)
+	return ok && c.n.Add(1) > 1
+}
+
+type keepAliveKey struct{}
+
+// withKeepAlive marks ctx as serving a keep-alive style request, too small
+// to be real work.
+func withKeepAlive(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keepAliveKey{}, true)
+}
+
+// isKeepAliveRequest reports whether req came over a reused connection and
+// its request URI and body together take at most maxBytes. How long it will
+// take is unknown before the root span starts, so size is all there is to
+// go by.
+func isKeepAliveRequest(req *http.Request, maxBytes int64) bool {
+	if !reusedConnection(req) || req.ContentLength < 0 {
+		return false
+	}
+	return int64(len(req.RequestURI))+req.ContentLength <= maxBytes
+}
+
+// keepAliveSampler drops root spans of requests marked withKeepAlive,
+// counting them as sampling_skipped="keepalive". Requests continuing a
+// remote trace follow their parent as usual. Everything else goes to next.
+type keepAliveSampler struct {
+	next trace.Sampler
+}
+
+var _ trace.Sampler = keepAliveSampler{}
+
+func (s keepAliveSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if p.ParentContext != nil && p.ParentContext.Value(keepAliveKey{}) != nil &&
+		!oteltrace.SpanContextFromContext(p.ParentContext).IsValid() {
+		spansSkipped.WithLabelValues("keepalive").Inc()
+		return trace.SamplingResult{
+			Decision:   trace.Drop,
+			Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s keepAliveSampler) Description() string {
+	return s.next.Description()
+}
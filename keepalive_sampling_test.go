@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestKeepAliveSampling(t *testing.T) {
+	const maxBytes = 64
+	tiny := keepAliveRequest{target: "/fibonacci?n=1"}
+	large := keepAliveRequest{target: "/fibonacci?n=1&pad=" + strings.Repeat("x", 100)}
+	withBody := keepAliveRequest{target: "/fibonacci?n=1", body: strings.Repeat("x", 100)}
+	withParent := keepAliveRequest{target: "/fibonacci?n=1", traceparent: "00-" + testTraceID + "-" + testSpanID + "-01"}
+	tests := []struct {
+		name     string
+		maxBytes int64
+		// requests are sent in order over one kept alive connection.
+		requests   []keepAliveRequest
+		wantTraced []bool
+	}{
+		{name: "first request traced", maxBytes: maxBytes, requests: []keepAliveRequest{tiny}, wantTraced: []bool{true}},
+		{
+			name:       "tiny reused requests suppressed",
+			maxBytes:   maxBytes,
+			requests:   []keepAliveRequest{tiny, tiny, tiny},
+			wantTraced: []bool{true, false, false},
+		},
+		{
+			name:       "large request URI traced",
+			maxBytes:   maxBytes,
+			requests:   []keepAliveRequest{tiny, large},
+			wantTraced: []bool{true, true},
+		},
+		{
+			name:       "request body counts",
+			maxBytes:   maxBytes,
+			requests:   []keepAliveRequest{tiny, withBody},
+			wantTraced: []bool{true, true},
+		},
+		{
+			name:       "remote parent followed",
+			maxBytes:   maxBytes,
+			requests:   []keepAliveRequest{tiny, withParent},
+			wantTraced: []bool{true, true},
+		},
+		{
+			name:       "disabled",
+			requests:   []keepAliveRequest{tiny, tiny, tiny},
+			wantTraced: []bool{true, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t, trace.WithSampler(keepAliveSampler{next: trace.ParentBased(trace.AlwaysSample())}))
+			prop, err := newPropagator([]string{"tracecontext"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTestPropagator(t, prop)
+			m := newTestMiddleware(t)
+			m.keepAliveMaxBytes = tt.maxBytes
+			handler := m.Handle("/fibonacci", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				io.Copy(io.Discard, req.Body)
+			}))
+
+			srv := newHTTPServer("127.0.0.1:0", handler)
+			lis, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go srv.Serve(lis)
+			defer srv.Close()
+
+			var conns int
+			client := &http.Client{Transport: &http.Transport{
+				MaxIdleConnsPerHost: 1,
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					conns++
+					return (&net.Dialer{}).DialContext(ctx, network, addr)
+				},
+			}}
+			for i, r := range tt.requests {
+				before := len(rec.Ended())
+				r.send(t, client, "http://"+lis.Addr().String())
+				if traced := len(rec.Ended()) > before; traced != tt.wantTraced[i] {
+					t.Errorf("request %d (%s): traced = %v, want %v", i, r.target, traced, tt.wantTraced[i])
+				}
+			}
+			if conns != 1 {
+				t.Errorf("requests used %d connections, want 1", conns)
+			}
+		})
+	}
+}
+
+// keepAliveRequest is a request sent by TestKeepAliveSampling.
+type keepAliveRequest struct {
+	target      string
+	body        string
+	traceparent string
+}
+
+// send sends r to base with client, reading the whole response so the
+// connection can be reused.
+func (r keepAliveRequest) send(t *testing.T, client *http.Client, base string) {
+	t.Helper()
+	method, body := http.MethodGet, io.Reader(nil)
+	if r.body != "" {
+		method, body = http.MethodPost, strings.NewReader(r.body)
+	}
+	req, err := http.NewRequest(method, base+r.target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.traceparent != "" {
+		req.Header.Set("traceparent", r.traceparent)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
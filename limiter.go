@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// concurrencyLimiter caps the number of requests handled at once. Requests
+// over the limit wait for a slot; the time they wait is observed per route
+// so queueing shows apart from processing latency.
+type concurrencyLimiter struct {
+	slots chan struct{}
+	wait  *prometheus.HistogramVec
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		slots: make(chan struct{}, limit),
+		wait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_queue_duration_seconds",
+			Help:    "Time requests waited for a concurrency slot before being handled.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+	}
+}
+
+// Acquire waits for a free slot, or for ctx to end. It returns how long it
+// waited and, on success, the function releasing the slot.
+func (l *concurrencyLimiter) Acquire(ctx context.Context, route string) (release func(), waited time.Duration, err error) {
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		waited = time.Since(start)
+		l.wait.WithLabelValues(route).Observe(waited.Seconds())
+		return func() { <-l.slots }, waited, nil
+	case <-ctx.Done():
+		waited = time.Since(start)
+		l.wait.WithLabelValues(route).Observe(waited.Seconds())
+		return nil, waited, ctx.Err()
+	}
+}
+
+// acquire takes a concurrency slot for a request to route when a limiter is
+// configured, recording the wait on span as http.server.queue_duration in
+// seconds. When the request gives up first it answers 503 and returns false.
+func (m *tracingMiddleware) acquire(ctx context.Context, span oteltrace.Span, route string, resp http.ResponseWriter) (release func(), ok bool) {
+	if m.limiter == nil {
+		return func() {}, true
+	}
+	release, waited, err := m.limiter.Acquire(ctx, route)
+	span.SetAttributes(attribute.Float64("http.server.queue_duration", waited.Seconds()))
+	if err != nil {
+		span.RecordError(err)
+		resp.WriteHeader(http.StatusServiceUnavailable)
+		resp.Write([]byte("gave up waiting for a free slot"))
+		return nil, false
+	}
+	return release, true
+}
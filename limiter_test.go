@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestConcurrencyLimiterQueueDuration(t *testing.T) {
+	const hold = 50 * time.Millisecond
+	tests := []struct {
+		name     string
+		limit    int
+		requests int
+		// wantQueued is the number of requests that wait about hold for
+		// a slot.
+		wantQueued int
+	}{
+		{name: "under the limit", limit: 3, requests: 3, wantQueued: 0},
+		{name: "one over", limit: 2, requests: 3, wantQueued: 1},
+		{name: "saturated", limit: 1, requests: 3, wantQueued: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.limiter = newConcurrencyLimiter(tt.limit)
+			entered := make(chan struct{}, tt.requests)
+			release := make(chan struct{})
+			h := m.Handle("/fibonacci", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				entered <- struct{}{}
+				<-release
+			}))
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fibonacci", nil))
+				}()
+			}
+			// Let every slot fill and the rest queue behind them, then
+			// hold the slots a while before letting them all through.
+			for i := 0; i < tt.limit; i++ {
+				<-entered
+			}
+			time.Sleep(hold)
+			close(release)
+			wg.Wait()
+
+			var queued int
+			for _, s := range rec.Ended() {
+				v := spanAttr(s, "http.server.queue_duration")
+				waited, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					t.Fatalf("http.server.queue_duration = %q: %v", v, err)
+				}
+				if waited >= (hold / 2).Seconds() {
+					queued++
+				}
+			}
+			if queued != tt.wantQueued {
+				t.Errorf("%d requests queued, want %d", queued, tt.wantQueued)
+			}
+
+			var metric dto.Metric
+			if err := m.limiter.wait.WithLabelValues("/fibonacci").(prometheus.Metric).Write(&metric); err != nil {
+				t.Fatal(err)
+			}
+			if got := metric.GetHistogram().GetSampleCount(); got != uint64(tt.requests) {
+				t.Errorf("histogram has %d samples, want %d", got, tt.requests)
+			}
+			if sum := metric.GetHistogram().GetSampleSum(); tt.wantQueued > 0 && sum < float64(tt.wantQueued)*(hold/2).Seconds() {
+				t.Errorf("histogram sum = %gs, want at least the queued time", sum)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiterGiveUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// holdFor is how long the only slot stays taken.
+		holdFor    time.Duration
+		wantStatus int
+	}{
+		{name: "slot frees in time", timeout: time.Second, holdFor: 20 * time.Millisecond, wantStatus: http.StatusNotFound},
+		{name: "gives up while queued", timeout: 20 * time.Millisecond, holdFor: time.Second, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.limiter = newConcurrencyLimiter(1)
+			release, _, err := m.limiter.Acquire(context.Background(), "/fibonacci")
+			if err != nil {
+				t.Fatal(err)
+			}
+			timer := time.AfterFunc(tt.holdFor, release)
+			defer func() {
+				if timer.Stop() {
+					release()
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			resp := httptest.NewRecorder()
+			m.Handle("/fibonacci", http.NotFoundHandler()).
+				ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/fibonacci", nil).WithContext(ctx))
+
+			if resp.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.Code, tt.wantStatus)
+			}
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			wantWait := tt.timeout
+			if tt.holdFor < wantWait {
+				wantWait = tt.holdFor
+			}
+			if waited, _ := strconv.ParseFloat(spanAttr(ended[0], "http.server.queue_duration"), 64); waited < wantWait.Seconds()/2 {
+				t.Errorf("http.server.queue_duration = %gs, want about %s", waited, wantWait)
+			}
+			if got := testutil.CollectAndCount(m.limiter.wait); got != 1 {
+				t.Errorf("histogram has %d series, want 1", got)
+			}
+		})
+	}
+}
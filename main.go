@@ -2,34 +2,53 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
 )
 
 // newResource returns a resource describing this application.
+//
+// RESOURCE_SCHEMA_URL overrides the schema URL, "none" drops it. The
+// attributes of resource.Default() are re-labelled with the chosen schema
+// rather than merged, since resource.Merge fails (and returns an empty
+// resource) when the two schema URLs differ. service.instance.id tells
+// replicas apart, see serviceInstanceID.
 func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName("fib"),
-		),
+	schemaURL := envString("RESOURCE_SCHEMA_URL", semconv.SchemaURL)
+	if schemaURL == "none" {
+		schemaURL = ""
+	}
+	attrs := append(resource.Default().Attributes(),
+		semconv.ServiceName("fib"),
+		semconv.ServiceInstanceID(serviceInstanceID()),
 	)
-	return r
+	return resource.NewWithAttributes(schemaURL, attrs...)
 }
 
 // newExporter returns a console exporter.
@@ -43,80 +62,266 @@ func newExporter(w io.Writer) (trace.SpanExporter, error) {
 	)
 }
 
-func fibonacci(ctx context.Context, n uint64) uint64 {
-	spanName := fmt.Sprintf("fibonacci-%d", n)
-	ctx, span := otel.Tracer("fibonacci").Start(ctx, spanName)
+// fibonacci computes fib(n) recursively, one span per call. depth is the
+// recursion depth of this call, 1 for the top level one.
+func fibonacci(ctx context.Context, n, depth uint64) (uint64, error) {
+	ctx, span := startFibSpan(ctx, fibModeRecursive, n)
+	countCall(ctx)
 
-	span.SetAttributes(attribute.KeyValue{
-		Key: "timestamp", Value: attribute.Int64Value(time.Now().UnixNano()),
+	span.SetOptional(attribute.KeyValue{
+		Key: attrKey("timestamp"), Value: attribute.Int64Value(time.Now().UnixNano()),
 	})
+	if fibMaxDepth > 0 && depth > fibMaxDepth {
+		err := fmt.Errorf("%w: limit is %d", errRecursionTooDeep, fibMaxDepth)
+		recordComputeError(span, err)
+		span.End()
+		return 0, err
+	}
+	if err := checkComputeBudget(ctx); err != nil {
+		recordComputeError(span, err)
+		span.End()
+		return 0, err
+	}
 	if n <= 1 {
 		span.End()
-		return n
+		return n, nil
+	}
+	if fibCrossover > 0 {
+		if n < fibCrossover {
+			span.SetOptional(attrKey("fib.strategy").String("iterative"))
+			span.End()
+			return fibUint64(n), nil
+		}
+		span.SetOptional(attrKey("fib.strategy").String("recursive"))
 	}
 	span.End()
 
-	// error
-	//span.RecordError(err)
-	//span.SetStatus(codes.Error, err)
-
-	return fibonacci(ctx, n-1) + fibonacci(ctx, n-2)
+	a, err := fibonacci(ctx, n-1, depth+1)
+	if err != nil {
+		return 0, err
+	}
+	b, err := fibonacci(ctx, n-2, depth+1)
+	if err != nil {
+		return 0, err
+	}
+	return a + b, nil
 }
 
 type nestedSpanHandler struct{}
 
 func (s *nestedSpanHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	ctx, span := otel.Tracer("nested").Start(req.Context(), "parent")
+	ctx, span := tracer("nested").Start(req.Context(), "parent")
 	defer span.End()
 
 	span.AddEvent("parent event")
 
 	span.SetAttributes(attribute.KeyValue{
-		Key: "parentId", Value: attribute.Int64Value(time.Now().UnixNano()),
+		Key: attrKey("parentId"), Value: attribute.Int64Value(time.Now().UnixNano()),
 	})
 
 	func(ctx context.Context) {
-		ctx, span := otel.Tracer("nested").Start(ctx, "child")
+		ctx, span := tracer("nested").Start(ctx, "child")
 		defer span.End()
 
 		span.SetAttributes(attribute.KeyValue{
-			Key: "childId", Value: attribute.Int64Value(time.Now().UnixNano()),
+			Key: attrKey("childId"), Value: attribute.Int64Value(time.Now().UnixNano()),
 		})
 	}(ctx)
 }
 
-type fibonacciHandler struct{}
+type fibonacciHandler struct {
+	// defaultMode is used when the request has no mode query param.
+	defaultMode fibMode
+	// calls observes the number of fibonacci invocations per request.
+	calls prometheus.Observer
+	// budget caps the wall-clock time of one computation, 0 disables it.
+	budget time.Duration
+	// overflow flags n close to overflowing uint64.
+	overflow *overflowWatch
+	// cache holds recent results, nil when caching is disabled.
+	cache *resultCache
+	// flights coalesces concurrent identical computations, nil when
+	// coalescing is disabled.
+	flights *singleflight.Group
+	// requests counts requests by mode and response status. Requests with
+	// an unknown mode are counted as mode "invalid".
+	requests *prometheus.CounterVec
+	// memory rejects computations estimated to exceed its memory budget,
+	// nil disables it.
+	memory *memoryGuard
+	// summary adds a summary event with the request's totals to the root
+	// span. Requests sharing a coalesced computation report zero calls.
+	summary bool
+	// cost meters the calls each request made per tenant.
+	cost *costMeter
+}
 
 func (s *fibonacciHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	n := req.URL.Query().Get("n")
-	nCount, err := strconv.ParseInt(n, 10, 64)
+	rec := &statusRecorder{ResponseWriter: resp}
+	resp = rec
+	modeLabel := "invalid"
+	defer func() {
+		s.requests.WithLabelValues(modeLabel, strconv.Itoa(rec.Status())).Inc()
+	}()
+
+	span := oteltrace.SpanFromContext(req.Context())
+	query, err := parseSingleValueQuery(req, span, "n", "mode")
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+	mode := s.defaultMode
+	if m := query.Get("mode"); m != "" {
+		if mode, err = parseFibMode(m); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte(err.Error()))
+			return
+		}
+	}
+	modeLabel = string(mode)
+	n := query.Get("n")
+	nCount, err := strconv.ParseUint(n, 10, 64)
 	if err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
-		resp.Write([]byte("n is not a number"))
+		resp.Write([]byte("n must be a non-negative integer"))
+		return
+	}
+	span.SetAttributes(attrKey("fib.mode").String(string(mode)))
+	s.overflow.Check(span, mode, nCount)
+	// Only results that would be served can be revalidated, an invalid n
+	// must fail the same with or without If-None-Match.
+	etag := fibETag(mode, nCount)
+	if checkFibonacci(mode, nCount) == nil && !s.memory.Exceeds(mode, nCount) && notModified(resp, req, span, etag) {
 		return
 	}
-	ret := fibonacci(req.Context(), uint64(nCount))
+
+	ctx, counter := withCallCounter(req.Context())
+	ctx = withComputeBudget(ctx, s.budget)
+	if deadline, ok := requestDeadline(req); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	key := cacheKey{mode: mode, n: nCount}
+	ret, cached := s.cache.Get(key)
+	if s.cache != nil {
+		span.SetAttributes(attrKey("fib.cache_hit").Bool(cached))
+	}
+	if !cached {
+		if s.memory.Reject(resp, span, mode, nCount) {
+			return
+		}
+		var coalesced bool
+		ret, coalesced, err = s.compute(ctx, key, counter)
+		if s.flights != nil {
+			span.SetAttributes(attrKey("fib.coalesced").Bool(coalesced))
+		}
+	}
+	s.cost.Record(req.Context(), span, counter.Calls())
+	if err != nil {
+		recordComputeError(span, err)
+		if errors.Is(err, errFibonacciOverflow) && s.overflow.WritePartial(resp, span, nCount) {
+			return
+		}
+		resp.WriteHeader(computeErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+	if s.summary {
+		span.AddEvent("summary", oteltrace.WithAttributes(
+			attrKey("fib.child_spans").Int64(int64(counter.Spans())),
+			attrKey("fib.calls").Int64(int64(counter.Calls())),
+			attrKey("fib.result").String(ret),
+		))
+	}
+	setCacheHeaders(resp, etag)
 	resp.WriteHeader(http.StatusOK)
-	resp.Write([]byte(strconv.FormatUint(ret, 10)))
+	resp.Write([]byte(ret))
+}
+
+// compute runs the computation for key and caches its result. With
+// coalescing enabled, requests arriving while the same key is being
+// computed wait for and share that result; coalesced reports whether this
+// request was one of them. The shared computation runs with the first
+// request's context, including its deadline and trace.
+func (s *fibonacciHandler) compute(ctx context.Context, key cacheKey, counter *callCounter) (ret string, coalesced bool, err error) {
+	run := func() (interface{}, error) {
+		ret, err := computeFibonacci(ctx, key.mode, key.n)
+		s.calls.Observe(float64(counter.Calls()))
+		if err == nil {
+			s.cache.Add(ctx, key, ret)
+		}
+		return ret, err
+	}
+	if s.flights == nil {
+		v, err := run()
+		return v.(string), false, err
+	}
+	leader := false
+	v, err, _ := s.flights.Do(string(key.mode)+"/"+strconv.FormatUint(key.n, 10), func() (interface{}, error) {
+		leader = true
+		return run()
+	})
+	return v.(string), !leader, err
 }
 
 func main() {
+	// ATTRIBUTE_PREFIX 给本服务自定义的span属性加上命名空间, 例如demo.
+	attrPrefix = os.Getenv("ATTRIBUTE_PREFIX")
+	// AUTO_GOMAXPROCS=false 时不按容器的CPU配额调整GOMAXPROCS, 设置了GOMAXPROCS时也不调整
+	procs, procsSource := runtime.GOMAXPROCS(0), "runtime default"
+	if envBool("AUTO_GOMAXPROCS", true) {
+		procs, procsSource = adjustGOMAXPROCS(cgroupCPUQuota)
+	}
+
+	// 所有collector都注册到reg, /metric也从reg采集, 测试中可换成独立的registry
+	reg := prometheus.DefaultRegisterer.(*prometheus.Registry)
+
 	countCollector := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "countPerSec",
 	}, []string{
 		"id", "database",
 	})
 
-	err := prometheus.Register(countCollector)
+	countCollector, err := registerOrReuse(reg, countCollector)
 	if err != nil {
-		panic(err)
+		log.Fatalln(err.Error())
 	}
 
+	// 每次请求中fibonacci被调用的次数, 递归模式下随n指数增长
+	fibonacciCalls := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fibonacci_calls",
+		Help:    "Number of fibonacci invocations made per request.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 12),
+	})
+	if fibonacciCalls, err = registerOrReuse(reg, fibonacciCalls); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	// 按mode和响应状态码统计/fibonacci请求数
+	fibonacciRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fibonacci_requests_total",
+		Help: "Fibonacci requests by mode and response status code.",
+	}, []string{"mode", "status"})
+	if fibonacciRequests, err = registerOrReuse(reg, fibonacciRequests); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	// 后台goroutine在关闭流程中通过bgCancel停止
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	var bg sync.WaitGroup
+	bg.Add(1)
 	go func() {
+		defer bg.Done()
 		timer := time.NewTimer(time.Second)
 		defer timer.Stop()
 		for {
-			<-timer.C
+			select {
+			case <-bgCtx.Done():
+				return
+			case <-timer.C:
+			}
 			countCollector.WithLabelValues("1", "db1").Inc()
 			timer.Reset(time.Second)
 		}
@@ -124,33 +329,496 @@ func main() {
 
 	// otel SDK
 	// Write telemetry data to a file.
-	f, err := os.Create("traces.txt")
+	// TRACE_FILE trace输出文件, TRACE_FORMAT=chrome时可以用traces.json直接导入chrome://tracing
+	f, err := createTraceFile(envString("TRACE_FILE", "traces.txt"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
+	// TRACE_FILE_MAX_AGE>0 时启动时删除修改时间早于该值的轮转/压缩后的旧trace文件
+	if maxAge := envDuration("TRACE_FILE_MAX_AGE", 0); maxAge > 0 {
+		removed, err := removeOldTraceFiles(f.path, maxAge)
+		for _, path := range removed {
+			log.Printf("removed old trace file %s", path)
+		}
+		if err != nil {
+			log.Printf("removing old trace files: %v", err)
+		}
+	}
+	// 收到SIGHUP时重新打开文件, 配合logrotate使用
+	reopenOnSIGHUP(f)
+	// 写文件失败或写入不完整时计数, 连续失败TRACE_WRITE_MAX_FAILURES次后停止写入
+	traceWriteErrors := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "trace_file_write_errors_total",
+		Help: "Failed or short writes to the trace output file.",
+	})
+	if traceWriteErrors, err = registerOrReuse(reg, traceWriteErrors); err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err = registerCollectors(reg, breakerState, breakerDropped, replayBuffered, replayDropped, spansSkipped); err != nil {
+		log.Fatalln(err.Error())
+	}
+	traceWriter := newGuardedWriter(f, int(envUint("TRACE_WRITE_MAX_FAILURES", 5)), traceWriteErrors)
 	// 创建一个新的exporter，将telemetry数据写出到文件
-	exp, err := newExporter(f)
+	// EXPORTER_TYPE=otlpgrpc/otlphttp 时改为发送到collector
+	// 或者按OTel规范设置OTEL_EXPORTER_OTLP_PROTOCOL=grpc/http/protobuf
+	exp, err := newConfiguredExporter(context.Background(), traceWriter)
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
-	// 新建一个TracerProvider, 以trace.WithBatcher把exporter注册上去
-	tracerProvider := trace.NewTracerProvider(
-		trace.WithBatcher(exp),
-		trace.WithResource(newResource()),
-	)
-	defer func() {
-		if err = tracerProvider.Shutdown(context.Background()); err != nil {
-			log.Fatal(err)
+	// 记录每次导出的耗时和批大小
+	timed := newTimedExporter(exp)
+	if err = timed.Register(reg); err != nil {
+		log.Fatalln(err.Error())
+	}
+	exp = timed
+	// OTLP_VALIDATE=true 时检查导出的span能否无损地编码为OTLP protobuf
+	if envBool("OTLP_VALIDATE", false) {
+		validationFailures := prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "otel_span_otlp_validation_failures_total",
+			Help: "Exported spans that did not survive an OTLP protobuf round trip.",
+		})
+		if validationFailures, err = registerOrReuse(reg, validationFailures); err != nil {
+			log.Fatalln(err.Error())
 		}
-	}()
+		exp = newValidatingExporter(exp, validationFailures)
+	}
+	// DEBUG_ENABLED=true 时注册/debug/*调试接口
+	debug := envBool("DEBUG_ENABLED", false)
+	var spanCounter *countingExporter
+	if debug {
+		// 统计导出的span数量, 供/debug/spans/count查看
+		spanCounter = newCountingExporter(exp)
+		exp = spanCounter
+	}
+	// MAX_EXPORTED_SPANS>0 时限制进程导出的span总数, 超出后丢弃
+	if limit := envUint("MAX_EXPORTED_SPANS", 0); limit > 0 {
+		capped := newCappedExporter(exp, limit)
+		if spanCounter != nil {
+			capped.onDrop = spanCounter.AddDropped
+		}
+		if err = registerCollectors(reg, capped.Collectors()...); err != nil {
+			log.Fatalln(err.Error())
+		}
+		exp = capped
+	}
+	// OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT>0 时导出前截断过长的字符串属性并记录原始长度
+	attrLimit := envUint("OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT", 0)
+	if attrLimit > 0 {
+		exp = newTruncatingExporter(exp, int(attrLimit))
+	}
+	// SPAN_TAGS=key=value,key=value 会被加到每个span上
+	spanTags, err := parseSpanTags(os.Getenv("SPAN_TAGS"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	// TRACE_SAMPLE_RATIO 根span的采样比例, 子span跟随父span, 运行时可通过/debug/sampling修改
+	ratioSampler, err := newRatioSampler(envFloat("TRACE_SAMPLE_RATIO", 1))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err = registerCollectors(reg, ratioSampler.Collector()); err != nil {
+		log.Fatalln(err.Error())
+	}
+	var sampler trace.Sampler = trace.ParentBased(ratioSampler)
+	// TENANT_SAMPLE_RATIOS=tenant=ratio,... 按租户设置根span的采样比例, 未配置的租户使用TRACE_SAMPLE_RATIO
+	if entries := envList("TENANT_SAMPLE_RATIOS"); len(entries) > 0 {
+		if sampler, err = newTenantSampler(sampler, entries); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+	sampler = forceSampler{next: sampler}
+	// TRACED_ROUTES 只对列出的路由做trace, 为空时全部trace, 运行时可通过/debug/routes修改
+	tracedRoutes := newRouteToggle(envList("TRACED_ROUTES"))
+	// PROBE_SAMPLE_RATIO /healthz等探针请求的采样比例, 默认0即全部丢弃
+	sampler = newProbeSampler(sampler, envFloat("PROBE_SAMPLE_RATIO", 0))
+	// 在探针采样之外判断路由开关, 关闭的探针路由也不记录
+	sampler = suppressSampler{next: sampler}
+	sampler = deadlineSampler{next: sampler}
+	sampler = keepAliveSampler{next: sampler}
+	// TRACESTATE_ENTRY=key=value 时添加到根span的tracestate中, 上游传来的tracestate会被保留
+	if entry := os.Getenv("TRACESTATE_ENTRY"); entry != "" {
+		if sampler, err = newTraceStateSampler(sampler, entry); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+	// SAMPLING_REASON_ATTR=true 时在根span上记录sampling.reason
+	if envBool("SAMPLING_REASON_ATTR", false) {
+		sampler = reasonSampler{next: sampler}
+	}
+	// 估算batch队列的占用比例, 每隔SPAN_QUEUE_POLL_INTERVAL更新otel_span_queue_saturation_ratio
+	// OTEL_BSP_MAX_QUEUE_SIZE 与SDK一致, 默认2048
+	maxQueueSize := int(envUint("OTEL_BSP_MAX_QUEUE_SIZE", 2048))
+	queue := newQueueTracker(exp, maxQueueSize)
+	if queue.gauge, err = registerOrReuse(reg, queue.gauge); err != nil {
+		log.Fatalln(err.Error())
+	}
+	queue.Run(bgCtx, &bg, envDuration("SPAN_QUEUE_POLL_INTERVAL", 5*time.Second))
+	res := newResource()
+	// 新建一个TracerProvider, 以trace.WithBatcher把exporter注册上去.
+	// 队列统计的processor要排在batcher前面, 保证span先计数再被导出
+	tpOpts := []trace.TracerProviderOption{
+		trace.WithSpanProcessor(queue.Processor()),
+		trace.WithBatcher(queue, trace.WithMaxQueueSize(maxQueueSize)),
+		trace.WithResource(res),
+		trace.WithSampler(sampler),
+	}
+	var ring *ringExporter
+	var trees *treeExporter
+	if debug {
+		// 在内存中保留最近的span, 供/debug/traces查看
+		ring = newRingExporter(int(envUint("DEBUG_TRACE_BUFFER", 256)))
+		tpOpts = append(tpOpts, trace.WithSyncer(ring))
+		// 保留最近一次/fibonacci请求的span树, 供/debug/lasttree查看
+		// DEBUG_TREE_DEPTH 树的最大深度, DEBUG_TREE_MAX_SPANS 每个trace最多保留的span数
+		trees = newTreeExporter("/fibonacci",
+			int(envUint("DEBUG_TREE_DEPTH", 10)),
+			int(envUint("DEBUG_TREE_MAX_SPANS", 10000)))
+		tpOpts = append(tpOpts, trace.WithSyncer(trees))
+	}
+	// SPAN_TAGS_SKIP 不加标签的span名或路由, 默认为/healthz等探针路由
+	if len(spanTags) > 0 {
+		skip := envList("SPAN_TAGS_SKIP")
+		if len(skip) == 0 {
+			skip = probeRoutes
+		}
+		tpOpts = append(tpOpts, trace.WithSpanProcessor(newSpanTagsProcessor(spanTags, skip)))
+	}
+	// CHILD_ATTRS_TO_PARENT=childId,... 子span结束时把这些属性复制到父span上
+	// CHILD_ATTRS_MAX_VALUES 单个属性最多复制多少种不同的值, 防止父span属性基数过高
+	if keys := envList("CHILD_ATTRS_TO_PARENT"); len(keys) > 0 {
+		tpOpts = append(tpOpts, trace.WithSpanProcessor(
+			newChildAttrsProcessor(keys, int(envUint("CHILD_ATTRS_MAX_VALUES", 100))),
+		))
+	}
+	// SDK自己也会读取OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT并直接截断, 这里关闭SDK的截断,
+	// 由导出前的truncatingExporter处理, 它会覆盖所有属性并记录原始长度
+	if attrLimit > 0 {
+		spanLimits := trace.NewSpanLimits()
+		spanLimits.AttributeValueLengthLimit = -1
+		tpOpts = append(tpOpts, trace.WithRawSpanLimits(spanLimits))
+	}
+	// 配合TRACE_ID_HEADER, 让根span沿用请求头中的trace ID
+	if os.Getenv("TRACE_ID_HEADER") != "" {
+		tpOpts = append(tpOpts, trace.WithIDGenerator(legacyTraceIDGenerator{}))
+	}
+	tracerProvider := trace.NewTracerProvider(tpOpts...)
 	// 把tracerProvider注册到全剧
 	otel.SetTracerProvider(tracerProvider)
+	// TRACE_FLUSH_INTERVAL>0 时按该间隔定期flush, 让trace文件及时更新
+	if interval := envDuration("TRACE_FLUSH_INTERVAL", 0); interval > 0 {
+		runFlushLoop(bgCtx, &bg, tracerProvider, interval)
+	}
+
+	// METRICS_EXPORTER=otlp 时额外通过OTLP推送请求指标, 默认只有Prometheus拉取
+	var meterProvider *sdkmetric.MeterProvider
+	switch metricsExporter := envString("METRICS_EXPORTER", "prometheus"); metricsExporter {
+	case "prometheus":
+	case "otlp":
+		meterProvider, err = newOTLPMeterProvider(context.Background(),
+			envString("OTLP_METRICS_ENDPOINT", "localhost:4317"),
+			envBool("OTLP_INSECURE", true),
+			envDuration("METRICS_PUSH_INTERVAL", 15*time.Second))
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		global.SetMeterProvider(meterProvider)
+	default:
+		log.Fatalf("unknown METRICS_EXPORTER %q", metricsExporter)
+	}
+
+	// PROPAGATORS 指定从请求头提取/注入trace上下文的格式
+	propagators := envList("PROPAGATORS")
+	if len(propagators) == 0 {
+		propagators = []string{"tracecontext", "baggage"}
+	}
+	propagator, err := newPropagator(propagators)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	// TRACE_ID_HEADER=X-Trace-Id 时, 上面的格式都没有带trace上下文的请求会沿用该请求头中的trace ID
+	if header := os.Getenv("TRACE_ID_HEADER"); header != "" {
+		propagator = propagation.NewCompositeTextMapPropagator(propagator, traceIDHeaderPropagator{header: header})
+	}
+	otel.SetTextMapPropagator(propagator)
+
+	// SELFTEST_ON_START=true 时启动前先导出一次span, 失败则退出
+	if envBool("SELFTEST_ON_START", false) {
+		if err = selfTest(exp, res, envDuration("SELFTEST_TIMEOUT", 10*time.Second)); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println("self-test passed")
+	}
 
-	http.Handle("/metric", promhttp.Handler())
-	http.Handle("/fibonacci", &fibonacciHandler{})
-	http.Handle("/nested", &nestedSpanHandler{})
-	if err = http.ListenAndServe(":8080", nil); err != nil {
+	// 所有路由都注册到mux上, 不属于已注册路由的请求(包括404)在请求指标中统一记为route="other"
+	mux := newRouteMux()
+	// METRICS_ERROR_HANDLING=http/continue/panic 采集出错时的处理方式
+	metricsHandler, err := newMetricsHandler(reg, reg, envString("METRICS_ERROR_HANDLING", "http"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	mux.Handle("/metric", metricsHandler)
+	// FIB_MODE 为/fibonacci默认使用的算法(recursive/iter/memo/big/matrix), 可以用?mode=覆盖
+	fibDefaultMode, err := parseFibMode(envString("FIB_MODE", string(fibModeRecursive)))
+	if err != nil {
 		log.Fatalln(err.Error())
 	}
+	// FIB_LEGACY_SPAN_NAMES=true 时沿用fibonacci-<n>的span名
+	legacySpanNames = envBool("FIB_LEGACY_SPAN_NAMES", false)
+	// FIB_COMPLEXITY_ATTRS=true 时在fibonacci span上记录算法和复杂度
+	complexityAttrs = envBool("FIB_COMPLEXITY_ATTRS", false)
+	// FIB_CROSSOVER>0 时递归模式下n小于该值的子问题改用迭代计算
+	fibCrossover = envUint("FIB_CROSSOVER", 0)
+	// FIB_MAX_SPAN_ATTRS>0 时限制每个fibonacci span上的属性数量
+	fibMaxAttrs = int(envUint("FIB_MAX_SPAN_ATTRS", 0))
+	// FIB_AGGREGATE_BELOW>0 时递归模式下n小于该值的请求只生成一个汇总span
+	fibAggregateBelow = envUint("FIB_AGGREGATE_BELOW", 0)
+	// FIB_MAX_DEPTH>0 时限制递归深度, 超出时返回400
+	fibMaxDepth = envUint("FIB_MAX_DEPTH", 0)
+	// FIB_MAX_DURATION 单次计算允许的最长时间, 0表示不限制
+	fibBudget := envDuration("FIB_MAX_DURATION", 30*time.Second)
+	// FIB_OVERFLOW_MARGIN n距离uint64溢出阈值在该范围内时计数
+	overflowRisk := newOverflowWatch(envUint("FIB_OVERFLOW_MARGIN", 5))
+	// FIB_OVERFLOW_PARTIAL=true 时溢出的响应中带上uint64能表示的最大结果
+	overflowRisk.partial = envBool("FIB_OVERFLOW_PARTIAL", false)
+	if overflowRisk.counter, err = registerOrReuse(reg, overflowRisk.counter); err != nil {
+		log.Fatalln(err.Error())
+	}
+	// FIB_MEMORY_BUDGET_MB>0 时拒绝预估span内存超出该值的计算, 返回400和预估值
+	var memory *memoryGuard
+	if mb := envUint("FIB_MEMORY_BUDGET_MB", 0); mb > 0 {
+		memory = &memoryGuard{budget: mb << 20}
+	}
+	// FIB_COST_TENANTS 按租户统计计算量时单独计数的租户, 其余租户记为other
+	cost := newCostMeter(envList("FIB_COST_TENANTS"))
+	if cost.total, err = registerOrReuse(reg, cost.total); err != nil {
+		log.Fatalln(err.Error())
+	}
+	// 请求数量和耗时, 默认只通过/metric暴露给Prometheus
+	reqMetrics, err := newRequestMetrics(global.Meter("http"), mux)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err = registerCollectors(reg, reqMetrics.Collectors()...); err != nil {
+		log.Fatalln(err.Error())
+	}
+	// FIB_CACHE_SIZE>0 时缓存最近的计算结果
+	var cache *resultCache
+	if size := envUint("FIB_CACHE_SIZE", 0); size > 0 {
+		evictions := prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "fibonacci_cache_evictions_total",
+			Help: "Results evicted from the fibonacci result cache.",
+		})
+		if evictions, err = registerOrReuse(reg, evictions); err != nil {
+			log.Fatalln(err.Error())
+		}
+		cache = newResultCache(int(size), traceEviction(evictions))
+	}
+	// FIB_COALESCE=true 时并发的相同请求共享同一次计算
+	var flights *singleflight.Group
+	if envBool("FIB_COALESCE", false) {
+		flights = &singleflight.Group{}
+	}
+	// TRUST_PROXY_HEADERS=true 时从X-Forwarded-For/X-Real-IP读取客户端IP
+	// RUNTIME_SPAN_ATTRS/RUNTIME_MEMSTATS_ATTRS=true 时在根span上记录goroutine数量/堆内存
+	tracing := &tracingMiddleware{
+		trustProxy:   envBool("TRUST_PROXY_HEADERS", false),
+		runtimeStats: envBool("RUNTIME_SPAN_ATTRS", false),
+		memStats:     envBool("RUNTIME_MEMSTATS_ATTRS", false),
+		// RUNTIME_SCHED_ATTRS=true 时在根span上记录GOMAXPROCS、CPU数和请求的worker编号
+		schedStats: envBool("RUNTIME_SCHED_ATTRS", false),
+		metrics:    reqMetrics,
+		routes:     tracedRoutes,
+		probes:     map[string]bool{},
+		// TRACE_MIN_DEADLINE>0 时剩余时间小于该值的请求不记录根span
+		minDeadline: envDuration("TRACE_MIN_DEADLINE", 0),
+		// TRACE_KEEPALIVE_MAX_BYTES>0 时复用连接上请求URI加body不超过该字节数的请求不记录根span
+		keepAliveMaxBytes: int64(envUint("TRACE_KEEPALIVE_MAX_BYTES", 0)),
+		// SPAN_HEADER_ATTRS=X-Tenant-ID,... 记录到根span上的请求头, 敏感的请求头会被忽略
+		headers: parseHeaderAttrs(envList("SPAN_HEADER_ATTRS")),
+		// TENANT_HEADER 携带租户ID的请求头, 租户会记录为根span的tenant.id
+		tenantHeader: envString("TENANT_HEADER", "X-Tenant-ID"),
+	}
+	for _, route := range probeRoutes {
+		tracing.probes[route] = true
+	}
+	// SPAN_NAME_TEMPLATE 服务端span的命名模板, 支持{method}和{route}, 无效时使用{route}
+	if tracing.spanName, err = parseSpanNameTemplate(envString("SPAN_NAME_TEMPLATE", defaultSpanNameTemplate)); err != nil {
+		log.Printf("%v, using %q", err, defaultSpanNameTemplate)
+		tracing.spanName = defaultSpanNameTemplate
+	}
+	// MAX_CONCURRENT_REQUESTS>0 时限制同时处理的请求数, 超出的请求排队等待
+	if limit := envUint("MAX_CONCURRENT_REQUESTS", 0); limit > 0 {
+		tracing.limiter = newConcurrencyLimiter(int(limit))
+		if tracing.limiter.wait, err = registerOrReuse(reg, tracing.limiter.wait); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+	// REQUEST_LOG_INTERVAL>0 时每个路由每隔该时间最多打印一行请求汇总日志
+	if interval := envDuration("REQUEST_LOG_INTERVAL", 0); interval > 0 {
+		tracing.logs = newRequestLog(interval)
+	}
+	// FIB_FORCE_SAMPLE_N>0 时n不小于该值的/fibonacci请求总是被采样, 其余按TRACE_SAMPLE_RATIO
+	if threshold := envUint("FIB_FORCE_SAMPLE_N", 0); threshold > 0 {
+		tracing.forceSampling = map[string]func(*http.Request) bool{
+			"/fibonacci": largeFibonacciN(threshold),
+		}
+	}
+	mux.Handle("/fibonacci", tracing.Handle("/fibonacci", &fibonacciHandler{
+		defaultMode: fibDefaultMode,
+		calls:       fibonacciCalls,
+		budget:      fibBudget,
+		overflow:    overflowRisk,
+		memory:      memory,
+		// FIB_SUMMARY_EVENT=true 时在根span上添加summary事件, 汇总子span数、调用次数和结果
+		summary:  envBool("FIB_SUMMARY_EVENT", false),
+		cost:     cost,
+		cache:    cache,
+		flights:  flights,
+		requests: fibonacciRequests,
+	}))
+	// FIB_BATCH_MAX 限制/fibonacci/batch一次计算的数量
+	mux.Handle("/fibonacci/batch", tracing.Handle("/fibonacci/batch", &fibonacciBatchHandler{
+		defaultMode: fibDefaultMode,
+		calls:       fibonacciCalls,
+		budget:      fibBudget,
+		overflow:    overflowRisk,
+		memory:      memory,
+		maxSize:     int(envUint("FIB_BATCH_MAX", 100)),
+		// FIB_BATCH_MAX_BYTES 限制/fibonacci/batch请求体的大小
+		maxBytes: int64(envUint("FIB_BATCH_MAX_BYTES", 64<<10)),
+	}))
+	mux.Handle("/nested", tracing.Handle("/nested", &nestedSpanHandler{}))
+	mux.Handle("/healthz", tracing.Handle("/healthz", &healthHandler{}))
+	// CHAIN_ENABLED=true 时/chain会带着trace上下文调用下游的fibonacci接口
+	if envBool("CHAIN_ENABLED", false) {
+		mux.Handle("/chain", tracing.Handle("/chain", &chainHandler{
+			client: &http.Client{
+				Transport: otelhttp.NewTransport(http.DefaultTransport),
+				Timeout:   30 * time.Second,
+			},
+			downstream: envString("CHAIN_DOWNSTREAM_URL", "http://localhost:8080/fibonacci"),
+		}))
+	}
+	// STATE_FILE 不为空时运行时修改的采样比例和路由开关会保存到该文件, 重启后恢复
+	runtimeCfg := &configHandler{sampler: ratioSampler, routes: tracedRoutes}
+	saveState := func() {}
+	if path := os.Getenv("STATE_FILE"); path != "" {
+		state := newStateFile(path, runtimeCfg)
+		state.Load()
+		saveState = state.Save
+		runtimeCfg.changed = saveState
+	}
+	// SIGINT/SIGTERM 或 POST /debug/shutdown 触发优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, triggerShutdown := context.WithCancel(ctx)
+	defer triggerShutdown()
+
+	if debug {
+		// /fibonacci?flush=true 在响应前导出该请求的span
+		tracing.flushRoutes = map[string]bool{"/fibonacci": true}
+		tracing.flusher = tracerProvider
+		mux.Handle("/debug/flush", &flushHandler{tp: tracerProvider})
+		mux.Handle("/debug/traces", &recentTracesHandler{ring: ring})
+		mux.Handle("/debug/lasttree", &lastTreeHandler{trees: trees})
+		mux.Handle("/debug/propagation", &propagationHandler{})
+		mux.Handle("/debug/sampling", &samplingHandler{sampler: ratioSampler, changed: saveState})
+		mux.Handle("/debug/spans/count", &spanCountHandler{counter: spanCounter})
+		mux.Handle("/debug/vars", publishVars(spanCounter, reqMetrics))
+		mux.Handle("/debug/routes", &routeToggleHandler{toggle: tracedRoutes, changed: saveState})
+		mux.Handle("/debug/config", runtimeCfg)
+		// SHUTDOWN_TOKEN 不为空时需要带上 Authorization: Bearer <token>
+		mux.Handle("/debug/shutdown", &shutdownHandler{
+			token:   os.Getenv("SHUTDOWN_TOKEN"),
+			trigger: triggerShutdown,
+		})
+	}
+	// CORS_ALLOWED_ORIGINS 为空时不启用CORS
+	handler := newCORSHandler(
+		&untracedMetricsHandler{mux: mux, traced: tracedRoutes, metrics: reqMetrics},
+		envList("CORS_ALLOWED_ORIGINS"),
+	)
+
+	// 超时时间可以通过环境变量配置, 防止慢连接长期占用
+	srv := newHTTPServer(":8080", handler)
+	// ENABLE_H2C=true 时同时支持明文HTTP/2 (h2c)
+	if envBool("ENABLE_H2C", false) {
+		enableH2C(srv)
+	}
+	// ENABLE_GRPC=true 时在GRPC_ADDR上同时提供gRPC服务fib.Fibonacci
+	var grpcSrv *grpc.Server
+	if envBool("ENABLE_GRPC", false) {
+		lis, err := net.Listen("tcp", envString("GRPC_ADDR", ":9090"))
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		grpcSrv = newGRPCServer(&fibonacciGRPCServer{
+			mode:   fibDefaultMode,
+			budget: fibBudget,
+			cache:  cache,
+			memory: memory,
+		})
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Printf("grpc server: %v", err)
+			}
+		}()
+	}
+	log.Printf("serving on %s, GOMAXPROCS=%d (%s)", srv.Addr, procs, procsSource)
+	// HTTP_MAX_CONNECTIONS>0 时限制同时打开的连接数, 超出的连接不会被accept
+	serveErr := runServer(ctx, srv, int(envUint("HTTP_MAX_CONNECTIONS", 0)))
+	if serveErr != nil {
+		log.Println(serveErr.Error())
+	}
+
+	// 按顺序关闭: 停止接收请求并等待处理中的请求, 把剩余span写出, 停止后台任务, 关闭文件, 最后注销指标
+	shutdownErr := runShutdown([]shutdownStep{
+		{name: "http server", timeout: 10 * time.Second, fn: srv.Shutdown},
+		{name: "grpc server", timeout: 10 * time.Second, fn: func(ctx context.Context) error {
+			if grpcSrv == nil {
+				return nil
+			}
+			return stopGRPCServer(ctx, grpcSrv)
+		}},
+		{name: "tracer provider", timeout: 10 * time.Second, fn: tracerProvider.Shutdown},
+		{name: "meter provider", timeout: 10 * time.Second, fn: func(ctx context.Context) error {
+			if meterProvider == nil {
+				return nil
+			}
+			return meterProvider.Shutdown(ctx)
+		}},
+		{name: "background tasks", timeout: 5 * time.Second, fn: func(ctx context.Context) error {
+			bgCancel()
+			done := make(chan struct{})
+			go func() {
+				bg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}},
+		{name: "trace file", timeout: time.Second, fn: func(context.Context) error {
+			return f.Close()
+		}},
+		// COMPRESS_TRACE_FILE=true 时把关闭后的trace文件压缩为<TRACE_FILE>.gz,
+		// COMPRESS_TRACE_FILE_REMOVE=true 时再删除原文件
+		{name: "compress trace file", timeout: 30 * time.Second, fn: func(ctx context.Context) error {
+			if !envBool("COMPRESS_TRACE_FILE", false) {
+				return nil
+			}
+			return compressTraceFile(ctx, f.path, envBool("COMPRESS_TRACE_FILE_REMOVE", false))
+		}},
+		{name: "metrics registry", timeout: time.Second, fn: func(context.Context) error {
+			unregisterCollectors(reg)
+			return nil
+		}},
+	})
+	if serveErr != nil || shutdownErr != nil {
+		os.Exit(1)
+	}
+	log.Println("server stopped")
 }
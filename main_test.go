@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// newTestFibonacciHandler returns a fibonacciHandler with unregistered
+// collectors and every optional feature off.
+func newTestFibonacciHandler() *fibonacciHandler {
+	return &fibonacciHandler{
+		defaultMode: fibModeIter,
+		calls:       prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_calls"}),
+		overflow:    newOverflowWatch(0),
+		cost:        newCostMeter(nil),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_requests",
+		}, []string{"mode", "status"}),
+	}
+}
+
+// serveFibonacci sends GET target to h inside a recorded root span and
+// returns the response and the root span.
+func serveFibonacci(t *testing.T, h http.Handler, target string) (*httptest.ResponseRecorder, oteltrace.Span) {
+	t.Helper()
+	ctx, span := tracer("test").Start(context.Background(), "/fibonacci")
+	defer span.End()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx))
+	return rec, span
+}
+
+func TestFibonacciHandlerModes(t *testing.T) {
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+		wantMode   string
+	}{
+		{target: "/fibonacci?n=10", wantStatus: http.StatusOK, wantBody: "55", wantMode: "iter"},
+		{target: "/fibonacci?n=10&mode=recursive", wantStatus: http.StatusOK, wantBody: "55", wantMode: "recursive"},
+		{target: "/fibonacci?n=10&mode=iter", wantStatus: http.StatusOK, wantBody: "55", wantMode: "iter"},
+		{target: "/fibonacci?n=10&mode=memo", wantStatus: http.StatusOK, wantBody: "55", wantMode: "memo"},
+		{target: "/fibonacci?n=10&mode=matrix", wantStatus: http.StatusOK, wantBody: "55", wantMode: "matrix"},
+		{target: "/fibonacci?n=100&mode=big", wantStatus: http.StatusOK, wantBody: "354224848179261915075", wantMode: "big"},
+		{target: "/fibonacci?n=10&mode=quantum", wantStatus: http.StatusBadRequest},
+		{target: "/fibonacci?n=-1", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			resp, _ := serveFibonacci(t, newTestFibonacciHandler(), tt.target)
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := resp.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			var root string
+			for _, s := range rec.Ended() {
+				if s.Name() == "/fibonacci" {
+					root = spanAttr(s, attrKey("fib.mode"))
+				}
+			}
+			if root != tt.wantMode {
+				t.Errorf("fib.mode = %q, want %q", root, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestNewResourceSchemaURL(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{name: "default", want: semconv.SchemaURL},
+		{name: "older schema", env: "https://opentelemetry.io/schemas/1.12.0", want: "https://opentelemetry.io/schemas/1.12.0"},
+		{name: "disabled", env: "none", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("RESOURCE_SCHEMA_URL", tt.env)
+			}
+			rec := useTestTracerProvider(t, trace.WithResource(newResource()))
+			_, span := tracer("test").Start(context.Background(), "span")
+			span.End()
+
+			res := rec.Ended()[0].Resource()
+			if got := res.SchemaURL(); got != tt.want {
+				t.Errorf("schema URL = %q, want %q", got, tt.want)
+			}
+			// A schema differing from resource.Default()'s must not make
+			// the merge drop the attributes.
+			for _, key := range []attribute.Key{semconv.ServiceNameKey, semconv.TelemetrySDKNameKey} {
+				if v, ok := res.Set().Value(key); !ok || v.Emit() == "" {
+					t.Errorf("resource has no %s: %v", key, res)
+				}
+			}
+		})
+	}
+}
+
+func TestFibonacciHandlerCacheHit(t *testing.T) {
+	tests := []struct {
+		name    string
+		cache   bool
+		targets []string
+		// want is fib.cache_hit on each request's root span.
+		want []string
+	}{
+		{name: "cache disabled", targets: []string{"/fibonacci?n=10", "/fibonacci?n=10"}, want: []string{"", ""}},
+		{name: "cold then cached", cache: true, targets: []string{"/fibonacci?n=10", "/fibonacci?n=10"}, want: []string{"false", "true"}},
+		{
+			name:    "keyed by mode",
+			cache:   true,
+			targets: []string{"/fibonacci?n=10", "/fibonacci?n=10&mode=memo", "/fibonacci?n=10&mode=memo"},
+			want:    []string{"false", "false", "true"},
+		},
+		{name: "errors not cached", cache: true, targets: []string{"/fibonacci?n=94", "/fibonacci?n=94"}, want: []string{"false", "false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			if tt.cache {
+				h.cache = newResultCache(4, nil)
+			}
+			for i, target := range tt.targets {
+				serveFibonacci(t, h, target)
+				ended := rec.Ended()
+				root := ended[len(ended)-1]
+				if got := spanAttr(root, attrKey("fib.cache_hit")); got != tt.want[i] {
+					t.Errorf("request %d fib.cache_hit = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// gateProcessor holds every span named name in OnStart until release is
+// closed, signalling entered for each one.
+type gateProcessor struct {
+	name    string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p gateProcessor) OnStart(_ context.Context, s trace.ReadWriteSpan) {
+	if s.Name() == p.name {
+		p.entered <- struct{}{}
+		<-p.release
+	}
+}
+
+func (p gateProcessor) OnEnd(trace.ReadOnlySpan)         {}
+func (p gateProcessor) Shutdown(context.Context) error   { return nil }
+func (p gateProcessor) ForceFlush(context.Context) error { return nil }
+
+func TestFibonacciHandlerCoalescing(t *testing.T) {
+	const requests = 20
+	tests := []struct {
+		name             string
+		coalesce         bool
+		wantComputations uint64
+	}{
+		{name: "coalesced", coalesce: true, wantComputations: 1},
+		{name: "not coalesced", coalesce: false, wantComputations: requests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := gateProcessor{name: "fibonacci-iter", entered: make(chan struct{}, requests), release: make(chan struct{})}
+			rec := useTestTracerProvider(t, trace.WithSpanProcessor(gate))
+			h := newTestFibonacciHandler()
+			calls := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_calls"})
+			h.calls = calls
+			if tt.coalesce {
+				h.flights = &singleflight.Group{}
+			}
+
+			var wg sync.WaitGroup
+			bodies := make([]string, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp, _ := serveFibonacci(t, h, "/fibonacci?n=50")
+					bodies[i] = resp.Body.String()
+				}(i)
+			}
+			// Hold the computations until every request had time to
+			// arrive and join one.
+			<-gate.entered
+			time.Sleep(100 * time.Millisecond)
+			close(gate.release)
+			wg.Wait()
+
+			for i, body := range bodies {
+				if body != "12586269025" {
+					t.Errorf("request %d got %q", i, body)
+				}
+			}
+			var m dto.Metric
+			if err := calls.Write(&m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetHistogram().GetSampleCount(); got != tt.wantComputations {
+				t.Errorf("ran %d computations, want %d", got, tt.wantComputations)
+			}
+			var followers uint64
+			for _, s := range rec.Ended() {
+				if spanAttr(s, attrKey("fib.coalesced")) == "true" {
+					followers++
+				}
+			}
+			if want := requests - tt.wantComputations; tt.coalesce && followers != want {
+				t.Errorf("%d spans with fib.coalesced=true, want %d", followers, want)
+			}
+		})
+	}
+}
+
+func TestFibonacciRequestsCounter(t *testing.T) {
+	targets := []string{
+		"/fibonacci?n=10",
+		"/fibonacci?n=10&mode=recursive",
+		"/fibonacci?n=10&mode=recursive",
+		"/fibonacci?n=10&mode=memo",
+		"/fibonacci?n=94&mode=memo",
+		"/fibonacci?n=100&mode=big",
+		"/fibonacci?n=10&mode=quantum",
+		"/fibonacci?n=-1",
+	}
+	useTestTracerProvider(t)
+	h := newTestFibonacciHandler()
+	for _, target := range targets {
+		serveFibonacci(t, h, target)
+	}
+	if got := testutil.CollectAndCount(h.requests); got != 7 {
+		t.Errorf("%d label sets, want 7", got)
+	}
+
+	tests := []struct {
+		mode, status string
+		want         float64
+	}{
+		{"iter", "200", 1},
+		{"recursive", "200", 2},
+		{"memo", "200", 1},
+		{"memo", strconv.Itoa(computeErrorStatus(errFibonacciOverflow)), 1},
+		{"big", "200", 1},
+		{"matrix", "200", 0},
+		// Unknown modes share one label so cardinality stays bounded.
+		{"invalid", "400", 1},
+		{"iter", "400", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.status, func(t *testing.T) {
+			if got := testutil.ToFloat64(h.requests.WithLabelValues(tt.mode, tt.status)); got != tt.want {
+				t.Errorf("fibonacci_requests_total = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFibonacciHandlerSummary(t *testing.T) {
+	tests := []struct {
+		target    string
+		summary   bool
+		wantCalls int64
+		// wantResult is the summary's fib.result, empty when no summary
+		// event is expected.
+		wantResult string
+	}{
+		{target: "/fibonacci?n=10&mode=recursive", summary: true, wantCalls: 177, wantResult: "55"},
+		{target: "/fibonacci?n=5&mode=recursive", summary: true, wantCalls: 15, wantResult: "5"},
+		{target: "/fibonacci?n=10&mode=iter", summary: true, wantCalls: 1, wantResult: "55"},
+		{target: "/fibonacci?n=10&mode=matrix", summary: true, wantCalls: 4, wantResult: "55"},
+		{target: "/fibonacci?n=10&mode=recursive"},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatBool(tt.summary)+tt.target, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			h.summary = tt.summary
+			resp, root := serveFibonacci(t, h, tt.target)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+			}
+
+			var children int64
+			var rootSpan trace.ReadOnlySpan
+			for _, s := range rec.Ended() {
+				if s.SpanContext().Equal(root.SpanContext()) {
+					rootSpan = s
+				} else {
+					children++
+				}
+			}
+			if rootSpan == nil {
+				t.Fatal("root span not recorded")
+			}
+			var attrs map[attribute.Key]attribute.Value
+			for _, ev := range rootSpan.Events() {
+				if ev.Name == "summary" {
+					attrs = map[attribute.Key]attribute.Value{}
+					for _, kv := range ev.Attributes {
+						attrs[kv.Key] = kv.Value
+					}
+				}
+			}
+			if (attrs != nil) != (tt.wantResult != "") {
+				t.Fatalf("summary event = %v, want one %v", attrs, tt.wantResult != "")
+			}
+			if attrs == nil {
+				return
+			}
+			if got := attrs[attrKey("fib.child_spans")].AsInt64(); got != children {
+				t.Errorf("fib.child_spans = %d, want %d", got, children)
+			}
+			if got := attrs[attrKey("fib.calls")].AsInt64(); got != tt.wantCalls {
+				t.Errorf("fib.calls = %d, want %d", got, tt.wantCalls)
+			}
+			if got := attrs[attrKey("fib.result")].AsString(); got != tt.wantResult {
+				t.Errorf("fib.result = %q, want %q", got, tt.wantResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"net/http"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// spanMemoryEstimate is roughly how many bytes one fibonacci span holds on
+// to until it is exported: the span with its attributes plus its slot in
+// the batch queue.
+const spanMemoryEstimate = 1 << 10
+
+// estimateFibSpans returns the number of spans computing fib(n) in mode
+// produces, saturating at math.MaxUint64. It follows fibCrossover and
+// fibAggregateBelow but not fibMaxDepth, so it may overestimate.
+func estimateFibSpans(mode fibMode, n uint64) uint64 {
+	switch mode {
+	case fibModeIter, fibModeBig:
+		return 1
+	case fibModeMemo:
+		return n + 1
+	case fibModeMatrix:
+		return 1 + uint64(bits.Len64(n))
+	}
+	if n < fibAggregateBelow {
+		return 1
+	}
+	// Each call is one span: calls(n) = calls(n-1) + calls(n-2) + 1, where
+	// the calls for n <= 1 and below the crossover don't recurse.
+	var prev, cur uint64 = 1, 1 // calls(i-1), calls(i)
+	for i := uint64(2); i <= n; i++ {
+		next := uint64(1)
+		if fibCrossover == 0 || i >= fibCrossover {
+			next = satAdd(satAdd(cur, prev), 1)
+		}
+		prev, cur = cur, next
+	}
+	return cur
+}
+
+// satAdd adds a and b, saturating at math.MaxUint64.
+func satAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// memoryGuard rejects computations whose spans are estimated to need more
+// memory than budget bytes. Unlike a flat limit on n it accounts for the
+// mode: recursive n=40 is far costlier than iter n=10000.
+type memoryGuard struct {
+	budget uint64
+}
+
+// memoryEstimate is the body of a response rejected by memoryGuard.
+type memoryEstimate struct {
+	Error          string `json:"error"`
+	N              uint64 `json:"n"`
+	Mode           string `json:"mode"`
+	EstimatedSpans uint64 `json:"estimated_spans"`
+	EstimatedBytes uint64 `json:"estimated_bytes"`
+	BudgetBytes    uint64 `json:"budget_bytes"`
+}
+
+// Reject answers the request with 400 and the estimate, also recording it
+// on span, when computing fib(n) in mode would exceed the budget. It
+// returns false, doing nothing, when the computation fits; a nil
+// memoryGuard lets everything through.
+func (g *memoryGuard) Reject(resp http.ResponseWriter, span oteltrace.Span, mode fibMode, n uint64) bool {
+	spans, bytes, exceeded := g.estimate(mode, n)
+	if !exceeded {
+		return false
+	}
+	body, _ := json.Marshal(memoryEstimate{
+		Error:          g.reject(span, spans, bytes).Error(),
+		N:              n,
+		Mode:           string(mode),
+		EstimatedSpans: spans,
+		EstimatedBytes: bytes,
+		BudgetBytes:    g.budget,
+	})
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusBadRequest)
+	resp.Write(body)
+	return true
+}
+
+// errMemoryBudget is returned for computations rejected by memoryGuard.
+var errMemoryBudget = errors.New("memory budget exceeded")
+
+// Check returns an errMemoryBudget error, also recording the estimate on
+// span, when computing fib(n) in mode would exceed the budget. It is Reject
+// for callers that report errors their own way.
+func (g *memoryGuard) Check(span oteltrace.Span, mode fibMode, n uint64) error {
+	spans, bytes, exceeded := g.estimate(mode, n)
+	if !exceeded {
+		return nil
+	}
+	return g.reject(span, spans, bytes)
+}
+
+// reject records an estimate over the budget on span and describes it.
+func (g *memoryGuard) reject(span oteltrace.Span, spans, bytes uint64) error {
+	span.SetAttributes(
+		attrKey("fib.estimated_spans").Int64(int64(minUint64(spans, math.MaxInt64))),
+		attrKey("fib.estimated_bytes").Int64(int64(minUint64(bytes, math.MaxInt64))),
+	)
+	return fmt.Errorf("%w: estimated memory of %d bytes exceeds the budget of %d bytes", errMemoryBudget, bytes, g.budget)
+}
+
+// Exceeds reports whether computing fib(n) in mode would be rejected.
+func (g *memoryGuard) Exceeds(mode fibMode, n uint64) bool {
+	_, _, exceeded := g.estimate(mode, n)
+	return exceeded
+}
+
+// estimate returns the spans and bytes computing fib(n) in mode takes, and
+// whether they exceed the budget.
+func (g *memoryGuard) estimate(mode fibMode, n uint64) (spans, bytes uint64, exceeded bool) {
+	if g == nil || mode != fibModeBig && n > maxUint64FibN {
+		// Overflowing computations fail before producing spans.
+		return 0, 0, false
+	}
+	spans = estimateFibSpans(mode, n)
+	bytes = spans * spanMemoryEstimate
+	if spans > math.MaxUint64/spanMemoryEstimate {
+		bytes = math.MaxUint64
+	}
+	return spans, bytes, bytes > g.budget
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
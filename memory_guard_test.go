@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestEstimateFibSpans(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           fibMode
+		n              uint64
+		crossover      uint64
+		aggregateBelow uint64
+		want           uint64
+	}{
+		{name: "recursive base", mode: fibModeRecursive, n: 1, want: 1},
+		{name: "recursive", mode: fibModeRecursive, n: 5, want: 15},
+		{name: "recursive larger", mode: fibModeRecursive, n: 10, want: 177},
+		{name: "recursive with crossover", mode: fibModeRecursive, n: 5, crossover: 4, want: 5},
+		{name: "recursive aggregated", mode: fibModeRecursive, n: 4, aggregateBelow: 5, want: 1},
+		{name: "recursive saturates", mode: fibModeRecursive, n: 200, want: math.MaxUint64},
+		{name: "iter", mode: fibModeIter, n: 90, want: 1},
+		{name: "big", mode: fibModeBig, n: 10000, want: 1},
+		{name: "memo", mode: fibModeMemo, n: 20, want: 21},
+		{name: "matrix", mode: fibModeMatrix, n: 8, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFibVar(t, &fibCrossover, tt.crossover)
+			setFibVar(t, &fibAggregateBelow, tt.aggregateBelow)
+			if got := estimateFibSpans(tt.mode, tt.n); got != tt.want {
+				t.Errorf("estimateFibSpans(%s, %d) = %d, want %d", tt.mode, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFibonacciHandlerMemoryBudget(t *testing.T) {
+	// budget fits 16 spans.
+	const budget = 16 * spanMemoryEstimate
+	tests := []struct {
+		name       string
+		guard      *memoryGuard
+		target     string
+		wantStatus int
+		// wantSpans is the estimate in a rejected response.
+		wantSpans uint64
+	}{
+		{name: "no guard", target: "/fibonacci?n=10&mode=recursive", wantStatus: http.StatusOK},
+		{name: "within budget", guard: &memoryGuard{budget: budget}, target: "/fibonacci?n=5&mode=recursive", wantStatus: http.StatusOK},
+		{
+			name: "recursive over budget", guard: &memoryGuard{budget: budget}, target: "/fibonacci?n=10&mode=recursive",
+			wantStatus: http.StatusBadRequest, wantSpans: 177,
+		},
+		{
+			name: "memo over budget", guard: &memoryGuard{budget: budget}, target: "/fibonacci?n=20&mode=memo",
+			wantStatus: http.StatusBadRequest, wantSpans: 21,
+		},
+		{name: "iter cheap at large n", guard: &memoryGuard{budget: budget}, target: "/fibonacci?n=90&mode=iter", wantStatus: http.StatusOK},
+		{name: "overflow left to the overflow check", guard: &memoryGuard{budget: budget}, target: "/fibonacci?n=200&mode=recursive", wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			h.memory = tt.guard
+			resp, root := serveFibonacci(t, h, tt.target)
+
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Code, tt.wantStatus, resp.Body)
+			}
+			if tt.wantSpans == 0 {
+				return
+			}
+			var got memoryEstimate
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatalf("%v: %s", err, resp.Body)
+			}
+			want := memoryEstimate{
+				Error:          got.Error,
+				N:              got.N,
+				Mode:           got.Mode,
+				EstimatedSpans: tt.wantSpans,
+				EstimatedBytes: tt.wantSpans * spanMemoryEstimate,
+				BudgetBytes:    budget,
+			}
+			if got != want || got.Error == "" {
+				t.Errorf("body = %+v, want %+v", got, want)
+			}
+
+			var top trace.ReadOnlySpan
+			for _, s := range rec.Ended() {
+				if s.SpanContext().SpanID() == root.SpanContext().SpanID() {
+					top = s
+				}
+			}
+			if top == nil {
+				t.Fatal("root span not recorded")
+			}
+			if got := spanAttr(top, attrKey("fib.estimated_spans")); got != strconv.FormatUint(tt.wantSpans, 10) {
+				t.Errorf("fib.estimated_spans = %q, want %d", got, tt.wantSpans)
+			}
+			for _, s := range rec.Ended() {
+				if s.Name() != "/fibonacci" {
+					t.Errorf("span %q started for a rejected request", s.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryGuardCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		guard   *memoryGuard
+		mode    fibMode
+		n       uint64
+		wantErr bool
+	}{
+		{name: "nil guard", mode: fibModeRecursive, n: 30},
+		{name: "fits", guard: &memoryGuard{budget: 1 << 20}, mode: fibModeRecursive, n: 10},
+		{name: "exceeds", guard: &memoryGuard{budget: 1 << 20}, mode: fibModeRecursive, n: 30, wantErr: true},
+		{name: "saturated estimate", guard: &memoryGuard{budget: math.MaxUint64 - 1}, mode: fibModeRecursive, n: 93, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			_, span := tracer("test").Start(context.Background(), "span")
+			err := tt.guard.Check(span, tt.mode, tt.n)
+			span.End()
+			if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, errMemoryBudget) {
+				t.Errorf("Check = %v, want errMemoryBudget %v", err, tt.wantErr)
+			}
+			if recorded := spanAttr(rec.Ended()[0], attrKey("fib.estimated_bytes")) != ""; recorded != tt.wantErr {
+				t.Errorf("estimate recorded = %v, want %v", recorded, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+)
+
+// metricsCollector is an OTLP metrics service passing on the names of the
+// metrics with data points it receives.
+type metricsCollector struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	received chan []string
+}
+
+func (c *metricsCollector) Export(_ context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	var names []string
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				if len(m.GetSum().GetDataPoints())+len(m.GetHistogram().GetDataPoints()) > 0 {
+					names = append(names, m.GetName())
+				}
+			}
+		}
+	}
+	select {
+	case c.received <- names:
+	default:
+	}
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPMeterProviderPushes(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := &metricsCollector{received: make(chan []string, 1)}
+	srv := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(srv, collector)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mp, err := newOTLPMeterProvider(ctx, lis.Addr().String(), true, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mp.Shutdown(context.Background())
+	metrics, err := newRequestMetrics(mp.Meter("test"), newRouteMux())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.Observe(ctx, "/fibonacci", http.MethodGet, http.StatusOK, 3*time.Millisecond)
+
+	tests := []string{"http.server.request_count", "http.server.duration"}
+	var got []string
+	select {
+	case got = <-collector.received:
+	case <-ctx.Done():
+		t.Fatal("no metrics pushed")
+	}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, g := range got {
+				if g == name {
+					return
+				}
+			}
+			t.Errorf("pushed %v, want data points for %s", got, name)
+		})
+	}
+}
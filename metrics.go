@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registerCollector registers c with reg. When an equivalent collector is
+// already registered (a re-init, or a second server in the same test
+// binary) the existing one is returned so callers keep using the
+// registered instance. Other errors are returned as is.
+//
+// Collectors are registered behind a recover, so one that panics while
+// being scraped fails that scrape (see newMetricsHandler) instead of the
+// process.
+func registerCollector(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	if err := reg.Register(safeCollector{c}); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if safe, ok := are.ExistingCollector.(safeCollector); ok {
+				return safe.Collector, nil
+			}
+			return are.ExistingCollector, nil
+		}
+		return nil, fmt.Errorf("register metrics: %w", err)
+	}
+	registered.mu.Lock()
+	registered.collectors = append(registered.collectors, registeredCollector{reg: reg, c: c})
+	registered.mu.Unlock()
+	return c, nil
+}
+
+// registered tracks the collectors registerCollector added, and where, for
+// unregisterCollectors.
+var registered struct {
+	mu         sync.Mutex
+	collectors []registeredCollector
+}
+
+type registeredCollector struct {
+	reg prometheus.Registerer
+	c   prometheus.Collector
+}
+
+// unregisterCollectors removes every collector registerCollector added to
+// reg, so the server can be set up again in the same process (a test
+// binary, say) with fresh metrics.
+func unregisterCollectors(reg prometheus.Registerer) {
+	registered.mu.Lock()
+	defer registered.mu.Unlock()
+	kept := registered.collectors[:0]
+	for _, rc := range registered.collectors {
+		if rc.reg != reg {
+			kept = append(kept, rc)
+			continue
+		}
+		reg.Unregister(safeCollector{rc.c})
+	}
+	registered.collectors = kept
+}
+
+// registerCollectors registers every collector in cs with registerCollector.
+func registerCollectors(reg prometheus.Registerer, cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if _, err := registerCollector(reg, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerOrReuse is registerCollector for collectors the caller keeps
+// recording into: it returns the registered instance with c's type.
+func registerOrReuse[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	got, err := registerCollector(reg, c)
+	if err != nil {
+		return c, err
+	}
+	if existing, ok := got.(T); ok {
+		return existing, nil
+	}
+	return c, nil
+}
+
+// safeCollector turns a panic in Collect into an invalid metric, which the
+// registry reports as a gather error.
+type safeCollector struct {
+	prometheus.Collector
+}
+
+func (c safeCollector) Collect(ch chan<- prometheus.Metric) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("collector panicked: %v", r)
+			ch <- prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(err), err)
+		}
+	}()
+	c.Collector.Collect(ch)
+}
+
+// newMetricsHandler serves the metrics gathered from g, registering the
+// handler's own metrics with reg. onError is the METRICS_ERROR_HANDLING
+// policy for failed collectors: "http" answers the scrape with a 500,
+// "continue" serves whatever was gathered and "panic" panics. Errors are
+// logged either way.
+func newMetricsHandler(reg prometheus.Registerer, g prometheus.Gatherer, onError string) (http.Handler, error) {
+	opts := promhttp.HandlerOpts{ErrorLog: log.Default()}
+	switch onError {
+	case "http":
+		opts.ErrorHandling = promhttp.HTTPErrorOnError
+	case "continue":
+		opts.ErrorHandling = promhttp.ContinueOnError
+	case "panic":
+		opts.ErrorHandling = promhttp.PanicOnError
+	default:
+		return nil, fmt.Errorf("unknown metrics error handling %q", onError)
+	}
+	return promhttp.InstrumentMetricHandler(
+		reg,
+		promhttp.HandlerFor(g, opts),
+	), nil
+}
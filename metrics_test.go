@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// setUpMetrics registers the kind of collectors the server does with reg and
+// returns the countPerSec instance to record into.
+func setUpMetrics(t *testing.T, reg *prometheus.Registry) *prometheus.CounterVec {
+	t.Helper()
+	count, err := registerOrReuse(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "countPerSec",
+	}, []string{"id", "database"}))
+	if err != nil {
+		t.Fatalf("register countPerSec: %v", err)
+	}
+	if err := registerCollectors(reg, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "test_gauge",
+	}, func() float64 { return 1 })); err != nil {
+		t.Fatalf("register gauge: %v", err)
+	}
+	if _, err := newMetricsHandler(reg, reg, "http"); err != nil {
+		t.Fatalf("newMetricsHandler: %v", err)
+	}
+	return count
+}
+
+func TestRegisterCollectorsLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		teardown bool
+		// want is the countPerSec value after recording once per run.
+		want float64
+	}{
+		{name: "reuse without teardown", teardown: false, want: 2},
+		{name: "fresh after teardown", teardown: true, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			defer unregisterCollectors(reg)
+
+			var count *prometheus.CounterVec
+			for run := 0; run < 2; run++ {
+				count = setUpMetrics(t, reg)
+				count.WithLabelValues("1", "db").Inc()
+				if tt.teardown && run == 0 {
+					unregisterCollectors(reg)
+				}
+			}
+			if got := testutil.ToFloat64(count.WithLabelValues("1", "db")); got != tt.want {
+				t.Errorf("countPerSec = %v, want %v", got, tt.want)
+			}
+
+			handler, err := newMetricsHandler(reg, reg, "http")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metric", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("scrape status = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestUnregisterCollectorsLeavesOtherRegistries(t *testing.T) {
+	a, b := prometheus.NewRegistry(), prometheus.NewRegistry()
+	defer unregisterCollectors(b)
+	setUpMetrics(t, a).WithLabelValues("1", "db").Inc()
+	setUpMetrics(t, b).WithLabelValues("1", "db").Inc()
+
+	unregisterCollectors(a)
+
+	tests := []struct {
+		name string
+		reg  *prometheus.Registry
+		want int
+	}{
+		{name: "torn down", reg: a, want: 0},
+		{name: "untouched", reg: b, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := testutil.GatherAndCount(tt.reg, "countPerSec", "test_gauge")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.want {
+				t.Errorf("gathered %d metrics, want %d", count, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterCollectorTwice(t *testing.T) {
+	newCount := func(labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "countPerSec", Help: "h"}, labels)
+	}
+	first := newCount("id", "database")
+	tests := []struct {
+		name      string
+		second    prometheus.Collector
+		wantReuse bool
+		wantErr   bool
+	}{
+		{name: "same collector", second: first, wantReuse: true},
+		{name: "equal collector", second: newCount("id", "database"), wantReuse: true},
+		{name: "conflicting labels", second: newCount("id"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			defer unregisterCollectors(reg)
+			if _, err := registerCollector(reg, first); err != nil {
+				t.Fatal(err)
+			}
+			got, err := registerCollector(reg, tt.second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantReuse && got != prometheus.Collector(first) {
+				t.Errorf("got %v, want the first collector reused", got)
+			}
+		})
+	}
+}
+
+// panickingCollector panics on every scrape.
+type panickingCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c panickingCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c panickingCollector) Collect(chan<- prometheus.Metric) { panic("broken collector") }
+
+func TestMetricsHandlerPanickingCollector(t *testing.T) {
+	tests := []struct {
+		onError    string
+		wantStatus int
+		// wantGood tells whether the healthy metric is still served.
+		wantGood bool
+	}{
+		{onError: "http", wantStatus: http.StatusInternalServerError},
+		{onError: "continue", wantStatus: http.StatusOK, wantGood: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onError, func(t *testing.T) {
+			captureLog(t)
+			reg := prometheus.NewRegistry()
+			defer unregisterCollectors(reg)
+			if err := registerCollectors(reg,
+				panickingCollector{desc: prometheus.NewDesc("broken_metric", "Panics.", nil, nil)},
+				prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "good_metric", Help: "Fine."}, func() float64 { return 1 }),
+			); err != nil {
+				t.Fatal(err)
+			}
+			handler, err := newMetricsHandler(reg, reg, tt.onError)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metric", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := strings.Contains(rec.Body.String(), "good_metric 1"); got != tt.wantGood {
+				t.Errorf("good_metric served = %v, want %v:\n%s", got, tt.wantGood, rec.Body)
+			}
+		})
+	}
+}
+
+func TestNewMetricsHandlerUnknownPolicy(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := newMetricsHandler(reg, reg, "ignore"); err == nil {
+		t.Error("unknown METRICS_ERROR_HANDLING accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware starts the root server span for each request before
+// handing it to the route's handler.
+type tracingMiddleware struct {
+	// trustProxy enables reading the client address from X-Forwarded-For
+	// and X-Real-IP. Only turn it on behind a proxy that sets them.
+	trustProxy bool
+	// runtimeStats adds the goroutine count to each root span.
+	runtimeStats bool
+	// memStats adds the in-use heap size; it stops the world briefly, so
+	// it is enabled separately.
+	memStats bool
+	// schedStats adds GOMAXPROCS, the CPU count and a worker id. Go hides
+	// goroutine ids, so the worker id numbers requests in arrival order
+	// instead; it tells apart spans of requests served side by side.
+	schedStats bool
+	workers    atomic.Uint64
+	// metrics records the count and duration of every request.
+	metrics *requestMetrics
+	// routes decides which routes are traced.
+	routes *routeToggle
+	// probes are health check routes, sampled by probeSampler.
+	probes map[string]bool
+	// forceSampling holds per route checks deciding, from the request
+	// alone, that its root span must be sampled (see forceSampler).
+	forceSampling map[string]func(*http.Request) bool
+	// minDeadline leaves the root span unrecorded for requests that must
+	// finish sooner than this (see deadlineSampler), 0 disables it.
+	minDeadline time.Duration
+	// logs writes throttled per route request summaries, nil disables them.
+	logs *requestLog
+	// spanName names the server spans, by default after the route.
+	spanName spanNameTemplate
+	// headers are the request headers recorded on the root span.
+	headers []string
+	// keepAliveMaxBytes leaves the root span unrecorded for requests on a
+	// reused connection whose request URI and body take at most this many
+	// bytes (see keepAliveSampler), 0 disables it.
+	keepAliveMaxBytes int64
+	// tenantHeader is the request header naming the tenant, which is
+	// recorded on the root span and used by tenantSampler.
+	tenantHeader string
+	// limiter caps concurrent requests, nil for no limit.
+	limiter *concurrencyLimiter
+	// flushRoutes are the routes whose requests may ask with ?flush=true for
+	// their spans to be exported before the response completes, by
+	// flushing flusher. Meant for debugging, as every flush exports a
+	// partial batch.
+	flushRoutes map[string]bool
+	flusher     interface{ ForceFlush(context.Context) error }
+}
+
+// Handle wraps next so every request to route runs inside a server span.
+func (m *tracingMiddleware) Handle(route string, next http.Handler) http.Handler {
+	m.routes.Register(route)
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		if !m.routes.Enabled(route) {
+			ctx = withTracingSuppressed(ctx)
+		}
+		if m.probes[route] {
+			ctx = withProbe(ctx)
+		}
+		if force := m.forceSampling[route]; force != nil && force(req) {
+			ctx = withForcedSampling(ctx)
+		}
+		if m.minDeadline > 0 && hasTightDeadline(req, m.minDeadline) {
+			ctx = withTightDeadline(ctx)
+		}
+		if m.keepAliveMaxBytes > 0 && isKeepAliveRequest(req, m.keepAliveMaxBytes) {
+			ctx = withKeepAlive(ctx)
+		}
+		attrs := []attribute.KeyValue{
+			semconv.HTTPMethod(req.Method),
+			semconv.HTTPRoute(route),
+			attribute.String("client.address", m.clientAddress(req)),
+			attribute.String("user_agent.original", req.UserAgent()),
+		}
+		attrs = append(attrs, headerAttributes(req, m.headers)...)
+		if tenant := req.Header.Get(m.tenantHeader); m.tenantHeader != "" && tenant != "" {
+			ctx = withTenant(ctx, tenant)
+			attrs = append(attrs, attrKey("tenant.id").String(tenant))
+		}
+		if m.runtimeStats {
+			attrs = append(attrs, attrKey("runtime.goroutines").Int(runtime.NumGoroutine()))
+		}
+		if m.schedStats {
+			attrs = append(attrs,
+				attrKey("runtime.worker_id").Int64(int64(m.workers.Add(1))),
+				attrKey("runtime.gomaxprocs").Int(runtime.GOMAXPROCS(0)),
+				attrKey("runtime.num_cpu").Int(runtime.NumCPU()),
+			)
+		}
+		if m.memStats {
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			attrs = append(attrs, attrKey("runtime.heap_inuse_bytes").Int64(int64(ms.HeapInuse)))
+		}
+		ctx, span := tracer("http").Start(ctx, m.spanName.Render(req.Method, route),
+			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+			oteltrace.WithAttributes(attrs...),
+		)
+		defer func() {
+			span.End()
+			if m.flushRoutes[route] && req.URL.Query().Get("flush") == "true" {
+				m.flush()
+			}
+		}()
+		runRootSpanStart(ctx, span)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: resp}
+		if release, ok := m.acquire(ctx, span, route, rec); ok {
+			func() {
+				defer release()
+				next.ServeHTTP(rec, req.WithContext(ctx))
+			}()
+		}
+		span.SetAttributes(semconv.HTTPStatusCode(rec.Status()))
+		if rec.writeErr != nil {
+			span.RecordError(rec.writeErr)
+			span.SetStatus(codes.Error, "writing response: "+rec.writeErr.Error())
+		}
+		runRootSpanEnd(ctx, span)
+		m.metrics.Observe(ctx, route, req.Method, rec.Status(), time.Since(start))
+		m.logs.Record(route, rec.Status())
+	})
+}
+
+// flush exports the spans ended so far, giving up after flushTimeout.
+func (m *tracingMiddleware) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := m.flusher.ForceFlush(ctx); err != nil {
+		log.Printf("flushing spans after request: %v", err)
+	}
+}
+
+// flushTimeout bounds the flush a request asks for with ?flush=true.
+const flushTimeout = 10 * time.Second
+
+// clientAddress returns the IP of the client that sent req. With trustProxy
+// set the leftmost valid X-Forwarded-For entry wins, then X-Real-IP;
+// otherwise, or when neither holds a valid IP, RemoteAddr is used.
+func (m *tracingMiddleware) clientAddress(req *http.Request) string {
+	if m.trustProxy {
+		for _, hop := range strings.Split(req.Header.Get("X-Forwarded-For"), ",") {
+			if ip := net.ParseIP(strings.TrimSpace(hop)); ip != nil {
+				return ip.String()
+			}
+		}
+		if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// useTestTracerProvider installs a global tracer provider recording every
+// ended span until the test finishes. opts come after the recorder, so they
+// may override the default AlwaysSample sampler.
+func useTestTracerProvider(t *testing.T, opts ...trace.TracerProviderOption) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	opts = append([]trace.TracerProviderOption{
+		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithSpanProcessor(rec),
+	}, opts...)
+	tp := trace.NewTracerProvider(opts...)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		tp.Shutdown(context.Background())
+	})
+	return rec
+}
+
+// newTestMiddleware returns a tracingMiddleware with every optional feature
+// off, as main sets it up with no environment.
+func newTestMiddleware(t *testing.T) *tracingMiddleware {
+	t.Helper()
+	metrics, err := newRequestMetrics(metric.NewNoopMeterProvider().Meter("test"), newRouteMux())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tracingMiddleware{
+		metrics:      metrics,
+		routes:       newRouteToggle(nil),
+		probes:       map[string]bool{},
+		spanName:     defaultSpanNameTemplate,
+		tenantHeader: "X-Tenant-ID",
+	}
+}
+
+// spanAttr returns the value of the attribute key on s, or "" without it.
+func spanAttr(s trace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestClientAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		remoteAddr string
+		header     map[string]string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "direct ipv6", remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{name: "remote addr without port", remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+		{
+			name:       "proxy headers ignored when untrusted",
+			remoteAddr: "192.0.2.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Real-IP": "203.0.113.8"},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "leftmost forwarded hop",
+			trustProxy: true,
+			remoteAddr: "192.0.2.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "203.0.113.7, 198.51.100.2"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "invalid hops skipped",
+			trustProxy: true,
+			remoteAddr: "192.0.2.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "unknown, 198.51.100.2"},
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "real ip",
+			trustProxy: true,
+			remoteAddr: "192.0.2.1:1234",
+			header:     map[string]string{"X-Real-IP": " 203.0.113.8 "},
+			want:       "203.0.113.8",
+		},
+		{
+			name:       "no valid proxy header",
+			trustProxy: true,
+			remoteAddr: "192.0.2.1:1234",
+			header:     map[string]string{"X-Forwarded-For": "garbage", "X-Real-IP": "junk"},
+			want:       "192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fibonacci", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			m := &tracingMiddleware{trustProxy: tt.trustProxy}
+			if got := m.clientAddress(req); got != tt.want {
+				t.Errorf("clientAddress = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracingMiddlewareClientAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		forwarded  string
+		wantAddr   string
+	}{
+		{name: "direct", wantAddr: "192.0.2.1"},
+		{name: "proxied", trustProxy: true, forwarded: "203.0.113.7, 192.0.2.1", wantAddr: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.trustProxy = tt.trustProxy
+
+			req := httptest.NewRequest(http.MethodGet, "/fibonacci", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("User-Agent", "test-agent/1.0")
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			m.Handle("/fibonacci", http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			if got := spanAttr(ended[0], "client.address"); got != tt.wantAddr {
+				t.Errorf("client.address = %q, want %q", got, tt.wantAddr)
+			}
+			if got := spanAttr(ended[0], "user_agent.original"); got != "test-agent/1.0" {
+				t.Errorf("user_agent.original = %q, want %q", got, "test-agent/1.0")
+			}
+		})
+	}
+}
+
+func TestTracingMiddlewareRuntimeAttributes(t *testing.T) {
+	tests := []struct {
+		name         string
+		runtimeStats bool
+		memStats     bool
+		want         map[attribute.Key]bool
+	}{
+		{
+			name: "disabled",
+			want: map[attribute.Key]bool{"runtime.goroutines": false, "runtime.heap_inuse_bytes": false},
+		},
+		{
+			name:         "goroutines",
+			runtimeStats: true,
+			want:         map[attribute.Key]bool{"runtime.goroutines": true, "runtime.heap_inuse_bytes": false},
+		},
+		{
+			name:         "goroutines and heap",
+			runtimeStats: true,
+			memStats:     true,
+			want:         map[attribute.Key]bool{"runtime.goroutines": true, "runtime.heap_inuse_bytes": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.runtimeStats, m.memStats = tt.runtimeStats, tt.memStats
+			m.Handle("/fibonacci", http.NotFoundHandler()).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fibonacci", nil))
+
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			for key, want := range tt.want {
+				v := spanAttr(ended[0], key)
+				if (v != "") != want {
+					t.Errorf("%s = %q, want present %v", key, v, want)
+				}
+				if want && v == "0" {
+					t.Errorf("%s = 0", key)
+				}
+			}
+		})
+	}
+}
+
+func TestTracingMiddlewareSchedAttributes(t *testing.T) {
+	const requests = 3
+	tests := []struct {
+		name       string
+		schedStats bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", schedStats: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.schedStats = tt.schedStats
+			handler := m.Handle("/fibonacci", http.NotFoundHandler())
+			for i := 0; i < requests; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fibonacci", nil))
+			}
+
+			ended := rec.Ended()
+			if len(ended) != requests {
+				t.Fatalf("got %d spans, want %d", len(ended), requests)
+			}
+			want := map[attribute.Key]string{
+				"runtime.gomaxprocs": strconv.Itoa(runtime.GOMAXPROCS(0)),
+				"runtime.num_cpu":    strconv.Itoa(runtime.NumCPU()),
+			}
+			workers := map[string]bool{}
+			for _, s := range ended {
+				for key, v := range want {
+					if !tt.schedStats {
+						v = ""
+					}
+					if got := spanAttr(s, key); got != v {
+						t.Errorf("%s = %q, want %q", key, got, v)
+					}
+				}
+				id := spanAttr(s, "runtime.worker_id")
+				if (id != "") != tt.schedStats {
+					t.Errorf("runtime.worker_id = %q, want present %v", id, tt.schedStats)
+				}
+				workers[id] = true
+			}
+			// Every request gets its own worker id.
+			if tt.schedStats && len(workers) != requests {
+				t.Errorf("%d distinct worker ids over %d requests", len(workers), requests)
+			}
+		})
+	}
+}
+
+// failingResponseWriter fails every body write, like a connection the
+// client closed.
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingResponseWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+func TestTracingMiddlewareWriteError(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      http.ResponseWriter
+		wantError bool
+	}{
+		{name: "ok", resp: httptest.NewRecorder()},
+		{name: "client gone", resp: failingResponseWriter{httptest.NewRecorder()}, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.Handle("/healthz", &healthHandler{}).ServeHTTP(tt.resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			if got := ended[0].Status().Code == codes.Error; got != tt.wantError {
+				t.Errorf("status = %v, want error %v", ended[0].Status(), tt.wantError)
+			}
+			var recorded bool
+			for _, ev := range ended[0].Events() {
+				recorded = recorded || ev.Name == semconv.ExceptionEventName
+			}
+			if recorded != tt.wantError {
+				t.Errorf("exception event = %v, want %v", recorded, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestTracingMiddlewareFlush(t *testing.T) {
+	tests := []struct {
+		name        string
+		flushRoutes map[string]bool
+		target      string
+		wantFlushed bool
+	}{
+		{name: "flush requested", flushRoutes: map[string]bool{"/fibonacci": true}, target: "/fibonacci?flush=true", wantFlushed: true},
+		{name: "no param", flushRoutes: map[string]bool{"/fibonacci": true}, target: "/fibonacci"},
+		{name: "flush false", flushRoutes: map[string]bool{"/fibonacci": true}, target: "/fibonacci?flush=false"},
+		{name: "route not flushable", flushRoutes: map[string]bool{"/nested": true}, target: "/fibonacci?flush=true"},
+		{name: "debug off", target: "/fibonacci?flush=true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := &stubExporter{}
+			// The batch would otherwise sit in the queue for an hour.
+			useTestTracerProvider(t, trace.WithBatcher(exp, trace.WithBatchTimeout(time.Hour)))
+			m := newTestMiddleware(t)
+			m.flushRoutes = tt.flushRoutes
+			m.flusher = otel.GetTracerProvider().(*trace.TracerProvider)
+			m.Handle("/fibonacci", http.NotFoundHandler()).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if flushed := len(exp.spans()) == 1; flushed != tt.wantFlushed {
+				t.Errorf("exported %d spans when the response completed, want flushed %v", len(exp.spans()), tt.wantFlushed)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// multiExporter fans every batch out to several independently configured
+// exporters, e.g. a pretty printed file plus OTLP to a collector. Each span
+// is serialized once per child, so the export cost grows linearly with the
+// number of children. Up to concurrency children export at once; with a
+// timeout set, a child that hasn't returned by then is reported as failed
+// and left to finish in the background, so a hung collector can't hold up
+// the batch.
+type multiExporter struct {
+	children    []trace.SpanExporter
+	concurrency int
+	timeout     time.Duration
+}
+
+var _ trace.SpanExporter = (*multiExporter)(nil)
+
+// newMultiExporter returns a multiExporter over children. concurrency <= 0
+// exports to all children in parallel, timeout <= 0 waits for every child.
+func newMultiExporter(concurrency int, timeout time.Duration, children ...trace.SpanExporter) *multiExporter {
+	if concurrency <= 0 || concurrency > len(children) {
+		concurrency = len(children)
+	}
+	return &multiExporter{children: children, concurrency: concurrency, timeout: timeout}
+}
+
+// ExportSpans hands spans to every child, even when another one fails.
+func (e *multiExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if e.timeout > 0 {
+		// A timed out child may still be reading spans after we return,
+		// and the batch processor reuses its slice.
+		spans = append([]trace.ReadOnlySpan(nil), spans...)
+	}
+	results := make([]error, len(e.children))
+	sem := make(chan struct{}, e.concurrency)
+	var wg sync.WaitGroup
+	for i, child := range e.children {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, child trace.SpanExporter) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = e.export(ctx, child, spans)
+		}(i, child)
+	}
+	wg.Wait()
+
+	var errs multiError
+	for i, err := range results {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("exporter #%d: %w", i, err))
+		}
+	}
+	return errs.err()
+}
+
+// export runs one child's export, giving up after e.timeout.
+func (e *multiExporter) export(ctx context.Context, child trace.SpanExporter, spans []trace.ReadOnlySpan) error {
+	if e.timeout <= 0 {
+		return child.ExportSpans(ctx, spans)
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- child.ExportSpans(ctx, spans) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *multiExporter) Shutdown(ctx context.Context) error {
+	var errs multiError
+	for _, child := range e.children {
+		if err := child.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.err()
+}
+
+// multiError collects the errors of several children.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d exporter(s) failed: %s", len(m), strings.Join(msgs, "; "))
+}
+
+// err returns m as an error, or nil when it is empty.
+func (m multiError) err() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
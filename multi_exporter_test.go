@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanIDs returns the span IDs of spans, in order.
+func spanIDs(spans []trace.ReadOnlySpan) []string {
+	ids := make([]string, len(spans))
+	for i, s := range spans {
+		ids[i] = s.SpanContext().SpanID().String()
+	}
+	return ids
+}
+
+func TestMultiExporterFanOut(t *testing.T) {
+	failed := errors.New("collector down")
+	tests := []struct {
+		name     string
+		children []*stubExporter
+		wantErr  bool
+	}{
+		{name: "two exporters", children: []*stubExporter{{}, {}}},
+		{name: "three exporters", children: []*stubExporter{{}, {}, {}}},
+		{name: "one failing", children: []*stubExporter{{}, {err: failed}, {}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			children := make([]trace.SpanExporter, len(tt.children))
+			for i, c := range tt.children {
+				children[i] = c
+			}
+			spans := testSpans(4)
+			err := newMultiExporter(0, 0, children...).ExportSpans(context.Background(), spans)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExportSpans = %v, want error %v", err, tt.wantErr)
+			}
+			for i, c := range tt.children {
+				if c.err != nil {
+					continue
+				}
+				if got, want := spanIDs(c.spans()), spanIDs(spans); !reflect.DeepEqual(got, want) {
+					t.Errorf("exporter #%d got %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfiguredExportersIndependent(t *testing.T) {
+	spanID := testSpans(1)[0].SpanContext().SpanID().String()
+	tests := []struct {
+		name      string
+		exporters string
+		// wantFile checks the trace file holds only the file child's output.
+		wantFile func(t *testing.T, out string)
+		// wantOTLP is the protocol the OTLP child receives the span over.
+		wantOTLP string
+		wantErr  error
+	}{
+		{
+			name:      "ndjson file and otlphttp",
+			exporters: "file:ndjson,otlphttp",
+			wantFile: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				if len(lines) != 1 || !json.Valid([]byte(lines[0])) || !strings.Contains(lines[0], `"span_id":"`+spanID+`"`) {
+					t.Errorf("trace file is not one NDJSON span:\n%s", out)
+				}
+			},
+			wantOTLP: "http/protobuf",
+		},
+		{
+			name:      "pretty file and otlpgrpc",
+			exporters: "file:pretty,otlpgrpc",
+			wantFile: func(t *testing.T, out string) {
+				if !strings.Contains(out, "\n\t\"SpanContext\": {") || strings.Contains(out, `"span_id"`) {
+					t.Errorf("trace file is not pretty JSON only:\n%s", out)
+				}
+			},
+			wantOTLP: "grpc",
+		},
+		{name: "two file formats", exporters: "file:ndjson,file:pretty", wantErr: ErrInvalidExporterType},
+		{name: "same file twice", exporters: "file,file", wantErr: ErrInvalidExporterType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, received := otlpCollector(t)
+			t.Setenv("OTLP_ENDPOINT", endpoint)
+			t.Setenv("EXPORTERS", tt.exporters)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var buf bytes.Buffer
+			exp, err := newConfiguredExporter(ctx, &buf)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer exp.Shutdown(context.Background())
+
+			if err := exp.ExportSpans(ctx, testSpans(1)); err != nil {
+				t.Fatal(err)
+			}
+			tt.wantFile(t, buf.String())
+			select {
+			case got := <-received:
+				if got != tt.wantOTLP {
+					t.Errorf("OTLP child exported over %s, want %s", got, tt.wantOTLP)
+				}
+			case <-ctx.Done():
+				t.Fatal("OTLP child exported nothing")
+			}
+		})
+	}
+}
+
+// hungExporter never returns from ExportSpans until released, ignoring its
+// context like a wedged client.
+type hungExporter struct {
+	release chan struct{}
+}
+
+func (e hungExporter) ExportSpans(context.Context, []trace.ReadOnlySpan) error {
+	<-e.release
+	return nil
+}
+
+func (e hungExporter) Shutdown(context.Context) error { return nil }
+
+func TestMultiExporterSlowChild(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	tests := []struct {
+		name        string
+		concurrency int
+		// slow is the hung child; blocking children honour their context.
+		slow trace.SpanExporter
+	}{
+		{name: "parallel, blocking child", slow: &stubExporter{block: true}},
+		{name: "parallel, hung child", slow: hungExporter{release: make(chan struct{})}},
+		{name: "one at a time, hung child first", concurrency: 1, slow: hungExporter{release: make(chan struct{})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if h, ok := tt.slow.(hungExporter); ok {
+				defer close(h.release)
+			}
+			fast := []*stubExporter{{}, {}}
+			e := newMultiExporter(tt.concurrency, timeout, tt.slow, fast[0], fast[1])
+
+			start := time.Now()
+			err := e.ExportSpans(context.Background(), testSpans(2))
+			if elapsed := time.Since(start); elapsed > 10*timeout {
+				t.Errorf("export took %s with a %s timeout", elapsed, timeout)
+			}
+			if err == nil || !strings.Contains(err.Error(), "exporter #0: "+context.DeadlineExceeded.Error()) {
+				t.Errorf("err = %v, want exporter #0 timed out", err)
+			}
+			if err != nil && strings.Contains(err.Error(), "#1") {
+				t.Errorf("err = %v, want only exporter #0 failed", err)
+			}
+			for i, c := range fast {
+				if got := len(c.spans()); got != 2 {
+					t.Errorf("exporter #%d got %d spans, want 2", i+1, got)
+				}
+			}
+		})
+	}
+}
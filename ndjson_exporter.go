@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ndjsonSpan is the compact, single line representation of a span.
+type ndjsonSpan struct {
+	TraceID      string        `json:"trace_id"`
+	SpanID       string        `json:"span_id"`
+	ParentSpanID string        `json:"parent_span_id,omitempty"`
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	StartTime    time.Time     `json:"start_time"`
+	EndTime      time.Time     `json:"end_time"`
+	Attributes   interface{}   `json:"attributes,omitempty"`
+	Events       []ndjsonEvent `json:"events,omitempty"`
+	Status       string        `json:"status"`
+	Scope        ndjsonScope   `json:"scope"`
+	// ResourceID references the batch's ndjsonResource record, with
+	// withBatchResource only.
+	ResourceID string `json:"resource_id,omitempty"`
+}
+
+// ndjsonResource is the record written ahead of a batch's spans by
+// withBatchResource. Type tells it apart from span lines.
+type ndjsonResource struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	SchemaURL  string      `json:"schema_url,omitempty"`
+	Attributes interface{} `json:"attributes,omitempty"`
+}
+
+// ndjsonEvent is a span event. Time is either the absolute time or, with
+// withRelativeEventTimes, the offset from the span start such as "+1.2ms".
+type ndjsonEvent struct {
+	Name       string      `json:"name"`
+	Time       interface{} `json:"time"`
+	Attributes interface{} `json:"attributes,omitempty"`
+}
+
+// ndjsonScope identifies the tracer that produced a span.
+type ndjsonScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// ndjsonAttribute is a span attribute with its OpenTelemetry type spelled
+// out, so downstream tooling can tell an INT64 from a STRING.
+type ndjsonAttribute struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// ndjsonExporter writes every span as one JSON object per line, which is
+// easier for log pipelines to ingest than the pretty printed stdout format.
+type ndjsonExporter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	stopped bool
+
+	typedAttributes    bool
+	relativeEventTimes bool
+	batchResource      bool
+}
+
+var _ trace.SpanExporter = (*ndjsonExporter)(nil)
+
+// ndjsonOption configures an ndjsonExporter.
+type ndjsonOption func(*ndjsonExporter)
+
+// withTypedAttributes renders attributes as a list of {key, type, value}
+// objects instead of a plain key/value map.
+func withTypedAttributes() ndjsonOption {
+	return func(e *ndjsonExporter) {
+		e.typedAttributes = true
+	}
+}
+
+// withRelativeEventTimes renders event times as offsets from the span start
+// instead of absolute timestamps.
+func withRelativeEventTimes() ndjsonOption {
+	return func(e *ndjsonExporter) {
+		e.relativeEventTimes = true
+	}
+}
+
+// withBatchResource writes the resource once per batch, as a record ahead
+// of the spans referencing it by resource_id.
+func withBatchResource() ndjsonOption {
+	return func(e *ndjsonExporter) {
+		e.batchResource = true
+	}
+}
+
+// newNDJSONExporter returns an exporter writing newline delimited JSON to w.
+func newNDJSONExporter(w io.Writer, opts ...ndjsonOption) *ndjsonExporter {
+	e := &ndjsonExporter{enc: json.NewEncoder(w)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+func (e *ndjsonExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil
+	}
+	var resourceIDs []string
+	if e.batchResource {
+		var resources []batchResource
+		resources, resourceIDs = batchResources(spans)
+		for _, r := range resources {
+			err := e.enc.Encode(ndjsonResource{
+				Type:       "resource",
+				ID:         r.ID,
+				SchemaURL:  r.Resource.SchemaURL(),
+				Attributes: ndjsonAttributes(r.Resource.Attributes(), e.typedAttributes),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, s := range spans {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out := toNDJSONSpan(s, e.typedAttributes, e.relativeEventTimes)
+		if resourceIDs != nil {
+			out.ResourceID = resourceIDs[i]
+		}
+		if err := e.enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ndjsonExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopped = true
+	return ctx.Err()
+}
+
+// toNDJSONSpan converts s, rendering attributes as typed objects when
+// typedAttributes is set and event times relative to the span start when
+// relativeEventTimes is set.
+func toNDJSONSpan(s trace.ReadOnlySpan, typedAttributes, relativeEventTimes bool) ndjsonSpan {
+	out := ndjsonSpan{
+		TraceID:    s.SpanContext().TraceID().String(),
+		SpanID:     s.SpanContext().SpanID().String(),
+		Name:       s.Name(),
+		Kind:       s.SpanKind().String(),
+		StartTime:  s.StartTime(),
+		EndTime:    s.EndTime(),
+		Attributes: ndjsonAttributes(s.Attributes(), typedAttributes),
+		Status:     s.Status().Code.String(),
+		Scope: ndjsonScope{
+			Name:    s.InstrumentationScope().Name,
+			Version: s.InstrumentationScope().Version,
+		},
+	}
+	if parent := s.Parent(); parent.HasSpanID() {
+		out.ParentSpanID = parent.SpanID().String()
+	}
+	for _, ev := range s.Events() {
+		var at interface{} = ev.Time
+		if relativeEventTimes {
+			at = "+" + ev.Time.Sub(s.StartTime()).String()
+		}
+		out.Events = append(out.Events, ndjsonEvent{
+			Name:       ev.Name,
+			Time:       at,
+			Attributes: ndjsonAttributes(ev.Attributes, typedAttributes),
+		})
+	}
+	return out
+}
+
+// ndjsonAttributes renders attrs as a typed list or a plain map, or nil
+// when there are none. Attributes are emitted sorted by key so output is
+// stable across runs: encoding/json already sorts map keys, the typed list
+// is sorted here.
+func ndjsonAttributes(attrs []attribute.KeyValue, typed bool) interface{} {
+	if len(attrs) == 0 {
+		return nil
+	}
+	if typed {
+		list := make([]ndjsonAttribute, 0, len(attrs))
+		for _, kv := range attrs {
+			list = append(list, ndjsonAttribute{
+				Key:   string(kv.Key),
+				Type:  kv.Value.Type().String(),
+				Value: jsonValue(kv.Value),
+			})
+		}
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+		return list
+	}
+	plain := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		plain[string(kv.Key)] = jsonValue(kv.Value)
+	}
+	return plain
+}
+
+// jsonValue converts v to its native JSON counterpart. NaN and infinite
+// floats, which encoding/json rejects, are rendered as strings.
+func jsonValue(v attribute.Value) interface{} {
+	switch v.Type() {
+	case attribute.FLOAT64:
+		return jsonFloat(v.AsFloat64())
+	case attribute.FLOAT64SLICE:
+		floats := v.AsFloat64Slice()
+		out := make([]interface{}, len(floats))
+		for i, f := range floats {
+			out[i] = jsonFloat(f)
+		}
+		return out
+	default:
+		return v.AsInterface()
+	}
+}
+
+func jsonFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return f
+}
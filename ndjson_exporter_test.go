@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// testSpanContext returns a sampled span context with the given IDs.
+func testSpanContext(traceID, spanID byte) oteltrace.SpanContext {
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{traceID},
+		SpanID:     oteltrace.SpanID{spanID},
+		TraceFlags: oteltrace.FlagsSampled,
+	})
+}
+
+func TestNDJSONExporter(t *testing.T) {
+	start := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	spans := tracetest.SpanStubs{
+		{
+			Name:        "fibonacci",
+			SpanContext: testSpanContext(1, 2),
+			Parent:      testSpanContext(1, 1),
+			StartTime:   start,
+			EndTime:     start.Add(time.Millisecond),
+			Attributes:  []attribute.KeyValue{attribute.Int64("fib.n", 5)},
+			Events:      []trace.Event{{Name: "cached", Time: start.Add(time.Microsecond)}},
+		},
+		{
+			Name:        "/fibonacci",
+			SpanContext: testSpanContext(1, 1),
+			StartTime:   start,
+			EndTime:     start.Add(2 * time.Millisecond),
+		},
+	}.Snapshots()
+
+	tests := []struct {
+		name  string
+		opts  []ndjsonOption
+		check func(t *testing.T, line map[string]interface{})
+	}{
+		{
+			name: "plain attributes",
+			check: func(t *testing.T, line map[string]interface{}) {
+				if line["name"] == "fibonacci" {
+					attrs, _ := line["attributes"].(map[string]interface{})
+					if attrs["fib.n"] != float64(5) {
+						t.Errorf("attributes = %v, want fib.n=5", line["attributes"])
+					}
+				}
+			},
+		},
+		{
+			name: "typed attributes",
+			opts: []ndjsonOption{withTypedAttributes()},
+			check: func(t *testing.T, line map[string]interface{}) {
+				if line["name"] == "fibonacci" {
+					attrs, _ := line["attributes"].([]interface{})
+					if len(attrs) != 1 || attrs[0].(map[string]interface{})["type"] != "INT64" {
+						t.Errorf("attributes = %v, want one INT64", line["attributes"])
+					}
+				}
+			},
+		},
+		{
+			name: "relative event times",
+			opts: []ndjsonOption{withRelativeEventTimes()},
+			check: func(t *testing.T, line map[string]interface{}) {
+				if line["name"] == "fibonacci" {
+					events, _ := line["events"].([]interface{})
+					if len(events) != 1 || events[0].(map[string]interface{})["time"] != "+1µs" {
+						t.Errorf("events = %v, want one at +1µs", line["events"])
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := newNDJSONExporter(&buf, tt.opts...).ExportSpans(context.Background(), spans); err != nil {
+				t.Fatal(err)
+			}
+
+			var lines int
+			sc := bufio.NewScanner(&buf)
+			for sc.Scan() {
+				lines++
+				var line map[string]interface{}
+				if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+					t.Fatalf("line %d is not a JSON object: %v\n%s", lines, err, sc.Bytes())
+				}
+				for _, key := range []string{"trace_id", "span_id", "name", "start_time", "end_time"} {
+					if _, ok := line[key]; !ok {
+						t.Errorf("line %d has no %s: %s", lines, key, sc.Bytes())
+					}
+				}
+				tt.check(t, line)
+			}
+			if lines != len(spans) {
+				t.Errorf("got %d lines, want %d", lines, len(spans))
+			}
+		})
+	}
+}
+
+func TestNDJSONExporterShutdown(t *testing.T) {
+	var buf bytes.Buffer
+	e := newNDJSONExporter(&buf)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "late", SpanContext: testSpanContext(1, 1)}}.Snapshots()
+	if err := e.ExportSpans(context.Background(), spans); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q after shutdown", buf.String())
+	}
+}
+
+func TestNDJSONTypedAttributes(t *testing.T) {
+	tests := []struct {
+		attr     attribute.KeyValue
+		wantType string
+		// wantJSON is the value as encoding/json renders it.
+		wantJSON string
+	}{
+		{attribute.Bool("b", true), "BOOL", `true`},
+		{attribute.Int64("i", -7), "INT64", `-7`},
+		{attribute.Float64("f", 1.5), "FLOAT64", `1.5`},
+		{attribute.Float64("nan", math.NaN()), "FLOAT64", `"NaN"`},
+		{attribute.Float64("inf", math.Inf(1)), "FLOAT64", `"+Inf"`},
+		{attribute.String("s", "42"), "STRING", `"42"`},
+		{attribute.BoolSlice("bs", []bool{true, false}), "BOOLSLICE", `[true,false]`},
+		{attribute.Int64Slice("is", []int64{1, 2}), "INT64SLICE", `[1,2]`},
+		{attribute.Float64Slice("fs", []float64{0.5, math.Inf(-1)}), "FLOAT64SLICE", `[0.5,"-Inf"]`},
+		{attribute.StringSlice("ss", []string{"a", "1"}), "STRINGSLICE", `["a","1"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantType+"/"+string(tt.attr.Key), func(t *testing.T) {
+			var buf bytes.Buffer
+			spans := tracetest.SpanStubs{{
+				Name:        "span",
+				SpanContext: testSpanContext(1, 1),
+				Attributes:  []attribute.KeyValue{tt.attr},
+			}}.Snapshots()
+			if err := newNDJSONExporter(&buf, withTypedAttributes()).ExportSpans(context.Background(), spans); err != nil {
+				t.Fatal(err)
+			}
+
+			var line struct {
+				Attributes []struct {
+					Key   string          `json:"key"`
+					Type  string          `json:"type"`
+					Value json.RawMessage `json:"value"`
+				} `json:"attributes"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("%v: %s", err, buf.Bytes())
+			}
+			if len(line.Attributes) != 1 {
+				t.Fatalf("got %d attributes, want 1: %s", len(line.Attributes), buf.Bytes())
+			}
+			got := line.Attributes[0]
+			if got.Key != string(tt.attr.Key) || got.Type != tt.wantType || string(got.Value) != tt.wantJSON {
+				t.Errorf("got {%s %s %s}, want {%s %s %s}", got.Key, got.Type, got.Value, tt.attr.Key, tt.wantType, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestNDJSONSortedAttributes(t *testing.T) {
+	keys := []string{"http.route", "fib.n", "zeta", "fib.mode", "app.tenant"}
+	tests := []struct {
+		name string
+		opts []ndjsonOption
+	}{
+		{name: "plain"},
+		{name: "typed", opts: []ndjsonOption{withTypedAttributes()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The same attributes in two insertion orders must render
+			// identically.
+			var outputs []string
+			for _, order := range [][]string{keys, {"zeta", "app.tenant", "fib.mode", "http.route", "fib.n"}} {
+				attrs := make([]attribute.KeyValue, 0, len(order))
+				for _, k := range order {
+					attrs = append(attrs, attribute.String(k, "v"))
+				}
+				spans := tracetest.SpanStubs{{
+					Name:        "span",
+					SpanContext: testSpanContext(1, 1),
+					Attributes:  attrs,
+					Events:      []trace.Event{{Name: "event", Attributes: attrs}},
+				}}.Snapshots()
+				var buf bytes.Buffer
+				if err := newNDJSONExporter(&buf, tt.opts...).ExportSpans(context.Background(), spans); err != nil {
+					t.Fatal(err)
+				}
+				outputs = append(outputs, buf.String())
+			}
+			if outputs[0] != outputs[1] {
+				t.Errorf("output depends on insertion order:\n%s%s", outputs[0], outputs[1])
+			}
+
+			line := outputs[0]
+			prev := -1
+			for _, k := range []string{"app.tenant", "fib.mode", "fib.n", "http.route", "zeta"} {
+				i := strings.Index(line, `"`+k+`"`)
+				if i < prev {
+					t.Errorf("%s emitted out of order: %s", k, line)
+				}
+				prev = i
+			}
+		})
+	}
+}
+
+func TestNDJSONRelativeEventTimes(t *testing.T) {
+	start := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		offset   time.Duration
+		relative bool
+		want     string
+	}{
+		{name: "at start", offset: 0, relative: true, want: `"+0s"`},
+		{name: "sub-millisecond", offset: 250 * time.Microsecond, relative: true, want: `"+250µs"`},
+		{name: "milliseconds", offset: 1200 * time.Microsecond, relative: true, want: `"+1.2ms"`},
+		{name: "seconds", offset: 1500 * time.Millisecond, relative: true, want: `"+1.5s"`},
+		{name: "absolute by default", offset: 1200 * time.Microsecond, want: `"2023-01-02T03:04:05.0012Z"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []ndjsonOption
+			if tt.relative {
+				opts = append(opts, withRelativeEventTimes())
+			}
+			spans := tracetest.SpanStubs{{
+				Name:        "span",
+				SpanContext: testSpanContext(1, 1),
+				StartTime:   start,
+				EndTime:     start.Add(2 * time.Second),
+				Events:      []trace.Event{{Name: "event", Time: start.Add(tt.offset)}},
+			}}.Snapshots()
+			var buf bytes.Buffer
+			if err := newNDJSONExporter(&buf, opts...).ExportSpans(context.Background(), spans); err != nil {
+				t.Fatal(err)
+			}
+
+			var line struct {
+				Events []struct {
+					Time json.RawMessage `json:"time"`
+				} `json:"events"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("%v: %s", err, buf.Bytes())
+			}
+			if len(line.Events) != 1 || string(line.Events[0].Time) != tt.want {
+				t.Errorf("events = %s, want one at %s", buf.Bytes(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// overflowWatch flags requests whose n is within margin of maxUint64FibN,
+// i.e. close to overflowing a uint64, before they actually overflow.
+type overflowWatch struct {
+	margin  uint64
+	counter prometheus.Counter
+	// partial makes overflow responses carry the largest result that
+	// still fits, see WritePartial.
+	partial bool
+}
+
+func newOverflowWatch(margin uint64) *overflowWatch {
+	return &overflowWatch{
+		margin: margin,
+		counter: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "fibonacci_overflow_risk_total",
+			Help: "Requests whose n is close to overflowing uint64.",
+		}),
+	}
+}
+
+// Check counts n as risky and adds an event to span when n is close to,
+// but not past, the overflow threshold. Big mode never overflows.
+func (w *overflowWatch) Check(span oteltrace.Span, mode fibMode, n uint64) {
+	if mode == fibModeBig || n > maxUint64FibN || n+w.margin < maxUint64FibN {
+		return
+	}
+	w.counter.Inc()
+	span.AddEvent("fibonacci.overflow_risk", oteltrace.WithAttributes(
+		attrKey("fib.n").Int64(int64(n)),
+		attrKey("fib.overflow_threshold").Int64(maxUint64FibN),
+	))
+}
+
+// overflowPartial is the body of an overflow response with partial results.
+type overflowPartial struct {
+	Error      string `json:"error"`
+	N          uint64 `json:"n"`
+	LargestN   uint64 `json:"largest_n"`
+	LargestFib string `json:"largest_fib"`
+}
+
+// WritePartial answers a request for n that overflowed with the largest
+// fibonacci number that fits in a uint64, also recording it on span. It
+// does nothing and returns false unless partial results are enabled.
+func (w *overflowWatch) WritePartial(resp http.ResponseWriter, span oteltrace.Span, n uint64) bool {
+	if !w.partial {
+		return false
+	}
+	largest := strconv.FormatUint(fibUint64(maxUint64FibN), 10)
+	span.SetAttributes(
+		attrKey("fib.partial_n").Int64(maxUint64FibN),
+		attrKey("fib.partial_result").String(largest),
+	)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(computeErrorStatus(errFibonacciOverflow))
+	json.NewEncoder(resp).Encode(overflowPartial{
+		Error:      errFibonacciOverflow.Error(),
+		N:          n,
+		LargestN:   maxUint64FibN,
+		LargestFib: largest,
+	})
+	return true
+}
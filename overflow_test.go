@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestOverflowWatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		n         string
+		wantRisk  float64
+		wantEvent bool
+	}{
+		{name: "safe", n: "10"},
+		{name: "within margin", n: "88", wantRisk: 1, wantEvent: true},
+		{name: "at threshold", n: "93", wantRisk: 1, wantEvent: true},
+		{name: "already overflowing", n: "94"},
+		{name: "big mode never overflows", mode: "big", n: "88"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			h.overflow = newOverflowWatch(5)
+			target := "/fibonacci?n=" + tt.n
+			if tt.mode != "" {
+				target += "&mode=" + tt.mode
+			}
+			serveFibonacci(t, h, target)
+
+			if got := testutil.ToFloat64(h.overflow.counter); got != tt.wantRisk {
+				t.Errorf("fibonacci_overflow_risk_total = %v, want %v", got, tt.wantRisk)
+			}
+			var gotEvent bool
+			for _, s := range rec.Ended() {
+				for _, ev := range s.Events() {
+					gotEvent = gotEvent || ev.Name == "fibonacci.overflow_risk"
+				}
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("overflow risk event = %v, want %v", gotEvent, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestOverflowWatchPartial(t *testing.T) {
+	const largest = "12200160415121876738" // fib(93)
+	tests := []struct {
+		name    string
+		partial bool
+		target  string
+		want    *overflowPartial
+	}{
+		{name: "disabled", target: "/fibonacci?n=100"},
+		{
+			name:    "enabled",
+			partial: true,
+			target:  "/fibonacci?n=100",
+			want:    &overflowPartial{Error: errFibonacciOverflow.Error(), N: 100, LargestN: maxUint64FibN, LargestFib: largest},
+		},
+		{
+			name:    "enabled with memo mode",
+			partial: true,
+			target:  "/fibonacci?n=94&mode=memo",
+			want:    &overflowPartial{Error: errFibonacciOverflow.Error(), N: 94, LargestN: maxUint64FibN, LargestFib: largest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			h := newTestFibonacciHandler()
+			h.overflow.partial = tt.partial
+			resp, _ := serveFibonacci(t, h, tt.target)
+
+			if want := computeErrorStatus(errFibonacciOverflow); resp.Code != want {
+				t.Errorf("status = %d, want %d", resp.Code, want)
+			}
+			var root trace.ReadOnlySpan
+			for _, s := range rec.Ended() {
+				if s.Name() == "/fibonacci" {
+					root = s
+				}
+			}
+			if got := spanAttr(root, attrKey("fib.partial_result")); (got == largest) != (tt.want != nil) {
+				t.Errorf("fib.partial_result = %q, want partial %v", got, tt.want != nil)
+			}
+			if tt.want == nil {
+				if strings.Contains(resp.Body.String(), "largest_fib") {
+					t.Errorf("body = %s, want no partial result", resp.Body)
+				}
+				return
+			}
+			var body overflowPartial
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%v: %s", err, resp.Body)
+			}
+			if body != *tt.want {
+				t.Errorf("body = %+v, want %+v", body, *tt.want)
+			}
+			if got := spanAttr(root, attrKey("fib.partial_n")); got != "93" {
+				t.Errorf("fib.partial_n = %q, want 93", got)
+			}
+		})
+	}
+}
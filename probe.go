@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// healthHandler answers liveness/readiness probes.
+type healthHandler struct{}
+
+func (s *healthHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.WriteHeader(http.StatusOK)
+	resp.Write([]byte("ok"))
+}
+
+// probeRoutes are the health check routes, whose root spans are sampled
+// by probeSampler and left without span tags by default.
+var probeRoutes = []string{"/healthz"}
+
+type probeKey struct{}
+
+// withProbe marks ctx as belonging to a probe request.
+func withProbe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, probeKey{}, true)
+}
+
+// probeSampler samples root spans of probe requests with its own, usually
+// tiny, ratio so probes stay visible without flooding the backend. Other
+// spans, including children of probe spans, go to next.
+type probeSampler struct {
+	next  trace.Sampler
+	probe trace.Sampler
+}
+
+var _ trace.Sampler = probeSampler{}
+
+// newProbeSampler samples probe root spans at ratio; 0 drops them all.
+func newProbeSampler(next trace.Sampler, ratio float64) probeSampler {
+	return probeSampler{next: next, probe: trace.TraceIDRatioBased(ratio)}
+}
+
+func (s probeSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if p.ParentContext != nil && p.ParentContext.Value(probeKey{}) != nil &&
+		!oteltrace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return s.probe.ShouldSample(p)
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s probeSampler) Description() string {
+	return s.next.Description()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestProbeSampler(t *testing.T) {
+	const requests = 2000
+	tests := []struct {
+		name  string
+		route string
+		ratio float64
+		// disabled turns tracing of route off, as TRACED_ROUTES can.
+		disabled bool
+		// want is the expected fraction of sampled requests.
+		want float64
+	}{
+		{name: "probe dropped by default", route: "/healthz", want: 0},
+		{name: "probe at low ratio", route: "/healthz", ratio: 0.05, want: 0.05},
+		{name: "other route unaffected", route: "/fibonacci", ratio: 0.05, want: 1},
+		{name: "disabled probe route dropped", route: "/healthz", ratio: 1, disabled: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Composed as in main, with the route switch winning.
+			sampler := suppressSampler{next: newProbeSampler(trace.AlwaysSample(), tt.ratio)}
+			rec := useTestTracerProvider(t, trace.WithSampler(sampler))
+			m := newTestMiddleware(t)
+			m.probes["/healthz"] = true
+			if tt.disabled {
+				m.routes = newRouteToggle([]string{"/fibonacci"})
+			}
+			handler := m.Handle(tt.route, &healthHandler{})
+			for i := 0; i < requests; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.route, nil))
+			}
+
+			got := float64(len(rec.Ended())) / requests
+			// Five standard deviations of the binomial distribution.
+			tolerance := 5 * math.Sqrt(tt.want*(1-tt.want)/requests)
+			if math.Abs(got-tt.want) > tolerance {
+				t.Errorf("sampled fraction = %.3f, want %.3f±%.3f", got, tt.want, tolerance)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// propagationHandler echoes the trace context the configured propagators
+// extract from the request headers, to debug why context doesn't make it
+// across a hop. It runs the extraction itself instead of being traced, so
+// the answer is what an incoming request would continue from.
+type propagationHandler struct{}
+
+// extractedContext is the body of a propagationHandler response.
+type extractedContext struct {
+	// Fields are the headers the configured propagators read.
+	Fields     []string          `json:"fields"`
+	Valid      bool              `json:"valid"`
+	TraceID    string            `json:"trace_id,omitempty"`
+	SpanID     string            `json:"span_id,omitempty"`
+	Sampled    bool              `json:"sampled"`
+	TraceState string            `json:"trace_state,omitempty"`
+	Baggage    map[string]string `json:"baggage"`
+}
+
+func (s *propagationHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	propagator := otel.GetTextMapPropagator()
+	ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	sc := oteltrace.SpanContextFromContext(ctx)
+	out := extractedContext{
+		Fields:     propagator.Fields(),
+		Valid:      sc.IsValid(),
+		Sampled:    sc.IsSampled(),
+		TraceState: sc.TraceState().String(),
+		Baggage:    map[string]string{},
+	}
+	if sc.IsValid() {
+		out.TraceID = sc.TraceID().String()
+		out.SpanID = sc.SpanID().String()
+	}
+	for _, m := range baggage.FromContext(ctx).Members() {
+		out.Baggage[m.Key()] = m.Value()
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(out)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPropagationHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		propagators []string
+		header      map[string]string
+		want        extractedContext
+	}{
+		{
+			name:        "traceparent sampled",
+			propagators: []string{"tracecontext", "baggage"},
+			header:      map[string]string{"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01"},
+			want:        extractedContext{Valid: true, TraceID: testTraceID, SpanID: testSpanID, Sampled: true, Baggage: map[string]string{}},
+		},
+		{
+			name:        "traceparent not sampled with tracestate and baggage",
+			propagators: []string{"tracecontext", "baggage"},
+			header: map[string]string{
+				"traceparent": "00-" + testTraceID + "-" + testSpanID + "-00",
+				"tracestate":  "vendor=value",
+				"baggage":     "tenant=acme,user=42",
+			},
+			want: extractedContext{
+				Valid:      true,
+				TraceID:    testTraceID,
+				SpanID:     testSpanID,
+				TraceState: "vendor=value",
+				Baggage:    map[string]string{"tenant": "acme", "user": "42"},
+			},
+		},
+		{
+			name:        "b3 configured",
+			propagators: []string{"b3"},
+			header:      map[string]string{"b3": testTraceID + "-" + testSpanID + "-1"},
+			want:        extractedContext{Valid: true, TraceID: testTraceID, SpanID: testSpanID, Sampled: true, Baggage: map[string]string{}},
+		},
+		{
+			name:        "traceparent ignored without tracecontext",
+			propagators: []string{"b3"},
+			header:      map[string]string{"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01"},
+			want:        extractedContext{Baggage: map[string]string{}},
+		},
+		{
+			name:        "malformed traceparent",
+			propagators: []string{"tracecontext"},
+			header:      map[string]string{"traceparent": "00-nothex-" + testSpanID + "-01"},
+			want:        extractedContext{Baggage: map[string]string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prop, err := newPropagator(tt.propagators)
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTestPropagator(t, prop)
+			req := httptest.NewRequest(http.MethodGet, "/debug/propagation", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+			(&propagationHandler{}).ServeHTTP(resp, req)
+
+			var got extractedContext
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatalf("%v: %s", err, resp.Body)
+			}
+			// Some propagators list their fields in map order.
+			wantFields := prop.Fields()
+			sort.Strings(got.Fields)
+			sort.Strings(wantFields)
+			if !reflect.DeepEqual(got.Fields, wantFields) {
+				t.Errorf("fields = %v, want %v", got.Fields, wantFields)
+			}
+			got.Fields = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("echoed %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
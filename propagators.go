@@ -0,0 +1,32 @@
+package main
+
+import (
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// newPropagator builds a composite propagator from format names as listed in
+// the PROPAGATORS env var. "b3" extracts both the single and multi header
+// encodings and injects the single header. "jaeger" reads and writes
+// uber-trace-id, mapping its flags byte to the sampled/debug trace flags.
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	var props []propagation.TextMapPropagator
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			props = append(props, propagation.TraceContext{})
+		case "baggage":
+			props = append(props, propagation.Baggage{})
+		case "b3":
+			props = append(props, b3.New())
+		case "b3multi":
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			props = append(props, jaeger.Jaeger{})
+		default:
+			return nil, newConfigError(ErrInvalidPropagator, nil, "unknown propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+// extractAndStart extracts the context in header with the propagator
+// configured by names and starts a local span under it.
+func extractAndStart(t *testing.T, names []string, header http.Header) oteltrace.SpanContext {
+	t.Helper()
+	rec := useTestTracerProvider(t)
+	prop, err := newPropagator(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(header))
+	_, span := tracer("test").Start(ctx, "local")
+	span.End()
+	ended := rec.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans, want 1", len(ended))
+	}
+	if got := ended[0].Parent().SpanID().String(); got != testSpanID {
+		t.Errorf("parent span ID = %s, want %s", got, testSpanID)
+	}
+	return ended[0].SpanContext()
+}
+
+func TestNewPropagatorB3(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		header map[string]string
+	}{
+		{
+			name:   "single header",
+			names:  []string{"tracecontext", "baggage", "b3"},
+			header: map[string]string{"b3": testTraceID + "-" + testSpanID + "-1"},
+		},
+		{
+			name:  "multi header",
+			names: []string{"tracecontext", "baggage", "b3"},
+			header: map[string]string{
+				"X-B3-TraceId": testTraceID,
+				"X-B3-SpanId":  testSpanID,
+				"X-B3-Sampled": "1",
+			},
+		},
+		{
+			name:  "multi header with b3multi",
+			names: []string{"b3multi"},
+			header: map[string]string{
+				"X-B3-TraceId": testTraceID,
+				"X-B3-SpanId":  testSpanID,
+				"X-B3-Sampled": "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			sc := extractAndStart(t, tt.names, header)
+			if got := sc.TraceID().String(); got != testTraceID {
+				t.Errorf("trace ID = %s, want %s", got, testTraceID)
+			}
+		})
+	}
+}
+
+func TestNewPropagatorUnknown(t *testing.T) {
+	if _, err := newPropagator([]string{"tracecontext", "xray"}); !errors.Is(err, ErrInvalidPropagator) {
+		t.Errorf("err = %v, want ErrInvalidPropagator", err)
+	}
+}
+
+func TestNewPropagatorJaeger(t *testing.T) {
+	tests := []struct {
+		name        string
+		flags       string
+		wantSampled bool
+		// wantFlags is the flags field injected downstream.
+		wantFlags string
+	}{
+		{name: "sampled", flags: "1", wantSampled: true, wantFlags: "1"},
+		{name: "not sampled", flags: "0", wantSampled: false, wantFlags: "0"},
+		{name: "debug alone is not sampled", flags: "2", wantSampled: false, wantFlags: "0"},
+		{name: "debug and sampled", flags: "3", wantSampled: true, wantFlags: "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prop, err := newPropagator([]string{"tracecontext", "jaeger"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			header.Set("uber-trace-id", testTraceID+":"+testSpanID+":0:"+tt.flags)
+			ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(header))
+
+			remote := oteltrace.SpanContextFromContext(ctx)
+			if got := remote.TraceID().String(); got != testTraceID {
+				t.Errorf("trace ID = %s, want %s", got, testTraceID)
+			}
+			if remote.IsSampled() != tt.wantSampled {
+				t.Errorf("sampled = %v, want %v", remote.IsSampled(), tt.wantSampled)
+			}
+			out := http.Header{}
+			prop.Inject(ctx, propagation.HeaderCarrier(out))
+			if got, want := out.Get("uber-trace-id"), testTraceID+":"+testSpanID+":0:"+tt.wantFlags; got != want {
+				t.Errorf("injected uber-trace-id = %q, want %q", got, want)
+			}
+			if tt.wantSampled {
+				sc := extractAndStart(t, []string{"jaeger"}, header)
+				if got := sc.TraceID().String(); got != testTraceID {
+					t.Errorf("local span trace ID = %s, want %s", got, testTraceID)
+				}
+			}
+		})
+	}
+}
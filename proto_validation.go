@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// validatingExporter checks every span exported through next survives an
+// OTLP protobuf encode/decode unchanged, counting and logging the ones that
+// don't (invalid UTF-8 strings, for instance, which strict backends reject).
+// Validation happens after the export and never affects its result.
+type validatingExporter struct {
+	next     trace.SpanExporter
+	failures prometheus.Counter
+}
+
+var _ trace.SpanExporter = (*validatingExporter)(nil)
+
+func newValidatingExporter(next trace.SpanExporter, failures prometheus.Counter) *validatingExporter {
+	return &validatingExporter{next: next, failures: failures}
+}
+
+func (e *validatingExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	err := e.next.ExportSpans(ctx, spans)
+	for _, s := range spans {
+		if verr := validateOTLPSpan(s); verr != nil {
+			e.failures.Inc()
+			log.Printf("span %q (%s) fails OTLP validation: %v", s.Name(), s.SpanContext().SpanID(), verr)
+		}
+	}
+	return err
+}
+
+func (e *validatingExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
+
+// validateOTLPSpan encodes the parts of s a backend would store and checks
+// they decode to the same message.
+func validateOTLPSpan(s trace.ReadOnlySpan) error {
+	traceID, spanID := s.SpanContext().TraceID(), s.SpanContext().SpanID()
+	msg := &tracepb.Span{
+		TraceId:           traceID[:],
+		SpanId:            spanID[:],
+		Name:              s.Name(),
+		StartTimeUnixNano: uint64(s.StartTime().UnixNano()),
+		EndTimeUnixNano:   uint64(s.EndTime().UnixNano()),
+		Attributes:        otlpAttributes(s.Attributes()),
+		Status:            &tracepb.Status{Message: s.Status().Description},
+	}
+	for _, ev := range s.Events() {
+		msg.Events = append(msg.Events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(ev.Time.UnixNano()),
+			Name:         ev.Name,
+			Attributes:   otlpAttributes(ev.Attributes),
+		})
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	var got tracepb.Span
+	if err := proto.Unmarshal(b, &got); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	if !proto.Equal(msg, &got) {
+		return fmt.Errorf("span changed in round trip")
+	}
+	return nil
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: otlpValue(kv.Value)})
+	}
+	return out
+}
+
+func otlpValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.BOOLSLICE:
+		var vs []*commonpb.AnyValue
+		for _, b := range v.AsBoolSlice() {
+			vs = append(vs, otlpValue(attribute.BoolValue(b)))
+		}
+		return otlpArray(vs)
+	case attribute.INT64SLICE:
+		var vs []*commonpb.AnyValue
+		for _, i := range v.AsInt64Slice() {
+			vs = append(vs, otlpValue(attribute.Int64Value(i)))
+		}
+		return otlpArray(vs)
+	case attribute.FLOAT64SLICE:
+		var vs []*commonpb.AnyValue
+		for _, f := range v.AsFloat64Slice() {
+			vs = append(vs, otlpValue(attribute.Float64Value(f)))
+		}
+		return otlpArray(vs)
+	case attribute.STRINGSLICE:
+		var vs []*commonpb.AnyValue
+		for _, s := range v.AsStringSlice() {
+			vs = append(vs, otlpValue(attribute.StringValue(s)))
+		}
+		return otlpArray(vs)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+	}
+}
+
+func otlpArray(vs []*commonpb.AnyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: vs}}}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestValidatingExporter(t *testing.T) {
+	const invalidUTF8 = "caf\xe9"
+	tests := []struct {
+		name        string
+		span        tracetest.SpanStub
+		wantFlagged bool
+	}{
+		{
+			name: "valid",
+			span: tracetest.SpanStub{Name: "fibonacci", Attributes: []attribute.KeyValue{
+				attribute.Int64("fib.n", 7),
+				attribute.String("fib.mode", "iter"),
+				attribute.Float64Slice("ratios", []float64{0.5, 1}),
+				attribute.BoolSlice("flags", []bool{true}),
+			}},
+		},
+		{
+			name:        "invalid utf-8 attribute",
+			span:        tracetest.SpanStub{Name: "fibonacci", Attributes: []attribute.KeyValue{attribute.String("user_agent.original", invalidUTF8)}},
+			wantFlagged: true,
+		},
+		{
+			name:        "invalid utf-8 in string slice",
+			span:        tracetest.SpanStub{Name: "fibonacci", Attributes: []attribute.KeyValue{attribute.StringSlice("hops", []string{"ok", invalidUTF8})}},
+			wantFlagged: true,
+		},
+		{
+			name: "invalid utf-8 event attribute",
+			span: tracetest.SpanStub{Name: "fibonacci", Events: []trace.Event{{
+				Name:       "cache.evict",
+				Attributes: []attribute.KeyValue{attribute.String("key", invalidUTF8)},
+			}}},
+			wantFlagged: true,
+		},
+		{name: "invalid utf-8 name", span: tracetest.SpanStub{Name: invalidUTF8}, wantFlagged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			failures := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_validation_failures"})
+			stub := &stubExporter{}
+			e := newValidatingExporter(stub, failures)
+			tt.span.SpanContext = testSpanContext(1, 1)
+			if err := e.ExportSpans(context.Background(), tracetest.SpanStubs{tt.span}.Snapshots()); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(stub.spans()) != 1 {
+				t.Errorf("next got %d spans, want 1 whatever the validation result", len(stub.spans()))
+			}
+			want := 0.0
+			if tt.wantFlagged {
+				want = 1
+			}
+			if got := testutil.ToFloat64(failures); got != want {
+				t.Errorf("failures = %v, want %v", got, want)
+			}
+			if logged := strings.Contains(logs.String(), "fails OTLP validation"); logged != tt.wantFlagged {
+				t.Errorf("logged = %v, want %v: %s", logged, tt.wantFlagged, logs)
+			}
+		})
+	}
+}
+
+func TestValidatingExporterPassesErrors(t *testing.T) {
+	failed := errors.New("collector unavailable")
+	e := newValidatingExporter(&stubExporter{err: failed}, prometheus.NewCounter(prometheus.CounterOpts{Name: "test_validation_failures"}))
+	if err := e.ExportSpans(context.Background(), testSpans(1)); err != failed {
+		t.Errorf("err = %v, want %v", err, failed)
+	}
+}
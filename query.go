@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// parseSingleValueQuery parses req's query string strictly: malformed
+// encodings are an error rather than silently dropped as by URL.Query, and
+// so is any of keys appearing more than once, since there is no telling
+// which value the caller meant. On error the raw query and the error are
+// recorded on span.
+func parseSingleValueQuery(req *http.Request, span oteltrace.Span, keys ...string) (url.Values, error) {
+	q, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		err = fmt.Errorf("malformed query string: %w", err)
+	} else {
+		for _, k := range keys {
+			if len(q[k]) > 1 {
+				err = fmt.Errorf("query parameter %s given %d times, expected once", k, len(q[k]))
+				break
+			}
+		}
+	}
+	if err != nil {
+		span.SetAttributes(attribute.String("url.query", req.URL.RawQuery))
+		span.RecordError(err)
+		return nil, err
+	}
+	return q, nil
+}
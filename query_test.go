@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestFibonacciHandlerQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		// wantBody is a substring of the response body.
+		wantBody string
+		// wantRecorded means url.query and an exception are on the span.
+		wantRecorded bool
+	}{
+		{name: "single n", query: "n=10", wantStatus: http.StatusOK, wantBody: "55"},
+		{name: "encoded n", query: "n=%31%30", wantStatus: http.StatusOK, wantBody: "55"},
+		{name: "unrelated key repeated", query: "n=10&x=1&x=2", wantStatus: http.StatusOK, wantBody: "55"},
+		{
+			name: "repeated n", query: "n=1&n=2",
+			wantStatus: http.StatusBadRequest, wantBody: "n given 2 times", wantRecorded: true,
+		},
+		{
+			name: "repeated identical n", query: "n=5&n=5",
+			wantStatus: http.StatusBadRequest, wantBody: "n given 2 times", wantRecorded: true,
+		},
+		{
+			name: "repeated mode", query: "n=5&mode=iter&mode=memo",
+			wantStatus: http.StatusBadRequest, wantBody: "mode given 2 times", wantRecorded: true,
+		},
+		{
+			name: "bad percent encoding", query: "n=%zz",
+			wantStatus: http.StatusBadRequest, wantBody: "malformed query string", wantRecorded: true,
+		},
+		{
+			name: "truncated percent encoding", query: "n=1%",
+			wantStatus: http.StatusBadRequest, wantBody: "malformed query string", wantRecorded: true,
+		},
+		{
+			name: "semicolon separator", query: "n=1;n=2",
+			wantStatus: http.StatusBadRequest, wantBody: "malformed query string", wantRecorded: true,
+		},
+		{
+			name: "not a number", query: "n=ten",
+			wantStatus: http.StatusBadRequest, wantBody: "non-negative integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			resp, _ := serveFibonacci(t, newTestFibonacciHandler(), "/fibonacci?"+tt.query)
+
+			if resp.Code != tt.wantStatus || !strings.Contains(resp.Body.String(), tt.wantBody) {
+				t.Errorf("got %d %q, want %d containing %q", resp.Code, resp.Body, tt.wantStatus, tt.wantBody)
+			}
+			var found bool
+			for _, s := range rec.Ended() {
+				if s.Name() != "/fibonacci" {
+					continue
+				}
+				found = true
+				wantQuery := ""
+				if tt.wantRecorded {
+					wantQuery = tt.query
+				}
+				if got := spanAttr(s, "url.query"); got != wantQuery {
+					t.Errorf("url.query = %q, want %q", got, wantQuery)
+				}
+				var recorded bool
+				for _, ev := range s.Events() {
+					recorded = recorded || ev.Name == semconv.ExceptionEventName
+				}
+				if recorded != tt.wantRecorded {
+					t.Errorf("exception event = %v, want %v", recorded, tt.wantRecorded)
+				}
+			}
+			if !found {
+				t.Fatal("no root span recorded")
+			}
+		})
+	}
+}
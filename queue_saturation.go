@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// queueTracker estimates how full the batch span processor's queue is. The
+// SDK doesn't expose the queue depth, so it counts sampled spans as they
+// end (its processor half) and subtracts them as batches reach the
+// exporter (its exporter half, which must be the exporter handed to the
+// batcher). Batches being assembled or exported are counted too, so the
+// estimate errs high by at most one batch. Spans ending while the estimate
+// is at capacity are assumed dropped by the batcher and not counted, which
+// keeps drops from skewing it.
+type queueTracker struct {
+	next     trace.SpanExporter
+	capacity int64
+	pending  atomic.Int64
+	gauge    prometheus.Gauge
+}
+
+var (
+	_ trace.SpanExporter  = (*queueTracker)(nil)
+	_ trace.SpanProcessor = (*queueTrackerProcessor)(nil)
+)
+
+func newQueueTracker(next trace.SpanExporter, capacity int) *queueTracker {
+	return &queueTracker{
+		next:     next,
+		capacity: int64(capacity),
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "otel_span_queue_saturation_ratio",
+			Help: "Estimated fill ratio of the span batch queue, from 0 to 1.",
+		}),
+	}
+}
+
+func (t *queueTracker) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	err := t.next.ExportSpans(ctx, spans)
+	if t.pending.Add(-int64(len(spans))) < 0 {
+		t.pending.Store(0)
+	}
+	return err
+}
+
+func (t *queueTracker) Shutdown(ctx context.Context) error {
+	return t.next.Shutdown(ctx)
+}
+
+// Processor returns the span processor half of t.
+func (t *queueTracker) Processor() trace.SpanProcessor {
+	return (*queueTrackerProcessor)(t)
+}
+
+// Ratio returns the estimated queue fill ratio.
+func (t *queueTracker) Ratio() float64 {
+	return float64(t.pending.Load()) / float64(t.capacity)
+}
+
+// Run updates the gauge every interval until ctx is done.
+func (t *queueTracker) Run(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			t.gauge.Set(t.Ratio())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+type queueTrackerProcessor queueTracker
+
+func (p *queueTrackerProcessor) OnStart(context.Context, trace.ReadWriteSpan) {}
+
+func (p *queueTrackerProcessor) OnEnd(s trace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	for {
+		n := p.pending.Load()
+		if n >= p.capacity || p.pending.CompareAndSwap(n, n+1) {
+			return
+		}
+	}
+}
+
+func (p *queueTrackerProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *queueTrackerProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueueSaturationGauge(t *testing.T) {
+	const capacity = 8
+	tests := []struct {
+		name  string
+		ended int
+		want  float64
+	}{
+		{name: "empty", ended: 0, want: 0},
+		{name: "quarter", ended: 2, want: 0.25},
+		{name: "half", ended: 4, want: 0.5},
+		{name: "full", ended: capacity, want: 1},
+		{name: "overfull", ended: 3 * capacity, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The exporter hangs, so the batcher never drains the queue.
+			exp := hungExporter{release: make(chan struct{})}
+			queue := newQueueTracker(exp, capacity)
+			tp := trace.NewTracerProvider(
+				trace.WithSampler(trace.AlwaysSample()),
+				trace.WithSpanProcessor(queue.Processor()),
+				trace.WithBatcher(queue,
+					trace.WithMaxQueueSize(capacity),
+					trace.WithMaxExportBatchSize(2),
+					trace.WithBatchTimeout(time.Millisecond),
+				),
+			)
+			t.Cleanup(func() { tp.Shutdown(context.Background()) })
+			t.Cleanup(func() { close(exp.release) })
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			queue.Run(ctx, &wg, time.Millisecond)
+
+			var prev float64
+			for i := 0; i < tt.ended; i++ {
+				_, span := tp.Tracer("test").Start(context.Background(), "span")
+				span.End()
+				got := queue.Ratio()
+				if got < prev || got > 1 {
+					t.Fatalf("after %d spans ratio = %v, want in [%v, 1]", i+1, got, prev)
+				}
+				prev = got
+			}
+			if got := queue.Ratio(); got != tt.want {
+				t.Errorf("Ratio = %v, want %v", got, tt.want)
+			}
+
+			deadline := time.Now().Add(2 * time.Second)
+			for testutil.ToFloat64(queue.gauge) != tt.want && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if got := testutil.ToFloat64(queue.gauge); got != tt.want {
+				t.Errorf("otel_span_queue_saturation_ratio = %v, want %v", got, tt.want)
+			}
+
+			// The updater stops with its context, as on shutdown.
+			cancel()
+			done := make(chan struct{})
+			go func() { wg.Wait(); close(done) }()
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Error("gauge updater still running after cancel")
+			}
+		})
+	}
+}
+
+func TestQueueTrackerDrains(t *testing.T) {
+	tests := []struct {
+		name     string
+		sampled  bool
+		wantPeak float64
+	}{
+		{name: "sampled", sampled: true, wantPeak: 0.5},
+		{name: "unsampled not counted", sampled: false, wantPeak: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := &stubExporter{}
+			queue := newQueueTracker(exp, 4)
+			stubs := tracetest.SpanStubs{
+				{Name: "a", SpanContext: testSpanContext(1, 1)},
+				{Name: "b", SpanContext: testSpanContext(1, 2)},
+			}
+			for i := range stubs {
+				if !tt.sampled {
+					stubs[i].SpanContext = stubs[i].SpanContext.WithTraceFlags(0)
+				}
+			}
+			for _, s := range stubs.Snapshots() {
+				queue.Processor().OnEnd(s)
+			}
+			if got := queue.Ratio(); got != tt.wantPeak {
+				t.Errorf("Ratio after ending = %v, want %v", got, tt.wantPeak)
+			}
+			// Exporting a batch, even one the processor did not count,
+			// never drives the estimate below zero.
+			if err := queue.ExportSpans(context.Background(), testSpans(3)); err != nil {
+				t.Fatal(err)
+			}
+			if got := queue.Ratio(); got != 0 {
+				t.Errorf("Ratio after export = %v, want 0", got)
+			}
+		})
+	}
+}
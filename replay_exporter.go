@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	replayBuffered = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "otel_exporter_replay_buffered_spans",
+		Help: "Spans held for replay after a failed export, per exporter.",
+	}, []string{"exporter"})
+	replayDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "otel_exporter_replay_dropped_spans_total",
+		Help: "Buffered spans discarded because the replay buffer was full.",
+	}, []string{"exporter"})
+)
+
+// replayExporter keeps batches that next failed to export in a bounded
+// in-memory queue and sends them again, oldest first, ahead of later
+// batches. Once the queue holds limit spans the oldest ones are dropped.
+// Spans that make it into the queue are not reported as failed.
+type replayExporter struct {
+	next  trace.SpanExporter
+	name  string
+	limit int
+
+	mu      sync.Mutex
+	pending [][]trace.ReadOnlySpan
+	size    int
+}
+
+var _ trace.SpanExporter = (*replayExporter)(nil)
+
+func newReplayExporter(next trace.SpanExporter, name string, limit int) *replayExporter {
+	replayBuffered.WithLabelValues(name).Set(0)
+	return &replayExporter{next: next, name: name, limit: limit}
+}
+
+type noReplayKey struct{}
+
+// withoutReplay marks ctx so a replayExporter hands the export straight to
+// its next exporter and reports its failure instead of buffering it.
+func withoutReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noReplayKey{}, true)
+}
+
+func (e *replayExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if ctx.Value(noReplayKey{}) != nil {
+		return e.next.ExportSpans(ctx, spans)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) == 0 {
+		err := e.next.ExportSpans(ctx, spans)
+		if err == nil {
+			return nil
+		}
+		// The batch processor reuses its slice, keep a copy.
+		e.push(append([]trace.ReadOnlySpan(nil), spans...))
+		return nil
+	}
+	e.push(append([]trace.ReadOnlySpan(nil), spans...))
+	if err := e.replay(ctx); err != nil {
+		// The spans are queued and will be sent again, not lost.
+		log.Printf("exporter %s: replay failed, %d span(s) buffered: %v", e.name, e.size, err)
+	}
+	return nil
+}
+
+// Shutdown makes a last attempt at the buffered spans before shutting next
+// down.
+func (e *replayExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if err := e.replay(ctx); err != nil {
+		log.Printf("exporter %s: %d buffered span(s) lost on shutdown: %v", e.name, e.size, err)
+	}
+	e.mu.Unlock()
+	return e.next.Shutdown(ctx)
+}
+
+// replay exports pending batches in order, stopping at the first failure.
+// e.mu must be held.
+func (e *replayExporter) replay(ctx context.Context) error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	for len(e.pending) > 0 {
+		batch := e.pending[0]
+		if err := e.next.ExportSpans(ctx, batch); err != nil {
+			return err
+		}
+		e.pending[0] = nil
+		e.pending = e.pending[1:]
+		e.size -= len(batch)
+		replayBuffered.WithLabelValues(e.name).Set(float64(e.size))
+	}
+	log.Printf("exporter %s: replay buffer drained", e.name)
+	return nil
+}
+
+// push queues batch, dropping the oldest spans beyond limit. e.mu must be
+// held.
+func (e *replayExporter) push(batch []trace.ReadOnlySpan) {
+	if len(batch) > e.limit {
+		replayDropped.WithLabelValues(e.name).Add(float64(len(batch) - e.limit))
+		batch = batch[len(batch)-e.limit:]
+	}
+	e.pending = append(e.pending, batch)
+	e.size += len(batch)
+	for e.size > e.limit {
+		over := e.size - e.limit
+		oldest := e.pending[0]
+		if over >= len(oldest) {
+			e.pending[0] = nil
+			e.pending = e.pending[1:]
+			over = len(oldest)
+		} else {
+			e.pending[0] = oldest[over:]
+		}
+		e.size -= over
+		replayDropped.WithLabelValues(e.name).Add(float64(over))
+	}
+	replayBuffered.WithLabelValues(e.name).Set(float64(e.size))
+}
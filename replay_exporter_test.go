@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReplayExporter(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		// outage are the batch sizes exported while next fails.
+		outage []int
+		// shutdown recovers through Shutdown instead of a new export of
+		// one span, which is queued behind the buffered ones.
+		shutdown     bool
+		wantExported int
+		wantDropped  float64
+	}{
+		{name: "replayed on recovery", limit: 10, outage: []int{2, 3}, wantExported: 6},
+		{name: "oldest dropped when full", limit: 4, outage: []int{2, 3}, wantExported: 4, wantDropped: 2},
+		{name: "oversized batch trimmed", limit: 2, outage: []int{5}, wantExported: 2, wantDropped: 4},
+		{name: "replayed on shutdown", limit: 10, outage: []int{2, 3}, shutdown: true, wantExported: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubExporter{err: errors.New("collector unavailable")}
+			e := newReplayExporter(stub, t.Name(), tt.limit)
+			// The counter is global and outlives the test under -count.
+			droppedBefore := testutil.ToFloat64(replayDropped.WithLabelValues(t.Name()))
+			for _, n := range tt.outage {
+				if err := e.ExportSpans(context.Background(), testSpans(n)); err != nil {
+					t.Errorf("export during outage = %v, want buffered", err)
+				}
+			}
+			if len(stub.spans()) != 0 {
+				t.Fatalf("exported %d spans during the outage", len(stub.spans()))
+			}
+
+			stub.setErr(nil)
+			var err error
+			if tt.shutdown {
+				err = e.Shutdown(context.Background())
+			} else {
+				err = e.ExportSpans(context.Background(), testSpans(1))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(stub.spans()); got != tt.wantExported {
+				t.Errorf("exported %d spans, want %d", got, tt.wantExported)
+			}
+			if got := testutil.ToFloat64(replayDropped.WithLabelValues(t.Name())) - droppedBefore; got != tt.wantDropped {
+				t.Errorf("dropped = %v, want %v", got, tt.wantDropped)
+			}
+			if got := testutil.ToFloat64(replayBuffered.WithLabelValues(t.Name())); got != 0 {
+				t.Errorf("buffered = %v after recovery, want 0", got)
+			}
+		})
+	}
+}
+
+func TestReplayExporterWithoutReplay(t *testing.T) {
+	failed := errors.New("collector unavailable")
+	stub := &stubExporter{err: failed}
+	e := newReplayExporter(stub, t.Name(), 10)
+	if err := e.ExportSpans(withoutReplay(context.Background()), testSpans(2)); err != failed {
+		t.Errorf("err = %v, want %v", err, failed)
+	}
+	if got := testutil.ToFloat64(replayBuffered.WithLabelValues(t.Name())); got != 0 {
+		t.Errorf("buffered = %v, want 0", got)
+	}
+}
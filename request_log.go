@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// requestLog logs handled requests at most once per interval per route,
+// summarizing what happened since the previous line. It keeps the log
+// readable under load, and shows requests are still being served when
+// sampling drops most of their traces.
+type requestLog struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*routeLogState
+}
+
+type routeLogState struct {
+	last     time.Time
+	requests int
+	errors   int
+}
+
+func newRequestLog(interval time.Duration) *requestLog {
+	return &requestLog{interval: interval, routes: make(map[string]*routeLogState)}
+}
+
+// Record counts one request to route answered with status, logging the
+// route's summary when interval has passed since its last line. A nil
+// requestLog records nothing.
+func (l *requestLog) Record(route string, status int) {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+	l.mu.Lock()
+	st, ok := l.routes[route]
+	if !ok {
+		st = &routeLogState{last: now}
+		l.routes[route] = st
+	}
+	st.requests++
+	if status >= 500 {
+		st.errors++
+	}
+	if now.Sub(st.last) < l.interval && ok {
+		l.mu.Unlock()
+		return
+	}
+	requests, failed, since := st.requests, st.errors, now.Sub(st.last)
+	st.last, st.requests, st.errors = now, 0, 0
+	l.mu.Unlock()
+
+	log.Printf("%s: %d request(s), %d server error(s) in the last %s", route, requests, failed, since.Round(time.Millisecond))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestLogThrottled(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	tests := []struct {
+		name string
+		// burst requests are recorded back to back, every fifth failing,
+		// then after waiting for the interval one more is.
+		burst     int
+		routes    []string
+		wantLines []string
+	}{
+		{
+			name:   "one route",
+			burst:  100,
+			routes: []string{"/fibonacci"},
+			wantLines: []string{
+				"/fibonacci: 1 request(s), 1 server error(s)",
+				"/fibonacci: 100 request(s), 19 server error(s)",
+			},
+		},
+		{
+			name:   "per route",
+			burst:  10,
+			routes: []string{"/fibonacci", "/nested"},
+			wantLines: []string{
+				"/fibonacci: 1 request(s), 1 server error(s)",
+				"/nested: 1 request(s), 1 server error(s)",
+				"/fibonacci: 10 request(s), 1 server error(s)",
+				"/nested: 10 request(s), 1 server error(s)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			l := newRequestLog(interval)
+			for i := 0; i < tt.burst; i++ {
+				status := http.StatusOK
+				if i%5 == 0 {
+					status = http.StatusInternalServerError
+				}
+				for _, route := range tt.routes {
+					l.Record(route, status)
+				}
+			}
+			time.Sleep(interval)
+			for _, route := range tt.routes {
+				l.Record(route, http.StatusOK)
+			}
+
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			if len(lines) != len(tt.wantLines) {
+				t.Fatalf("got %d log lines, want %d:\n%s", len(lines), len(tt.wantLines), logs)
+			}
+			for i, want := range tt.wantLines {
+				if !strings.Contains(lines[i], want) {
+					t.Errorf("line %d = %q, want %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNilRequestLog(t *testing.T) {
+	logs := captureLog(t)
+	var l *requestLog
+	l.Record("/fibonacci", http.StatusOK)
+	if logs.Len() != 0 {
+		t.Errorf("nil requestLog logged %q", logs)
+	}
+}
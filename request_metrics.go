@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// requestMetrics records the count and duration of HTTP requests, both as
+// Prometheus metrics scraped from /metric and as OTel instruments, which
+// are pushed only when METRICS_EXPORTER=otlp installs a MeterProvider.
+type requestMetrics struct {
+	duration *prometheus.HistogramVec
+	// routes are the known routes; every other route is recorded as
+	// otherRoute to keep label cardinality bounded.
+	routes *routeMux
+	// handled counts every observed request, for /debug/vars.
+	handled atomic.Uint64
+
+	otelCount    instrument.Int64Counter
+	otelDuration instrument.Float64Histogram
+}
+
+// otherRoute is the route label of requests outside the known routes.
+const otherRoute = "other"
+
+func newRequestMetrics(meter metric.Meter, routes *routeMux) (*requestMetrics, error) {
+	count, err := meter.Int64Counter("http.server.request_count",
+		instrument.WithDescription("Number of HTTP requests handled."))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("http.server.duration",
+		instrument.WithDescription("Duration of HTTP requests."),
+		instrument.WithUnit("ms"))
+	if err != nil {
+		return nil, err
+	}
+	return &requestMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "http_request_duration_seconds",
+			Help: "Duration of HTTP requests.",
+		}, []string{"route", "method", "code"}),
+		routes:       routes,
+		otelCount:    count,
+		otelDuration: duration,
+	}, nil
+}
+
+// Collectors returns the Prometheus collectors to register.
+func (m *requestMetrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.duration}
+}
+
+// Observe records one finished request.
+func (m *requestMetrics) Observe(ctx context.Context, route, method string, code int, d time.Duration) {
+	m.handled.Add(1)
+	if !m.routes.IsKnown(route) {
+		route = otherRoute
+	}
+	m.duration.WithLabelValues(route, method, strconv.Itoa(code)).Observe(d.Seconds())
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRoute(route),
+		semconv.HTTPMethod(method),
+		semconv.HTTPStatusCode(code),
+	}
+	m.otelCount.Add(ctx, 1, attrs...)
+	m.otelDuration.Record(ctx, float64(d)/float64(time.Millisecond), attrs...)
+}
+
+// statusRecorder remembers the status code written through it and the
+// first error returned while writing the body, e.g. a client that went away.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	writeErr error
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	if err != nil && r.writeErr == nil {
+		r.writeErr = err
+	}
+	return n, err
+}
+
+// Status returns the written status code, 200 if the handler wrote none.
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Handled returns the number of requests observed so far.
+func (m *requestMetrics) Handled() uint64 {
+	return m.handled.Load()
+}
+
+// untracedMetricsHandler records request metrics for requests to mux that
+// no tracingMiddleware route handles: untraced routes such as /metric under
+// their pattern, anything else, such as scanners probing random paths,
+// under otherRoute. Requests to traced routes pass straight through, their
+// middleware records them.
+type untracedMetricsHandler struct {
+	mux     *routeMux
+	traced  *routeToggle
+	metrics *requestMetrics
+}
+
+func (h *untracedMetricsHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	_, pattern := h.mux.Handler(req)
+	if h.traced.IsKnown(pattern) {
+		h.mux.ServeHTTP(resp, req)
+		return
+	}
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: resp}
+	h.mux.ServeHTTP(rec, req)
+	h.metrics.Observe(req.Context(), pattern, req.Method, rec.Status(), time.Since(start))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// requestCountsByRoute returns the number of requests observed by m per
+// route label.
+func requestCountsByRoute(t *testing.T, m *requestMetrics) map[string]uint64 {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m.Collectors()...)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == "route" {
+					counts[l.GetValue()] += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return counts
+}
+
+func TestRequestMetricsOtherRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  map[string]uint64
+	}{
+		{
+			name:  "traced route",
+			paths: []string{"/fibonacci?n=3"},
+			want:  map[string]uint64{"/fibonacci": 1},
+		},
+		{
+			name:  "untraced registered route",
+			paths: []string{"/metric"},
+			want:  map[string]uint64{"/metric": 1},
+		},
+		{
+			name:  "unknown path",
+			paths: []string{"/wp-login.php"},
+			want:  map[string]uint64{otherRoute: 1},
+		},
+		{
+			name:  "scanner paths share one series",
+			paths: []string{"/.env", "/admin", "/fibonacci/extra", "/cgi-bin/test.cgi", "/metric"},
+			want:  map[string]uint64{otherRoute: 4, "/metric": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestTracerProvider(t)
+			mux := newRouteMux()
+			metrics, err := newRequestMetrics(metric.NewNoopMeterProvider().Meter("test"), mux)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := newTestMiddleware(t)
+			m.metrics = metrics
+			ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+			mux.Handle("/fibonacci", m.Handle("/fibonacci", ok))
+			mux.Handle("/metric", ok)
+			h := &untracedMetricsHandler{mux: mux, traced: m.routes, metrics: metrics}
+
+			for _, path := range tt.paths {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+			if got := requestCountsByRoute(t, metrics); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requests by route = %v, want %v", got, tt.want)
+			}
+			if got := metrics.Handled(); got != uint64(len(tt.paths)) {
+				t.Errorf("Handled = %d, want %d", got, len(tt.paths))
+			}
+		})
+	}
+}
+
+func TestRouteMuxIsKnown(t *testing.T) {
+	mux := newRouteMux()
+	mux.Handle("/fibonacci", http.NotFoundHandler())
+	mux.Handle("/debug/", http.NotFoundHandler())
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: "/fibonacci", want: true},
+		{pattern: "/debug/", want: true},
+		{pattern: "/debug/vars", want: false},
+		{pattern: "", want: false},
+		{pattern: "/", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := mux.IsKnown(tt.pattern); got != tt.want {
+				t.Errorf("IsKnown(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
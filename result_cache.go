@@ -0,0 +1,101 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// cacheKey identifies a computed result.
+type cacheKey struct {
+	mode fibMode
+	n    uint64
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value string
+}
+
+// resultCache is a fixed size LRU cache of computed fibonacci results. A
+// nil *resultCache is valid and caches nothing.
+type resultCache struct {
+	size    int
+	onEvict func(ctx context.Context, key cacheKey)
+
+	mu    sync.Mutex
+	order *list.List
+	items map[cacheKey]*list.Element
+}
+
+// newResultCache returns a cache holding up to size results. onEvict, if
+// not nil, is called for every evicted entry with the context passed to the
+// Add that caused it. It runs after the cache lock is released, so it may
+// use the cache itself.
+func newResultCache(size int, onEvict func(ctx context.Context, key cacheKey)) *resultCache {
+	return &resultCache{
+		size:    size,
+		onEvict: onEvict,
+		order:   list.New(),
+		items:   make(map[cacheKey]*list.Element, size),
+	}
+}
+
+// Get returns the cached result for key and marks it recently used.
+func (c *resultCache) Get(key cacheKey) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).value, true
+}
+
+// Add stores value for key, evicting the least recently used entries when
+// the cache is full.
+func (c *resultCache) Add(ctx context.Context, key cacheKey, value string) {
+	if c == nil {
+		return
+	}
+	var evicted []cacheKey
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+		for c.order.Len() > c.size {
+			oldest := c.order.Back()
+			entry := c.order.Remove(oldest).(*cacheEntry)
+			delete(c.items, entry.key)
+			evicted = append(evicted, entry.key)
+		}
+	}
+	c.mu.Unlock()
+
+	if c.onEvict != nil {
+		for _, key := range evicted {
+			c.onEvict(ctx, key)
+		}
+	}
+}
+
+// traceEviction returns an eviction callback that counts evictions in
+// counter and adds a cache.evict event to the span active in ctx.
+func traceEviction(counter prometheus.Counter) func(ctx context.Context, key cacheKey) {
+	return func(ctx context.Context, key cacheKey) {
+		counter.Inc()
+		oteltrace.SpanFromContext(ctx).AddEvent("cache.evict", oteltrace.WithAttributes(
+			attrKey("fib.mode").String(string(key.mode)),
+			attrKey("fib.n").Int64(int64(key.n)),
+		))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestResultCacheEvictions(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		adds []uint64
+		// get is looked up after the adds, wantHit tells if it is cached.
+		get         uint64
+		wantHit     bool
+		wantEvicted []uint64
+	}{
+		{name: "fits", size: 2, adds: []uint64{1, 2}, get: 1, wantHit: true},
+		{name: "oldest evicted", size: 2, adds: []uint64{1, 2, 3}, get: 1, wantEvicted: []uint64{1}},
+		{name: "update keeps size", size: 2, adds: []uint64{1, 2, 1, 3}, get: 1, wantHit: true, wantEvicted: []uint64{2}},
+		{name: "tiny cache", size: 1, adds: []uint64{1, 2, 3, 4}, get: 4, wantHit: true, wantEvicted: []uint64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t)
+			counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "fibonacci_cache_evictions_total"})
+			cache := newResultCache(tt.size, traceEviction(counter))
+
+			ctx, span := tracer("test").Start(context.Background(), "request")
+			for _, n := range tt.adds {
+				cache.Add(ctx, cacheKey{mode: fibModeIter, n: n}, "v")
+			}
+			span.End()
+
+			if _, hit := cache.Get(cacheKey{mode: fibModeIter, n: tt.get}); hit != tt.wantHit {
+				t.Errorf("Get(%d) hit = %v, want %v", tt.get, hit, tt.wantHit)
+			}
+			if got := testutil.ToFloat64(counter); got != float64(len(tt.wantEvicted)) {
+				t.Errorf("evictions = %v, want %d", got, len(tt.wantEvicted))
+			}
+			var got []uint64
+			for _, ev := range rec.Ended()[0].Events() {
+				if ev.Name != "cache.evict" {
+					continue
+				}
+				for _, kv := range ev.Attributes {
+					if kv.Key == attrKey("fib.n") {
+						got = append(got, uint64(kv.Value.AsInt64()))
+					}
+				}
+			}
+			if len(got) != len(tt.wantEvicted) {
+				t.Fatalf("evict events for %v, want %v", got, tt.wantEvicted)
+			}
+			for i := range got {
+				if got[i] != tt.wantEvicted[i] {
+					t.Errorf("evict events for %v, want %v", got, tt.wantEvicted)
+				}
+			}
+		})
+	}
+}
+
+func TestResultCacheEvictCallbackMayUseCache(t *testing.T) {
+	var cache *resultCache
+	cache = newResultCache(1, func(ctx context.Context, key cacheKey) {
+		// Would deadlock if called with the cache lock held.
+		cache.Get(key)
+	})
+	cache.Add(context.Background(), cacheKey{n: 1}, "1")
+	cache.Add(context.Background(), cacheKey{n: 2}, "1")
+}
+
+func TestNilResultCache(t *testing.T) {
+	var cache *resultCache
+	cache.Add(context.Background(), cacheKey{n: 1}, "1")
+	if _, ok := cache.Get(cacheKey{n: 1}); ok {
+		t.Error("nil cache returned a hit")
+	}
+}
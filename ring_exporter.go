@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ringExporter keeps the most recently exported spans in memory, overwriting
+// the oldest once it holds size spans.
+type ringExporter struct {
+	mu    sync.Mutex
+	spans []ndjsonSpan
+	next  int
+	full  bool
+}
+
+var _ trace.SpanExporter = (*ringExporter)(nil)
+
+func newRingExporter(size int) *ringExporter {
+	if size < 1 {
+		size = 1
+	}
+	return &ringExporter{spans: make([]ndjsonSpan, size)}
+}
+
+func (e *ringExporter) ExportSpans(_ context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		e.spans[e.next] = toNDJSONSpan(s, false, false)
+		e.next = (e.next + 1) % len(e.spans)
+		if e.next == 0 {
+			e.full = true
+		}
+	}
+	return nil
+}
+
+func (e *ringExporter) Shutdown(context.Context) error { return nil }
+
+// Snapshot returns the buffered spans from oldest to newest.
+func (e *ringExporter) Snapshot() []ndjsonSpan {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.full {
+		return append([]ndjsonSpan(nil), e.spans[:e.next]...)
+	}
+	out := make([]ndjsonSpan, 0, len(e.spans))
+	out = append(out, e.spans[e.next:]...)
+	return append(out, e.spans[:e.next]...)
+}
+
+// recentTracesHandler renders the spans held by a ringExporter as JSON.
+type recentTracesHandler struct {
+	ring *ringExporter
+}
+
+func (s *recentTracesHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(s.ring.Snapshot())
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRecentTracesHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		routes []string
+		want   []string
+	}{
+		{name: "not full", size: 10, routes: []string{"/a", "/b", "/c"}, want: []string{"/a", "/b", "/c"}},
+		{name: "exactly full", size: 3, routes: []string{"/a", "/b", "/c"}, want: []string{"/a", "/b", "/c"}},
+		{name: "wrapped", size: 2, routes: []string{"/a", "/b", "/c"}, want: []string{"/b", "/c"}},
+		{name: "empty", size: 2, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := newRingExporter(tt.size)
+			useTestTracerProvider(t, trace.WithSyncer(ring))
+			m := newTestMiddleware(t)
+			for _, route := range tt.routes {
+				h := m.Handle(route, http.NotFoundHandler())
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, route, nil))
+			}
+
+			resp := httptest.NewRecorder()
+			(&recentTracesHandler{ring: ring}).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/traces", nil))
+			var spans []ndjsonSpan
+			if err := json.Unmarshal(resp.Body.Bytes(), &spans); err != nil {
+				t.Fatalf("%v: %s", err, resp.Body)
+			}
+			got := []string{}
+			for _, s := range spans {
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spans = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRingExporterConcurrent(t *testing.T) {
+	ring := newRingExporter(8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ring.ExportSpans(context.Background(), testSpans(3))
+		}()
+		go func() {
+			defer wg.Done()
+			ring.Snapshot()
+		}()
+	}
+	wg.Wait()
+	if got := len(ring.Snapshot()); got != 8 {
+		t.Errorf("holds %d spans, want 8", got)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// routeMux is an http.ServeMux that remembers the patterns registered on
+// it, so request metrics can tell its routes from arbitrary paths.
+type routeMux struct {
+	*http.ServeMux
+
+	mu       sync.RWMutex
+	patterns map[string]bool
+}
+
+func newRouteMux() *routeMux {
+	return &routeMux{ServeMux: http.NewServeMux(), patterns: map[string]bool{}}
+}
+
+// Handle registers handler for pattern and records pattern as known.
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.ServeMux.Handle(pattern, handler)
+	m.mu.Lock()
+	m.patterns[pattern] = true
+	m.mu.Unlock()
+}
+
+// IsKnown reports whether pattern was registered.
+func (m *routeMux) IsKnown(pattern string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.patterns[pattern]
+}
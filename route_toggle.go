@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// routeToggle tracks which routes are traced. Routes not set explicitly
+// follow defaultOn.
+type routeToggle struct {
+	mu        sync.RWMutex
+	defaultOn bool
+	routes    map[string]bool
+	known     []string
+}
+
+// newRouteToggle traces only the given routes, or every route when none
+// are given.
+func newRouteToggle(traced []string) *routeToggle {
+	t := &routeToggle{defaultOn: len(traced) == 0, routes: make(map[string]bool, len(traced))}
+	for _, route := range traced {
+		t.routes[route] = true
+	}
+	return t
+}
+
+// Enabled reports whether route is traced.
+func (t *routeToggle) Enabled(route string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if on, ok := t.routes[route]; ok {
+		return on
+	}
+	return t.defaultOn
+}
+
+// Register records route as served by the tracing middleware.
+func (t *routeToggle) Register(route string) {
+	t.mu.Lock()
+	t.known = append(t.known, route)
+	t.mu.Unlock()
+}
+
+// IsKnown reports whether route has been registered.
+func (t *routeToggle) IsKnown(route string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, r := range t.known {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Known returns the registered routes in registration order.
+func (t *routeToggle) Known() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]string(nil), t.known...)
+}
+
+// Set turns tracing for route on or off.
+func (t *routeToggle) Set(route string, on bool) {
+	t.mu.Lock()
+	t.routes[route] = on
+	t.mu.Unlock()
+}
+
+func (t *routeToggle) snapshot() map[string]bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make(map[string]bool, len(t.routes))
+	for route, on := range t.routes {
+		out[route] = on
+	}
+	return out
+}
+
+type tracingSuppressedKey struct{}
+
+// withTracingSuppressed marks ctx so that suppressSampler drops every span
+// started from it or its descendants.
+func withTracingSuppressed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tracingSuppressedKey{}, true)
+}
+
+// suppressSampler drops spans started from a context marked by
+// withTracingSuppressed and defers to next otherwise. Dropped spans are
+// non-recording, so a disabled route costs little more than a context
+// lookup.
+type suppressSampler struct {
+	next trace.Sampler
+}
+
+var _ trace.Sampler = suppressSampler{}
+
+func (s suppressSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if p.ParentContext != nil && p.ParentContext.Value(tracingSuppressedKey{}) != nil {
+		return trace.SamplingResult{
+			Decision:   trace.Drop,
+			Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s suppressSampler) Description() string {
+	return s.next.Description()
+}
+
+// routeToggleHandler lists the per-route tracing switches on GET and flips
+// one on PUT or POST with route and enabled query params.
+type routeToggleHandler struct {
+	toggle *routeToggle
+	// changed, if set, is called after a route was switched.
+	changed func()
+}
+
+func (s *routeToggleHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		route := req.URL.Query().Get("route")
+		on, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+		if route == "" || err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte("route and enabled=true|false are required"))
+			return
+		}
+		s.toggle.Set(route, on)
+		if s.changed != nil {
+			s.changed()
+		}
+	default:
+		resp.Header().Set("Allow", "GET, PUT, POST")
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(map[string]interface{}{
+		"default": s.toggle.defaultOn,
+		"routes":  s.toggle.snapshot(),
+	})
+}
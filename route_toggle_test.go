@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRouteToggle(t *testing.T) {
+	tests := []struct {
+		name   string
+		traced []string
+		// toggle, if set, is sent to the /debug/routes handler first.
+		toggle string
+		want   map[string]int
+	}{
+		{name: "all routes by default", want: map[string]int{"/fibonacci": 2, "/nested": 2}},
+		{name: "allowlist", traced: []string{"/fibonacci"}, want: map[string]int{"/fibonacci": 2, "/nested": 0}},
+		{
+			name:   "disabled at runtime",
+			toggle: "route=/nested&enabled=false",
+			want:   map[string]int{"/fibonacci": 2, "/nested": 0},
+		},
+		{
+			name:   "enabled at runtime",
+			traced: []string{"/fibonacci"},
+			toggle: "route=/nested&enabled=true",
+			want:   map[string]int{"/fibonacci": 2, "/nested": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t, trace.WithSampler(suppressSampler{next: trace.AlwaysSample()}))
+			m := newTestMiddleware(t)
+			m.routes = newRouteToggle(tt.traced)
+			if tt.toggle != "" {
+				resp := httptest.NewRecorder()
+				(&routeToggleHandler{toggle: m.routes}).ServeHTTP(resp, httptest.NewRequest(http.MethodPut, "/debug/routes?"+tt.toggle, nil))
+				if resp.Code != http.StatusOK {
+					t.Fatalf("toggle status = %d: %s", resp.Code, resp.Body)
+				}
+			}
+			// The handler starts a child span, which must follow its route.
+			child := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				_, span := tracer("test").Start(req.Context(), "child")
+				span.End()
+			})
+			for route, want := range tt.want {
+				before := len(rec.Ended())
+				m.Handle(route, child).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, route, nil))
+				if got := len(rec.Ended()) - before; got != want {
+					t.Errorf("%s produced %d spans, want %d", route, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteToggleHandlerBadRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		query  string
+		want   int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodPut, "route=/nested", http.StatusBadRequest},
+		{http.MethodPut, "enabled=false", http.StatusBadRequest},
+		{http.MethodPost, "route=/nested&enabled=maybe", http.StatusBadRequest},
+		{http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.query, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			(&routeToggleHandler{toggle: newRouteToggle(nil)}).ServeHTTP(resp, httptest.NewRequest(tt.method, "/debug/routes?"+tt.query, nil))
+			if resp.Code != tt.want {
+				t.Errorf("status = %d, want %d", resp.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuppressSamplerKeepsOtherContexts(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantSample bool
+	}{
+		{name: "unmarked", ctx: context.Background(), wantSample: true},
+		{name: "suppressed", ctx: withTracingSuppressed(context.Background())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestTracerProvider(t, trace.WithSampler(suppressSampler{next: trace.AlwaysSample()}))
+			_, span := tracer("test").Start(tt.ctx, "span")
+			defer span.End()
+			if span.IsRecording() != tt.wantSample {
+				t.Errorf("recording = %v, want %v", span.IsRecording(), tt.wantSample)
+			}
+		})
+	}
+}
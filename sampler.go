@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// reasonSampler wraps a sampler and records on sampled local root spans why
+// they were sampled, as the sampling.reason attribute.
+type reasonSampler struct {
+	next trace.Sampler
+}
+
+var _ trace.Sampler = reasonSampler{}
+
+func (s reasonSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	res := s.next.ShouldSample(p)
+	if res.Decision != trace.RecordAndSample {
+		return res
+	}
+	parent := oteltrace.SpanContextFromContext(p.ParentContext)
+	if parent.IsValid() && !parent.IsRemote() {
+		return res
+	}
+	res.Attributes = append(res.Attributes, attrKey("sampling.reason").String(samplingReason(parent, s.next)))
+	return res
+}
+
+func (s reasonSampler) Description() string {
+	return s.next.Description()
+}
+
+// samplingReason explains a positive decision by sampler for a span whose
+// parent is parent.
+func samplingReason(parent oteltrace.SpanContext, sampler trace.Sampler) string {
+	switch {
+	case parent.IsValid() && parent.IsSampled():
+		return "remote parent sampled; " + sampler.Description()
+	case parent.IsValid():
+		return "remote parent not sampled; " + sampler.Description()
+	default:
+		return "root; " + sampler.Description()
+	}
+}
+
+// ratioSampler samples a fraction of traces by trace ID. Unlike
+// trace.TraceIDRatioBased the fraction can be changed at runtime.
+type ratioSampler struct {
+	mu    sync.RWMutex
+	ratio float64
+	inner trace.Sampler
+}
+
+var _ trace.Sampler = (*ratioSampler)(nil)
+
+func newRatioSampler(ratio float64) (*ratioSampler, error) {
+	s := &ratioSampler{}
+	if err := s.SetRatio(ratio); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetRatio replaces the sampled fraction, which must be within [0, 1].
+func (s *ratioSampler) SetRatio(ratio float64) error {
+	if !(ratio >= 0 && ratio <= 1) {
+		return newConfigError(ErrInvalidSampleRatio, nil, "sample ratio %g out of range [0, 1]", ratio)
+	}
+	s.mu.Lock()
+	s.ratio = ratio
+	s.inner = trace.TraceIDRatioBased(ratio)
+	s.mu.Unlock()
+	return nil
+}
+
+// Ratio returns the sampled fraction currently in effect.
+func (s *ratioSampler) Ratio() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ratio
+}
+
+// Collector returns a gauge reporting the ratio currently in effect.
+func (s *ratioSampler) Collector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "otel_traces_sampler_ratio",
+		Help: "Fraction of root spans currently sampled.",
+	}, s.Ratio)
+}
+
+func (s *ratioSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	s.mu.RLock()
+	inner := s.inner
+	s.mu.RUnlock()
+	return inner.ShouldSample(p)
+}
+
+func (s *ratioSampler) Description() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inner.Description()
+}
+
+// samplingHandler reports the sample ratio on GET and changes it on PUT or
+// POST with a ratio query param.
+type samplingHandler struct {
+	sampler *ratioSampler
+	// changed, if set, is called after the ratio was changed.
+	changed func()
+}
+
+func (s *samplingHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		ratio, err := strconv.ParseFloat(req.URL.Query().Get("ratio"), 64)
+		if err == nil {
+			err = s.sampler.SetRatio(ratio)
+		}
+		if err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte("ratio must be a number within [0, 1]"))
+			return
+		}
+		if s.changed != nil {
+			s.changed()
+		}
+	default:
+		resp.Header().Set("Allow", "GET, PUT, POST")
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	resp.WriteHeader(http.StatusOK)
+	resp.Write([]byte(strconv.FormatFloat(s.sampler.Ratio(), 'g', -1, 64)))
+}
+
+// traceStateSampler adds a vendor entry to the W3C tracestate of local root
+// spans. Entries received from upstream are kept; the SDK already carries
+// them over to every local span.
+type traceStateSampler struct {
+	next       trace.Sampler
+	key, value string
+}
+
+var _ trace.Sampler = traceStateSampler{}
+
+// newTraceStateSampler validates entry ("key=value") against the
+// tracestate syntax and returns a sampler adding it to root spans.
+func newTraceStateSampler(next trace.Sampler, entry string) (traceStateSampler, error) {
+	key, value, ok := strings.Cut(entry, "=")
+	if !ok {
+		return traceStateSampler{}, newConfigError(ErrInvalidTraceState, nil, "invalid tracestate entry %q, want key=value", entry)
+	}
+	if _, err := (oteltrace.TraceState{}).Insert(key, value); err != nil {
+		return traceStateSampler{}, newConfigError(ErrInvalidTraceState, err, "invalid tracestate entry %q", entry)
+	}
+	return traceStateSampler{next: next, key: key, value: value}, nil
+}
+
+func (s traceStateSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	res := s.next.ShouldSample(p)
+	parent := oteltrace.SpanContextFromContext(p.ParentContext)
+	if parent.IsValid() && !parent.IsRemote() {
+		return res
+	}
+	if ts, err := res.Tracestate.Insert(s.key, s.value); err == nil {
+		res.Tracestate = ts
+	}
+	return res
+}
+
+func (s traceStateSampler) Description() string {
+	return s.next.Description()
+}
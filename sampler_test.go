@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// remoteParent returns ctx carrying a remote parent span context.
+func remoteParent(ctx context.Context, sampled bool) context.Context {
+	sc := testSpanContext(1, 1).WithRemote(true)
+	if !sampled {
+		sc = sc.WithTraceFlags(0)
+	}
+	return oteltrace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+func TestReasonSampler(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler trace.Sampler
+		parent  func(context.Context) context.Context
+		// wantReason is the prefix of sampling.reason on the first span,
+		// "" for no attribute, "-" for an unsampled span.
+		wantReason string
+	}{
+		{
+			name:       "parent based root",
+			sampler:    trace.ParentBased(trace.TraceIDRatioBased(1)),
+			wantReason: "root; ParentBased{root:AlwaysOnSampler",
+		},
+		{
+			name:       "always on root",
+			sampler:    trace.AlwaysSample(),
+			wantReason: "root; AlwaysOnSampler",
+		},
+		{
+			name:    "sampled remote parent",
+			sampler: trace.ParentBased(trace.TraceIDRatioBased(0)),
+			parent: func(ctx context.Context) context.Context {
+				return remoteParent(ctx, true)
+			},
+			wantReason: "remote parent sampled; ParentBased",
+		},
+		{
+			name:    "forced remote parent",
+			sampler: trace.AlwaysSample(),
+			parent: func(ctx context.Context) context.Context {
+				return remoteParent(ctx, false)
+			},
+			wantReason: "remote parent not sampled; AlwaysOnSampler",
+		},
+		{
+			name:       "dropped root",
+			sampler:    trace.ParentBased(trace.TraceIDRatioBased(0)),
+			wantReason: "-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.NewSpanRecorder()
+			tp := trace.NewTracerProvider(
+				trace.WithSampler(reasonSampler{next: tt.sampler}),
+				trace.WithSpanProcessor(rec),
+			)
+			defer tp.Shutdown(context.Background())
+
+			ctx := context.Background()
+			if tt.parent != nil {
+				ctx = tt.parent(ctx)
+			}
+			ctx, root := tp.Tracer("test").Start(ctx, "root")
+			_, child := tp.Tracer("test").Start(ctx, "child")
+			child.End()
+			root.End()
+
+			ended := rec.Ended()
+			if tt.wantReason == "-" {
+				if len(ended) != 0 {
+					t.Fatalf("recorded %d spans, want none", len(ended))
+				}
+				return
+			}
+			if len(ended) != 2 {
+				t.Fatalf("recorded %d spans, want 2", len(ended))
+			}
+			for _, s := range ended {
+				got := spanAttr(s, attrKey("sampling.reason"))
+				switch s.Name() {
+				case "root":
+					if !strings.HasPrefix(got, tt.wantReason) {
+						t.Errorf("root sampling.reason = %q, want prefix %q", got, tt.wantReason)
+					}
+				case "child":
+					if got != "" {
+						t.Errorf("child sampling.reason = %q, want none", got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSamplerRatioGauge(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		ratio  string
+		want   float64
+	}{
+		{name: "read", method: http.MethodGet, want: 1},
+		{name: "lowered", method: http.MethodPut, ratio: "0.25", want: 0.25},
+		{name: "set by post", method: http.MethodPost, ratio: "0", want: 0},
+		{name: "out of range", method: http.MethodPut, ratio: "1.5", want: 1},
+		{name: "not a number", method: http.MethodPut, ratio: "half", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newRatioSampler(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gauge := s.Collector()
+			var changed bool
+			h := &samplingHandler{sampler: s, changed: func() { changed = true }}
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/debug/sampling?ratio="+tt.ratio, nil))
+
+			if got := testutil.ToFloat64(gauge); got != tt.want {
+				t.Errorf("otel_traces_sampler_ratio = %v, want %v", got, tt.want)
+			}
+			if wantChanged := tt.want != 1; changed != wantChanged {
+				t.Errorf("changed called = %v, want %v", changed, wantChanged)
+			}
+		})
+	}
+}
+
+func TestTraceStateSampler(t *testing.T) {
+	tests := []struct {
+		name       string
+		tracestate string
+		// entry configures the vendor entry, "" leaves it off.
+		entry string
+		want  string
+	}{
+		{name: "preserved", tracestate: "vendor=abc", want: "vendor=abc"},
+		{name: "appended to incoming", tracestate: "vendor=abc", entry: "fibdemo=1", want: "fibdemo=1,vendor=abc"},
+		{name: "root without upstream", entry: "fibdemo=1", want: "fibdemo=1"},
+		{name: "replaces own upstream entry", tracestate: "fibdemo=0,vendor=abc", entry: "fibdemo=1", want: "fibdemo=1,vendor=abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sampler trace.Sampler = trace.AlwaysSample()
+			if tt.entry != "" {
+				var err error
+				if sampler, err = newTraceStateSampler(sampler, tt.entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rec := useTestTracerProvider(t, trace.WithSampler(sampler))
+			prop, err := newPropagator([]string{"tracecontext"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			if tt.tracestate != "" {
+				header.Set("traceparent", "00-"+testTraceID+"-"+testSpanID+"-01")
+				header.Set("tracestate", tt.tracestate)
+			}
+			ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(header))
+			ctx, root := tracer("test").Start(ctx, "root")
+			_, child := tracer("test").Start(ctx, "child")
+			child.End()
+			root.End()
+
+			for _, s := range rec.Ended() {
+				if got := s.SpanContext().TraceState().String(); got != tt.want {
+					t.Errorf("%s tracestate = %q, want %q", s.Name(), got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTraceStateSamplerInvalid(t *testing.T) {
+	tests := []string{"fibdemo", "Fib Demo=1", "fibdemo=a,b", "=1"}
+	for _, entry := range tests {
+		t.Run(entry, func(t *testing.T) {
+			if _, err := newTraceStateSampler(trace.AlwaysSample(), entry); !errors.Is(err, ErrInvalidTraceState) {
+				t.Errorf("err = %v, want ErrInvalidTraceState", err)
+			}
+		})
+	}
+}
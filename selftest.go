@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// selfTest exports a span describing a small fibonacci computation
+// straight through exp, so a broken exporter or unreachable collector fails
+// startup instead of silently dropping spans later. The span is recorded by
+// a private, always sampling provider, so the configured sampling ratio
+// can't drop it, and exported with withoutReplay, so a replay buffer can't
+// swallow the failure. It gives up after timeout.
+func selfTest(exp trace.SpanExporter, res *resource.Resource, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(
+		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithResource(res),
+		trace.WithSpanProcessor(rec),
+	)
+	defer tp.Shutdown(ctx)
+	_, span := tp.Tracer("selftest").Start(ctx, "selftest")
+	span.SetAttributes(
+		attrKey("fib.n").Int64(5),
+		attrKey("fib.result").Int64(int64(fibUint64(5))),
+	)
+	span.End()
+
+	if err := exp.ExportSpans(withoutReplay(ctx), rec.Ended()); err != nil {
+		return fmt.Errorf("self-test export failed: %w", err)
+	}
+	return nil
+}
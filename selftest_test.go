@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSelfTest(t *testing.T) {
+	rejected := errors.New("collector unavailable")
+	tests := []struct {
+		name     string
+		exporter *stubExporter
+		// replay wraps the exporter in a replayExporter, which must not
+		// hide a failure.
+		replay  bool
+		wantErr error
+	}{
+		{name: "working exporter", exporter: &stubExporter{}},
+		{name: "failing exporter", exporter: &stubExporter{err: rejected}, wantErr: rejected},
+		{name: "failing exporter behind replay", exporter: &stubExporter{err: rejected}, replay: true, wantErr: rejected},
+		{name: "hung exporter", exporter: &stubExporter{block: true}, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The configured sampler must not matter.
+			useTestTracerProvider(t, trace.WithSampler(trace.NeverSample()))
+			var exp trace.SpanExporter = tt.exporter
+			if tt.replay {
+				exp = newReplayExporter(exp, "selftest", 10)
+			}
+
+			err := selfTest(exp, resource.Empty(), 50*time.Millisecond)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("selfTest = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			spans := tt.exporter.spans()
+			if len(spans) != 1 || spans[0].Name() != "selftest" {
+				t.Fatalf("exported %v, want the selftest span", spans)
+			}
+			if got := spanAttr(spans[0], attrKey("fib.result")); got != "5" {
+				t.Errorf("fib.result = %q, want 5", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
+)
+
+// newHTTPServer returns a server for handler on addr. Its timeouts come from
+// HTTP_READ_HEADER_TIMEOUT, HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT and
+// HTTP_IDLE_TIMEOUT, with defaults that cut off slow or hung clients.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:      envDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:       envDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		ConnContext:       withConnRequests,
+	}
+}
+
+// runServer serves srv until ctx is done or serving fails. It does not stop
+// srv itself; that is the first of the shutdown steps run afterwards. The
+// http.ErrServerClosed returned by a clean shutdown is not an error; only
+// real listen/serve failures are returned. With maxConns > 0 at most that
+// many connections are open at once; further ones aren't accepted until
+// one closes, so they wait in the kernel's backlog and are refused once it
+// is full.
+func runServer(ctx context.Context, srv *http.Server, maxConns int) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if maxConns > 0 {
+		lis = netutil.LimitListener(lis, maxConns)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return serveError(err)
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// serveError filters out http.ErrServerClosed, which signals a requested
+// shutdown rather than a failure.
+func serveError(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// enableH2C makes srv accept HTTP/2 over cleartext, both by prior knowledge
+// and through an Upgrade: h2c request, next to HTTP/1.1. TLS listeners
+// negotiate HTTP/2 on their own and don't need this.
+func enableH2C(srv *http.Server) {
+	srv.Handler = h2c.NewHandler(srv.Handler, &http2.Server{IdleTimeout: srv.IdleTimeout})
+}
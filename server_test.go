@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestServeError(t *testing.T) {
+	failed := errors.New("accept failed")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "server closed", err: http.ErrServerClosed, want: nil},
+		{name: "wrapped server closed", err: fmt.Errorf("serve: %w", http.ErrServerClosed), want: nil},
+		{name: "real failure", err: failed, want: failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveError(tt.err); got != tt.want {
+				t.Errorf("serveError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunServer(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{name: "clean shutdown", addr: "127.0.0.1:0"},
+		{name: "bind failure", addr: busy.Addr().String(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &http.Server{Addr: tt.addr, Handler: http.NotFoundHandler()}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- runServer(ctx, srv, 0) }()
+
+			// Shut down the way main does: stop waiting first, then close
+			// the server, which makes Serve return ErrServerClosed.
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			err := <-done
+			if shutdownErr := srv.Shutdown(context.Background()); shutdownErr != nil {
+				t.Fatal(shutdownErr)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runServer = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunServerMaxConnections(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxConns int
+		dial     int
+		// wantAccepted is the number of connections accepted while all
+		// dialed ones are held open.
+		wantAccepted int
+	}{
+		{name: "unlimited", dial: 4, wantAccepted: 4},
+		{name: "under limit", maxConns: 4, dial: 3, wantAccepted: 3},
+		{name: "excess not accepted", maxConns: 2, dial: 4, wantAccepted: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// runServer listens itself, so reserve a free port for it.
+			free, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := free.Addr().String()
+			free.Close()
+
+			var mu sync.Mutex
+			var accepted int
+			srv := &http.Server{Addr: addr, Handler: http.NotFoundHandler(), ConnState: func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				}
+			}}
+			acceptedConns := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return accepted
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go runServer(ctx, srv, tt.maxConns)
+			defer srv.Close()
+
+			var conns []net.Conn
+			for i := 0; i < tt.dial; i++ {
+				// Connections over the limit still complete the handshake
+				// into the kernel's backlog; they are just never accepted.
+				conn, err := dialRetry(addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				conns = append(conns, conn)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if got := acceptedConns(); got != tt.wantAccepted {
+				t.Fatalf("accepted %d of %d connections, want %d", got, tt.dial, tt.wantAccepted)
+			}
+
+			// Closing accepted connections frees their slots for the
+			// waiting ones.
+			for _, conn := range conns[:tt.wantAccepted] {
+				conn.Close()
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for acceptedConns() < tt.dial && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if got := acceptedConns(); got != tt.dial {
+				t.Errorf("accepted %d of %d connections after freeing slots", got, tt.dial)
+			}
+		})
+	}
+}
+
+// dialRetry dials addr, retrying briefly while the server starts listening.
+func dialRetry(addr string) (net.Conn, error) {
+	var err error
+	for i := 0; i < 50; i++ {
+		var conn net.Conn
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			return conn, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil, err
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want [4]time.Duration
+	}{
+		{
+			name: "defaults",
+			want: [4]time.Duration{5 * time.Second, 15 * time.Second, 60 * time.Second, 120 * time.Second},
+		},
+		{
+			name: "configured",
+			env: map[string]string{
+				"HTTP_READ_HEADER_TIMEOUT": "1s",
+				"HTTP_READ_TIMEOUT":        "2s",
+				"HTTP_WRITE_TIMEOUT":       "3s",
+				"HTTP_IDLE_TIMEOUT":        "4s",
+			},
+			want: [4]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			srv := newHTTPServer(":0", http.NotFoundHandler())
+			got := [4]time.Duration{srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout}
+			if got != tt.want {
+				t.Errorf("timeouts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowClientDisconnected(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		// send is written by a client that then stalls.
+		send string
+	}{
+		{name: "slow headers", env: "HTTP_READ_HEADER_TIMEOUT", send: "GET /fibonacci HTTP/1.1\r\nHost: x\r\n"},
+		{name: "slow body", env: "HTTP_READ_TIMEOUT", send: "POST /fibonacci HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\n12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, "100ms")
+			handler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				io.Copy(io.Discard, req.Body)
+			})
+			srv := newHTTPServer("127.0.0.1:0", handler)
+			lis, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go srv.Serve(lis)
+			defer srv.Close()
+
+			conn, err := net.Dial("tcp", lis.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := io.WriteString(conn, tt.send); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			conn.SetReadDeadline(start.Add(5 * time.Second))
+			io.Copy(io.Discard, conn)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("connection still open after %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestEnableH2C(t *testing.T) {
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	tests := []struct {
+		name   string
+		client *http.Client
+		want   string
+	}{
+		{name: "h2c prior knowledge", client: h2cClient, want: "HTTP/2.0"},
+		{name: "http/1.1 still served", client: &http.Client{}, want: "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				io.WriteString(resp, req.Proto)
+			})
+			srv := newHTTPServer("127.0.0.1:0", handler)
+			enableH2C(srv)
+			lis, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go srv.Serve(lis)
+			defer srv.Close()
+
+			resp, err := tt.client.Get("http://" + lis.Addr().String() + "/fibonacci")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.Proto != tt.want || string(body) != tt.want {
+				t.Errorf("response %s, served over %s, want %s", resp.Proto, body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// shutdownStep is one named stage of the shutdown sequence.
+type shutdownStep struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// runShutdown runs steps in the declared order, each under its own timeout,
+// and logs the outcome of every step. A failing step does not stop the ones
+// after it; the first error is returned once all have run.
+func runShutdown(steps []shutdownStep) error {
+	var firstErr error
+	for _, step := range steps {
+		ctx, cancel := context.WithTimeout(context.Background(), step.timeout)
+		start := time.Now()
+		err := step.fn(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("shutdown %s: failed after %s: %v", step.name, time.Since(start), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		log.Printf("shutdown %s: done in %s", step.name, time.Since(start))
+	}
+	return firstErr
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunShutdown(t *testing.T) {
+	failed := errors.New("flush failed")
+	tests := []struct {
+		name string
+		// fail and slow name the steps that return an error and that
+		// outlive their timeout.
+		fail, slow string
+		wantErr    error
+	}{
+		{name: "all succeed"},
+		{name: "middle step errors", fail: "tracer provider", wantErr: failed},
+		{name: "first step errors", fail: "http server", wantErr: failed},
+		{name: "step times out", slow: "http server", wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []string
+			step := func(name string) shutdownStep {
+				return shutdownStep{name: name, timeout: 20 * time.Millisecond, fn: func(ctx context.Context) error {
+					ran = append(ran, name)
+					switch name {
+					case tt.fail:
+						return failed
+					case tt.slow:
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					return nil
+				}}
+			}
+			names := []string{"http server", "tracer provider", "background tasks", "metrics registry"}
+			var steps []shutdownStep
+			for _, name := range names {
+				steps = append(steps, step(name))
+			}
+
+			err := runShutdown(steps)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("runShutdown = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ran, names) {
+				t.Errorf("ran %v, want %v", ran, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// RootSpanHook enriches the root server span of a request, e.g. with
+// attributes the handlers don't know about. OnStart runs right after the span
+// starts, OnEnd right before it ends, once the response is written; either
+// may be nil.
+type RootSpanHook struct {
+	OnStart func(ctx context.Context, span oteltrace.Span)
+	OnEnd   func(ctx context.Context, span oteltrace.Span)
+}
+
+// rootSpanHooks are the registered hooks, in registration order.
+var rootSpanHooks []RootSpanHook
+
+// RegisterRootSpanHook adds hook to the hooks run on every root span. It is
+// not safe to call once the server is serving.
+func RegisterRootSpanHook(hook RootSpanHook) {
+	rootSpanHooks = append(rootSpanHooks, hook)
+}
+
+// runRootSpanStart runs the OnStart hooks.
+func runRootSpanStart(ctx context.Context, span oteltrace.Span) {
+	for _, h := range rootSpanHooks {
+		if h.OnStart != nil {
+			h.OnStart(ctx, span)
+		}
+	}
+}
+
+// runRootSpanEnd runs the OnEnd hooks.
+func runRootSpanEnd(ctx context.Context, span oteltrace.Span) {
+	for _, h := range rootSpanHooks {
+		if h.OnEnd != nil {
+			h.OnEnd(ctx, span)
+		}
+	}
+}
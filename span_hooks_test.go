@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// useRootSpanHooks replaces the registered root span hooks until the test
+// finishes.
+func useRootSpanHooks(t *testing.T, hooks ...RootSpanHook) {
+	t.Helper()
+	prev := rootSpanHooks
+	rootSpanHooks = nil
+	t.Cleanup(func() { rootSpanHooks = prev })
+	for _, h := range hooks {
+		RegisterRootSpanHook(h)
+	}
+}
+
+func TestRootSpanHooks(t *testing.T) {
+	setAttr := func(key, value string) func(context.Context, oteltrace.Span) {
+		return func(_ context.Context, span oteltrace.Span) {
+			span.SetAttributes(attribute.String(key, value))
+		}
+	}
+	tests := []struct {
+		name  string
+		hooks []RootSpanHook
+		want  map[attribute.Key]string
+	}{
+		{name: "none", want: map[attribute.Key]string{"app.custom": ""}},
+		{
+			name:  "start hook",
+			hooks: []RootSpanHook{{OnStart: setAttr("app.custom", "start")}},
+			want:  map[attribute.Key]string{"app.custom": "start"},
+		},
+		{
+			name:  "end hook",
+			hooks: []RootSpanHook{{OnEnd: setAttr("app.custom", "end")}},
+			want:  map[attribute.Key]string{"app.custom": "end"},
+		},
+		{
+			name: "registration order",
+			hooks: []RootSpanHook{
+				{OnStart: setAttr("app.first", "a"), OnEnd: setAttr("app.custom", "first")},
+				{OnStart: setAttr("app.second", "b"), OnEnd: setAttr("app.custom", "second")},
+			},
+			want: map[attribute.Key]string{"app.first": "a", "app.second": "b", "app.custom": "second"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := tracetest.NewInMemoryExporter()
+			useTestTracerProvider(t, trace.WithSyncer(exp))
+			useRootSpanHooks(t, tt.hooks...)
+			m := newTestMiddleware(t)
+			m.Handle("/fibonacci", http.NotFoundHandler()).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fibonacci", nil))
+
+			exported := exp.GetSpans().Snapshots()
+			if len(exported) != 1 {
+				t.Fatalf("exported %d spans, want 1", len(exported))
+			}
+			for key, want := range tt.want {
+				if got := spanAttr(exported[0], key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRootSpanHooksSeeRequest(t *testing.T) {
+	useTestTracerProvider(t)
+	var events []string
+	var startSpan, endSpan oteltrace.SpanContext
+	useRootSpanHooks(t, RootSpanHook{
+		OnStart: func(ctx context.Context, span oteltrace.Span) {
+			events = append(events, "start")
+			startSpan = span.SpanContext()
+			if !span.IsRecording() || !oteltrace.SpanFromContext(ctx).SpanContext().Equal(startSpan) {
+				t.Error("OnStart not given the recording root span and its context")
+			}
+		},
+		OnEnd: func(ctx context.Context, span oteltrace.Span) {
+			events = append(events, "end")
+			endSpan = span.SpanContext()
+			if !span.IsRecording() {
+				t.Error("OnEnd given an ended span")
+			}
+		},
+	})
+	m := newTestMiddleware(t)
+	m.Handle("/fibonacci", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		events = append(events, "handler")
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fibonacci", nil))
+
+	if want := []string{"start", "handler", "end"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if !startSpan.IsValid() || !startSpan.Equal(endSpan) {
+		t.Errorf("hooks saw spans %v and %v, want the same root span", startSpan.SpanID(), endSpan.SpanID())
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultSpanNameTemplate names server spans after their route alone.
+const defaultSpanNameTemplate = "{route}"
+
+// spanNameTemplate renders server span names from a template using the
+// {method} and {route} placeholders, e.g. "{method} {route}". The zero
+// value renders the route.
+type spanNameTemplate string
+
+// parseSpanNameTemplate validates t: it may only use the known
+// placeholders and must not be blank.
+func parseSpanNameTemplate(t string) (spanNameTemplate, error) {
+	if strings.TrimSpace(t) == "" {
+		return "", fmt.Errorf("span name template is empty")
+	}
+	for rest := t; ; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("span name template %q: unclosed placeholder", t)
+		}
+		switch name := rest[open+1 : open+end]; name {
+		case "method", "route":
+		default:
+			return "", fmt.Errorf("span name template %q: unknown placeholder {%s}", t, name)
+		}
+		rest = rest[open+end+1:]
+	}
+	return spanNameTemplate(t), nil
+}
+
+// Render returns the span name for a request to route with method.
+func (t spanNameTemplate) Render(method, route string) string {
+	if t == "" {
+		return route
+	}
+	return strings.NewReplacer("{method}", method, "{route}", route).Replace(string(t))
+}
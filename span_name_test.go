@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSpanNameTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+		want     string
+	}{
+		{template: "{route}", want: "/fibonacci"},
+		{template: "{method} {route}", want: "POST /fibonacci"},
+		{template: "fib: {method}", want: "fib: POST"},
+		{template: "static", want: "static"},
+		{template: "", wantErr: true},
+		{template: "  ", wantErr: true},
+		{template: "{verb} {route}", wantErr: true},
+		{template: "{method {route}", wantErr: true},
+		{template: "{route", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := parseSpanNameTemplate(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			rec := useTestTracerProvider(t)
+			m := newTestMiddleware(t)
+			m.spanName = tmpl
+			m.Handle("/fibonacci", http.NotFoundHandler()).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fibonacci", nil))
+			if got := rec.Ended()[0].Name(); got != tt.want {
+				t.Errorf("span name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
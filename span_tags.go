@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// spanTagsProcessor adds a fixed set of deployment tags to every span when it
+// starts. Spans whose name or http.route is listed in skip (probes and other
+// ignored spans) are left untouched.
+type spanTagsProcessor struct {
+	tags []attribute.KeyValue
+	skip map[string]struct{}
+}
+
+var _ trace.SpanProcessor = (*spanTagsProcessor)(nil)
+
+func newSpanTagsProcessor(tags []attribute.KeyValue, skip []string) *spanTagsProcessor {
+	p := &spanTagsProcessor{tags: tags, skip: make(map[string]struct{}, len(skip))}
+	for _, name := range skip {
+		p.skip[name] = struct{}{}
+	}
+	return p
+}
+
+func (p *spanTagsProcessor) OnStart(_ context.Context, s trace.ReadWriteSpan) {
+	if _, ok := p.skip[s.Name()]; ok {
+		return
+	}
+	for _, kv := range s.Attributes() {
+		if kv.Key == semconv.HTTPRouteKey {
+			if _, ok := p.skip[kv.Value.AsString()]; ok {
+				return
+			}
+		}
+	}
+	s.SetAttributes(p.tags...)
+}
+
+func (p *spanTagsProcessor) OnEnd(trace.ReadOnlySpan) {}
+
+func (p *spanTagsProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *spanTagsProcessor) ForceFlush(context.Context) error { return nil }
+
+// parseSpanTags parses "key=value,key=value" into string attributes.
+func parseSpanTags(s string) ([]attribute.KeyValue, error) {
+	var tags []attribute.KeyValue
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, newConfigError(ErrInvalidSpanTag, nil, "invalid span tag %q, want key=value", pair)
+		}
+		tags = append(tags, attribute.String(k, strings.TrimSpace(v)))
+	}
+	return tags, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestParseSpanTags(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []attribute.KeyValue
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "region=eu", want: []attribute.KeyValue{attribute.String("region", "eu")}},
+		{
+			in: " region = eu , cluster=c1,,",
+			want: []attribute.KeyValue{
+				attribute.String("region", "eu"),
+				attribute.String("cluster", "c1"),
+			},
+		},
+		{in: "empty=", want: []attribute.KeyValue{attribute.String("empty", "")}},
+		{in: "region", wantErr: true},
+		{in: "=eu", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSpanTags(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSpanTag) {
+					t.Fatalf("err = %v, want ErrInvalidSpanTag", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpanTagsProcessor(t *testing.T) {
+	tags := []attribute.KeyValue{attribute.String("region", "eu"), attribute.String("cluster", "c1")}
+	tests := []struct {
+		name     string
+		skip     []string
+		span     string
+		attrs    []attribute.KeyValue
+		wantTags bool
+	}{
+		{name: "tagged", skip: probeRoutes, span: "/fibonacci", wantTags: true},
+		{name: "skipped by name", skip: probeRoutes, span: "/healthz"},
+		{
+			name:  "skipped by route",
+			skip:  probeRoutes,
+			span:  "GET /healthz",
+			attrs: []attribute.KeyValue{semconv.HTTPRoute("/healthz")},
+		},
+		{name: "custom skip", skip: []string{"ignored"}, span: "ignored"},
+		{name: "probe tagged with custom skip", skip: []string{"ignored"}, span: "/healthz", wantTags: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.NewSpanRecorder()
+			tp := trace.NewTracerProvider(
+				trace.WithSpanProcessor(newSpanTagsProcessor(tags, tt.skip)),
+				trace.WithSpanProcessor(rec),
+			)
+			defer tp.Shutdown(context.Background())
+
+			_, span := tp.Tracer("test").Start(context.Background(), tt.span, oteltrace.WithAttributes(tt.attrs...))
+			span.End()
+
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			got := make(map[attribute.Key]bool)
+			for _, kv := range ended[0].Attributes() {
+				got[kv.Key] = true
+			}
+			for _, kv := range tags {
+				if got[kv.Key] != tt.wantTags {
+					t.Errorf("has %s = %v, want %v", kv.Key, got[kv.Key], tt.wantTags)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// spanTreeNode is one span of a captured trace with its children nested.
+type spanTreeNode struct {
+	Name       string          `json:"name"`
+	SpanID     string          `json:"span_id"`
+	StartTime  time.Time       `json:"start_time"`
+	Duration   string          `json:"duration"`
+	Attributes interface{}     `json:"attributes,omitempty"`
+	Children   []*spanTreeNode `json:"children,omitempty"`
+	// Truncated is set when children were left out to respect maxDepth.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// treeExporter collects the spans of traces whose local root serves the
+// http.route root and, when that root span ends, keeps the trace as a
+// nested tree. Only the latest tree is kept. A trace contributes at most
+// maxSpans spans and the tree is cut at maxDepth levels, so a large
+// recursive n stays cheap.
+// Traces whose root never arrives, because it was dropped or the process
+// is shutting down, are given up after pendingTraceAge, and at most
+// maxPendingTraces are collected at once.
+type treeExporter struct {
+	root     string
+	maxDepth int
+	maxSpans int
+
+	mu      sync.Mutex
+	pending map[oteltrace.TraceID]*pendingTrace
+	last    *spanTreeNode
+}
+
+// pendingTrace is the spans of a trace collected so far.
+type pendingTrace struct {
+	spans []trace.ReadOnlySpan
+	since time.Time
+}
+
+const (
+	// pendingTraceAge is how long a treeExporter waits for a trace's root.
+	pendingTraceAge = time.Minute
+	// maxPendingTraces bounds the traces a treeExporter collects at once;
+	// the oldest one is given up to make room.
+	maxPendingTraces = 64
+)
+
+var _ trace.SpanExporter = (*treeExporter)(nil)
+
+func newTreeExporter(root string, maxDepth, maxSpans int) *treeExporter {
+	return &treeExporter{
+		root:     root,
+		maxDepth: maxDepth,
+		maxSpans: maxSpans,
+		pending:  make(map[oteltrace.TraceID]*pendingTrace),
+	}
+}
+
+func (e *treeExporter) ExportSpans(_ context.Context, spans []trace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	e.expire(now)
+	for _, s := range spans {
+		id := s.SpanContext().TraceID()
+		if parent := s.Parent(); parent.IsValid() && !parent.IsRemote() {
+			p := e.pending[id]
+			if p == nil {
+				if len(e.pending) >= maxPendingTraces {
+					e.evictOldest()
+				}
+				p = &pendingTrace{since: now}
+				e.pending[id] = p
+			}
+			if len(p.spans) < e.maxSpans {
+				p.spans = append(p.spans, s)
+			}
+			continue
+		}
+		// A local root: children end before it, so the trace is complete.
+		var captured []trace.ReadOnlySpan
+		if p := e.pending[id]; p != nil {
+			captured = p.spans
+			delete(e.pending, id)
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key == semconv.HTTPRouteKey && kv.Value.AsString() == e.root {
+				e.last = e.buildTree(s, captured)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// expire gives up the traces pending since before pendingTraceAge ago.
+// e.mu must be held.
+func (e *treeExporter) expire(now time.Time) {
+	for id, p := range e.pending {
+		if now.Sub(p.since) > pendingTraceAge {
+			delete(e.pending, id)
+		}
+	}
+}
+
+// evictOldest gives up the trace pending the longest. e.mu must be held.
+func (e *treeExporter) evictOldest() {
+	var oldest oteltrace.TraceID
+	var since time.Time
+	for id, p := range e.pending {
+		if since.IsZero() || p.since.Before(since) {
+			oldest, since = id, p.since
+		}
+	}
+	delete(e.pending, oldest)
+}
+
+func (e *treeExporter) Shutdown(context.Context) error { return nil }
+
+// Last returns the most recently captured tree, nil before the first one.
+func (e *treeExporter) Last() *spanTreeNode {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.last
+}
+
+func (e *treeExporter) buildTree(root trace.ReadOnlySpan, spans []trace.ReadOnlySpan) *spanTreeNode {
+	children := make(map[oteltrace.SpanID][]trace.ReadOnlySpan)
+	for _, s := range spans {
+		parent := s.Parent().SpanID()
+		children[parent] = append(children[parent], s)
+	}
+	var build func(s trace.ReadOnlySpan, depth int) *spanTreeNode
+	build = func(s trace.ReadOnlySpan, depth int) *spanTreeNode {
+		node := &spanTreeNode{
+			Name:       s.Name(),
+			SpanID:     s.SpanContext().SpanID().String(),
+			StartTime:  s.StartTime(),
+			Duration:   s.EndTime().Sub(s.StartTime()).String(),
+			Attributes: ndjsonAttributes(s.Attributes(), false),
+		}
+		kids := children[s.SpanContext().SpanID()]
+		if depth >= e.maxDepth {
+			node.Truncated = len(kids) > 0
+			return node
+		}
+		sort.Slice(kids, func(i, j int) bool { return kids[i].StartTime().Before(kids[j].StartTime()) })
+		for _, kid := range kids {
+			node.Children = append(node.Children, build(kid, depth+1))
+		}
+		return node
+	}
+	return build(root, 1)
+}
+
+// lastTreeHandler renders the tree held by a treeExporter as JSON, or 404
+// when nothing has been captured yet.
+type lastTreeHandler struct {
+	trees *treeExporter
+}
+
+func (s *lastTreeHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	tree := s.trees.Last()
+	if tree == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		resp.Write([]byte("no trace captured yet"))
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(tree)
+}
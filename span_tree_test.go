@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// treeShape renders the fib.n values of a span tree as "n(children...)",
+// marking truncated nodes with "…".
+func treeShape(node *spanTreeNode) string {
+	label := node.Name
+	if attrs, ok := node.Attributes.(map[string]interface{}); ok {
+		if n, ok := attrs[string(attrKey("fib.n"))]; ok {
+			b, _ := json.Marshal(n)
+			label = string(b)
+		}
+	}
+	if node.Truncated {
+		return label + "…"
+	}
+	if len(node.Children) == 0 {
+		return label
+	}
+	kids := make([]string, len(node.Children))
+	for i, kid := range node.Children {
+		kids[i] = treeShape(kid)
+	}
+	return label + "(" + strings.Join(kids, " ") + ")"
+}
+
+func TestLastTreeHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth int
+		target   string
+		want     string
+	}{
+		{name: "recursion", maxDepth: 10, target: "/fibonacci?n=4&mode=recursive", want: "/fibonacci(4(3(2(1 0) 1) 2(1 0)))"},
+		{name: "depth capped", maxDepth: 3, target: "/fibonacci?n=4&mode=recursive", want: "/fibonacci(4(3… 2…))"},
+		{name: "iterative", maxDepth: 10, target: "/fibonacci?n=4", want: "/fibonacci(4)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trees := newTreeExporter("/fibonacci", tt.maxDepth, 100)
+			useTestTracerProvider(t, trace.WithSyncer(trees))
+			m := newTestMiddleware(t)
+			handler := m.Handle("/fibonacci", newTestFibonacciHandler())
+			lastTree := &lastTreeHandler{trees: trees}
+
+			resp := httptest.NewRecorder()
+			lastTree.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/lasttree", nil))
+			if resp.Code != http.StatusNotFound {
+				t.Errorf("status before any request = %d, want %d", resp.Code, http.StatusNotFound)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+			// Requests to other routes don't replace the tree.
+			m.Handle("/nested", http.NotFoundHandler()).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nested", nil))
+
+			resp = httptest.NewRecorder()
+			lastTree.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/lasttree", nil))
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+			}
+			var tree spanTreeNode
+			if err := json.Unmarshal(resp.Body.Bytes(), &tree); err != nil {
+				t.Fatal(err)
+			}
+			if got := treeShape(&tree); got != tt.want {
+				t.Errorf("tree = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
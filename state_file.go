@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// stateFile persists the runtime tuned settings of a configHandler across
+// restarts, as the JSON form of runtimeConfig.
+type stateFile struct {
+	path   string
+	config *configHandler
+}
+
+func newStateFile(path string, config *configHandler) *stateFile {
+	return &stateFile{path: path, config: config}
+}
+
+// Load applies the saved settings. A missing file is not an error; an
+// unreadable or invalid one is ignored with a warning, keeping the settings
+// from the environment.
+func (s *stateFile) Load() {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	var cfg runtimeConfig
+	if err == nil {
+		err = json.Unmarshal(b, &cfg)
+	}
+	if err == nil {
+		err = s.config.apply(cfg)
+	}
+	if err != nil {
+		log.Printf("ignoring state file %s: %v", s.path, err)
+		return
+	}
+	log.Printf("restored runtime settings from %s", s.path)
+}
+
+// Save writes the current settings, replacing the file atomically so a
+// crash mid-write can't corrupt it.
+func (s *stateFile) Save() {
+	b, err := json.MarshalIndent(s.config.effective(), "", "  ")
+	if err != nil {
+		log.Printf("save state file %s: %v", s.path, err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err == nil {
+		_, err = tmp.Write(b)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), s.path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		log.Printf("save state file %s: %v", s.path, err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startRuntimeConfig builds the runtime settings as main does on startup,
+// from ratio 1 with /fibonacci and /nested traced, restoring path.
+func startRuntimeConfig(t *testing.T, path string) (*configHandler, *stateFile) {
+	t.Helper()
+	sampler, err := newRatioSampler(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := newRouteToggle(nil)
+	routes.Register("/fibonacci")
+	routes.Register("/nested")
+	cfg := &configHandler{sampler: sampler, routes: routes}
+	state := newStateFile(path, cfg)
+	state.Load()
+	cfg.changed = state.Save
+	return cfg, state
+}
+
+func TestStateFileSurvivesRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *configHandler, state *stateFile) http.Handler
+		target string
+		// wantRatio and wantNested are the settings after the restart.
+		wantRatio  float64
+		wantNested bool
+	}{
+		{
+			name: "sampling handler",
+			change: func(cfg *configHandler, state *stateFile) http.Handler {
+				return &samplingHandler{sampler: cfg.sampler, changed: state.Save}
+			},
+			target:     "/debug/sampling?ratio=0.25",
+			wantRatio:  0.25,
+			wantNested: true,
+		},
+		{
+			name: "route toggle handler",
+			change: func(cfg *configHandler, state *stateFile) http.Handler {
+				return &routeToggleHandler{toggle: cfg.routes, changed: state.Save}
+			},
+			target:     "/debug/routes?route=/nested&enabled=false",
+			wantRatio:  1,
+			wantNested: false,
+		},
+		{
+			name: "rejected change not saved",
+			change: func(cfg *configHandler, state *stateFile) http.Handler {
+				return &samplingHandler{sampler: cfg.sampler, changed: state.Save}
+			},
+			target:     "/debug/sampling?ratio=7",
+			wantRatio:  1,
+			wantNested: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			path := filepath.Join(t.TempDir(), "state.json")
+			cfg, state := startRuntimeConfig(t, path)
+			tt.change(cfg, state).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+			restarted, _ := startRuntimeConfig(t, path)
+			if got := restarted.sampler.Ratio(); got != tt.wantRatio {
+				t.Errorf("ratio after restart = %g, want %g", got, tt.wantRatio)
+			}
+			if got := restarted.routes.Enabled("/nested"); got != tt.wantNested {
+				t.Errorf("/nested traced after restart = %v, want %v", got, tt.wantNested)
+			}
+		})
+	}
+}
+
+func TestStateFileConfigHandlerRestart(t *testing.T) {
+	captureLog(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	cfg, _ := startRuntimeConfig(t, path)
+	body := strings.NewReader(`{"sample_ratio": 0.1, "traced_routes": {"/fibonacci": false}}`)
+	resp := httptest.NewRecorder()
+	cfg.ServeHTTP(resp, httptest.NewRequest(http.MethodPut, "/debug/config", body))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+	}
+
+	restarted, _ := startRuntimeConfig(t, path)
+	if got := restarted.sampler.Ratio(); got != 0.1 {
+		t.Errorf("ratio after restart = %g, want 0.1", got)
+	}
+	if restarted.routes.Enabled("/fibonacci") {
+		t.Error("/fibonacci traced after restart, want off")
+	}
+}
+
+func TestStateFileLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		// content is written to the state file; nil leaves it missing.
+		content []byte
+		// wantWarning is logged; empty means nothing is.
+		wantWarning string
+	}{
+		{name: "missing"},
+		{name: "corrupt", content: []byte(`{"sample_ratio": 0.`), wantWarning: "ignoring state file"},
+		{name: "not json", content: []byte("\x00\x01garbage"), wantWarning: "ignoring state file"},
+		{name: "ratio out of range", content: []byte(`{"sample_ratio": 3}`), wantWarning: "out of range"},
+		{name: "unknown route", content: []byte(`{"traced_routes": {"/gone": true}}`), wantWarning: "unknown route"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged := captureLog(t)
+			path := filepath.Join(t.TempDir(), "state.json")
+			if tt.content != nil {
+				if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg, _ := startRuntimeConfig(t, path)
+
+			// Startup carries on with the settings from the environment.
+			if got := cfg.sampler.Ratio(); got != 1 {
+				t.Errorf("ratio = %g, want 1", got)
+			}
+			if !cfg.routes.Enabled("/fibonacci") || !cfg.routes.Enabled("/nested") {
+				t.Error("routes changed by an invalid state file")
+			}
+			if tt.wantWarning == "" && logged.Len() != 0 {
+				t.Errorf("unexpected log: %s", logged)
+			}
+			if tt.wantWarning != "" && !strings.Contains(logged.String(), tt.wantWarning) {
+				t.Errorf("log = %q, want a warning containing %q", logged, tt.wantWarning)
+			}
+		})
+	}
+}
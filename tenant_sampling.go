@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type tenantKey struct{}
+
+// withTenant records in ctx the tenant a request was made for.
+func withTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant recorded with withTenant, or "".
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenantSampler samples the root spans of configured tenants at their own
+// ratio. Spans of other tenants, without a tenant, or with a parent are
+// left to next, which applies the global ratio.
+type tenantSampler struct {
+	next    trace.Sampler
+	tenants map[string]trace.Sampler
+}
+
+var _ trace.Sampler = tenantSampler{}
+
+// newTenantSampler parses entries of the form tenant=ratio.
+func newTenantSampler(next trace.Sampler, entries []string) (tenantSampler, error) {
+	s := tenantSampler{next: next, tenants: make(map[string]trace.Sampler, len(entries))}
+	for _, entry := range entries {
+		tenant, value, ok := strings.Cut(entry, "=")
+		if !ok || tenant == "" {
+			return tenantSampler{}, newConfigError(ErrInvalidSampleRatio, nil, "invalid tenant sample ratio %q, want tenant=ratio", entry)
+		}
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(ratio >= 0 && ratio <= 1) {
+			return tenantSampler{}, newConfigError(ErrInvalidSampleRatio, err, "tenant %s: sample ratio %q out of range [0, 1]", tenant, value)
+		}
+		s.tenants[tenant] = trace.TraceIDRatioBased(ratio)
+	}
+	return s, nil
+}
+
+func (s tenantSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if p.ParentContext != nil && !oteltrace.SpanContextFromContext(p.ParentContext).IsValid() {
+		if tenant := tenantFromContext(p.ParentContext); tenant != "" {
+			if sampler, ok := s.tenants[tenant]; ok {
+				return sampler.ShouldSample(p)
+			}
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s tenantSampler) Description() string {
+	return "TenantSampler{" + s.next.Description() + "}"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTenantSampler(t *testing.T) {
+	const (
+		requests = 2000
+		ratio    = 0.5
+	)
+	tenants := []string{"acme=0.1", "globex=0.9"}
+	tests := []struct {
+		name   string
+		tenant string
+		header map[string]string
+		// want is the expected fraction of sampled requests.
+		want float64
+	}{
+		{name: "low ratio tenant", tenant: "acme", want: 0.1},
+		{name: "high ratio tenant", tenant: "globex", want: 0.9},
+		{name: "unknown tenant uses default", tenant: "initech", want: ratio},
+		{name: "no tenant uses default", want: ratio},
+		{
+			name:   "sampled parent wins over tenant",
+			tenant: "acme",
+			header: map[string]string{"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01"},
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := newTenantSampler(trace.ParentBased(trace.TraceIDRatioBased(ratio)), tenants)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := useTestTracerProvider(t, trace.WithSampler(sampler))
+			prop, err := newPropagator([]string{"tracecontext"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTestPropagator(t, prop)
+			m := newTestMiddleware(t)
+			handler := m.Handle("/fibonacci", http.NotFoundHandler())
+			for i := 0; i < requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/fibonacci", nil)
+				if tt.tenant != "" {
+					req.Header.Set("X-Tenant-ID", tt.tenant)
+				}
+				for k, v := range tt.header {
+					req.Header.Set(k, v)
+				}
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			got := float64(len(rec.Ended())) / requests
+			// Five standard deviations of the binomial distribution.
+			tolerance := 5 * math.Sqrt(tt.want*(1-tt.want)/requests)
+			if math.Abs(got-tt.want) > tolerance {
+				t.Errorf("sampled fraction = %.3f, want %.3f±%.3f", got, tt.want, tolerance)
+			}
+			for _, s := range rec.Ended() {
+				if got := spanAttr(s, attrKey("tenant.id")); got != tt.tenant {
+					t.Fatalf("tenant.id = %q, want %q", got, tt.tenant)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTenantSamplerInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr bool
+	}{
+		{name: "valid", entries: []string{"acme=0", "globex=1", "initech=0.25"}},
+		{name: "missing ratio", entries: []string{"acme"}, wantErr: true},
+		{name: "missing tenant", entries: []string{"=0.5"}, wantErr: true},
+		{name: "not a number", entries: []string{"acme=half"}, wantErr: true},
+		{name: "out of range", entries: []string{"acme=1.5"}, wantErr: true},
+		{name: "negative", entries: []string{"acme=-0.1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTenantSampler(trace.AlwaysSample(), tt.entries)
+			if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrInvalidSampleRatio) {
+				t.Errorf("newTenantSampler(%q) = %v, want ErrInvalidSampleRatio %v", tt.entries, err, tt.wantErr)
+			}
+		})
+	}
+}
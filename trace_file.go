@@ -0,0 +1,166 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// traceFile is an io.Writer over the trace output file that can be reopened
+// in place, so external log rotation can move the file away without the
+// exporter losing track of it.
+type traceFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// createTraceFile creates (or truncates) the trace file at path.
+func createTraceFile(path string) (*traceFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &traceFile{path: path, f: f}, nil
+}
+
+func (t *traceFile) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.f.Write(p)
+}
+
+// Reopen opens the path again in append mode and swaps it in for the
+// current descriptor, which is closed afterwards. Writes are serialized with
+// the swap, so none are lost or interleaved.
+func (t *traceFile) Reopen() error {
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	old := t.f
+	t.f = f
+	t.mu.Unlock()
+	return old.Close()
+}
+
+func (t *traceFile) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.f.Close()
+}
+
+// reopenOnSIGHUP reopens t every time the process receives SIGHUP, which is
+// how logrotate asks processes to let go of a rotated file.
+func reopenOnSIGHUP(t *traceFile) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := t.Reopen(); err != nil {
+				log.Printf("reopen %s: %v", t.path, err)
+				continue
+			}
+			log.Printf("reopened %s", t.path)
+		}
+	}()
+}
+
+// compressTraceFile gzips the closed trace file at path into path+".gz",
+// removing the original when remove is set. The archive is written under a
+// temporary name and renamed at the end, so an interrupted run never leaves
+// a truncated .gz behind. ctx is checked between chunks.
+func compressTraceFile(ctx context.Context, path string, remove bool) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, ctxReader{ctx: ctx, r: src})
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if remove {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// ctxReader fails reads once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// removeOldTraceFiles deletes the rotated or compressed copies of the trace
+// file at path, such as traces.txt.1, traces.txt.2.gz or
+// traces.txt-20240101, that were last modified more than maxAge ago. Only
+// files in path's directory whose name is path's base name followed by "."
+// or "-" are considered; path itself is never removed. It returns the
+// removed files and the first error, keeping going past files it fails to
+// remove.
+func removeOldTraceFiles(path string, maxAge time.Duration) ([]string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	var removed []string
+	var firstErr error
+	for _, e := range entries {
+		name := e.Name()
+		rest := strings.TrimPrefix(name, base)
+		if e.IsDir() || rest == name || rest == "" || (rest[0] != '.' && rest[0] != '-') {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		old := filepath.Join(dir, name)
+		if err := os.Remove(old); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		removed = append(removed, old)
+	}
+	return removed, firstErr
+}
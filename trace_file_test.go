@@ -0,0 +1,242 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestTraceFileReopen(t *testing.T) {
+	tests := []struct {
+		name    string
+		rotate  bool
+		wantOld string
+		wantNew string
+	}{
+		{name: "rotated away", rotate: true, wantOld: "before\n", wantNew: "after\n"},
+		{name: "still in place", rotate: false, wantNew: "before\nafter\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.txt")
+			f, err := createTraceFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			f.Write([]byte("before\n"))
+			if tt.rotate {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := f.Reopen(); err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte("after\n"))
+
+			if got := readFile(t, path); got != tt.wantNew {
+				t.Errorf("%s = %q, want %q", path, got, tt.wantNew)
+			}
+			if tt.rotate {
+				if got := readFile(t, path+".1"); got != tt.wantOld {
+					t.Errorf("rotated file = %q, want %q", got, tt.wantOld)
+				}
+			}
+		})
+	}
+}
+
+func TestReopenOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.txt")
+	f, err := createTraceFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reopenOnSIGHUP(f)
+
+	f.Write([]byte("a"))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("cannot send SIGHUP: %v", err)
+	}
+
+	// Keep writing until the writes land in the reopened file; none of
+	// them may get lost on the way.
+	writes := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.Write([]byte("b"))
+		writes++
+		if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("writes never reached the reopened file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	old, reopened := readFile(t, path+".1"), readFile(t, path)
+	if !strings.HasPrefix(old, "a") || strings.Trim(old[1:]+reopened, "b") != "" {
+		t.Errorf("rotated file %q and reopened file %q hold more than the writes", old, reopened)
+	}
+	if got := len(old) - 1 + len(reopened); got != writes {
+		t.Errorf("found %d writes after SIGHUP, want %d", got, writes)
+	}
+}
+
+func TestCompressTraceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		remove   bool
+		canceled bool
+	}{
+		{name: "keep original"},
+		{name: "remove original", remove: true},
+		{name: "canceled", canceled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.txt")
+			f, err := createTraceFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp, err := newExporter(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Shut down in main's order: exporter first, then the file.
+			tp := trace.NewTracerProvider(trace.WithBatcher(exp))
+			for i := 0; i < 3; i++ {
+				_, span := tp.Tracer("test").Start(context.Background(), "fibonacci")
+				span.End()
+			}
+			if err := tp.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want := readFile(t, path)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.canceled {
+				cancel()
+			}
+			defer cancel()
+			err = compressTraceFile(ctx, path, tt.remove)
+			if tt.canceled {
+				if err == nil {
+					t.Error("compressed with a canceled context")
+				}
+				for _, leftover := range []string{path + ".gz", path + ".gz.tmp"} {
+					if _, err := os.Stat(leftover); err == nil {
+						t.Errorf("%s left behind", leftover)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gz, err := os.Open(path + ".gz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer gz.Close()
+			zr, err := gzip.NewReader(gz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want || strings.Count(want, `"Name": "fibonacci"`) != 3 {
+				t.Errorf("decompressed %d bytes, want the %d bytes with 3 spans", len(got), len(want))
+			}
+			if _, err := os.Stat(path); os.IsNotExist(err) != tt.remove {
+				t.Errorf("original exists = %v, want removed %v", err == nil, tt.remove)
+			}
+		})
+	}
+}
+
+func TestRemoveOldTraceFiles(t *testing.T) {
+	const maxAge = time.Hour
+	tests := []struct {
+		name string
+		// age is how long ago the file was last modified.
+		age         time.Duration
+		wantRemoved bool
+	}{
+		{name: "traces.txt", age: 2 * maxAge},
+		{name: "traces.txt.1", age: 2 * maxAge, wantRemoved: true},
+		{name: "traces.txt.2.gz", age: 3 * maxAge, wantRemoved: true},
+		{name: "traces.txt-20240101", age: 2 * maxAge, wantRemoved: true},
+		{name: "traces.txt.3", age: maxAge / 2},
+		{name: "traces.txt.gz", age: time.Minute},
+		{name: "traces.txtx", age: 2 * maxAge},
+		{name: "other.txt.1", age: 2 * maxAge},
+		{name: "app.log", age: 2 * maxAge},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte("span\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-tt.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := removeOldTraceFiles(filepath.Join(dir, "traces.txt"), maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotRemoved := map[string]bool{}
+	for _, path := range removed {
+		gotRemoved[filepath.Base(path)] = true
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, statErr := os.Stat(filepath.Join(dir, tt.name))
+			if exists := statErr == nil; exists == tt.wantRemoved {
+				t.Errorf("file exists = %v, want removed %v", exists, tt.wantRemoved)
+			}
+			if gotRemoved[tt.name] != tt.wantRemoved {
+				t.Errorf("reported removed = %v, want %v", gotRemoved[tt.name], tt.wantRemoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// traceIDHeaderPropagator bridges legacy upstreams that only send a bare
+// trace ID in a custom header such as X-Trace-Id. It must come after the
+// standard propagators: when none of them found a parent, it records the
+// header's trace ID in the context for legacyTraceIDGenerator, which gives
+// it to the local root span so the request still lands in the upstream's
+// trace. There is no parent span to reference and no upstream sampling
+// decision, so the span is a root sampled like any other. On inject it
+// writes the trace ID back out for legacy downstreams.
+type traceIDHeaderPropagator struct {
+	header string
+}
+
+var _ propagation.TextMapPropagator = traceIDHeaderPropagator{}
+
+func (p traceIDHeaderPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+		carrier.Set(p.header, sc.TraceID().String())
+	}
+}
+
+func (p traceIDHeaderPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if oteltrace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	traceID, ok := parseLegacyTraceID(carrier.Get(p.header))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, legacyTraceIDKey{}, traceID)
+}
+
+func (p traceIDHeaderPropagator) Fields() []string {
+	return []string{p.header}
+}
+
+// parseLegacyTraceID accepts a 32 or 16 hex digit trace ID, the latter left
+// padded with zeros as Zipkin and Jaeger do, and rejects the all zero ID.
+func parseLegacyTraceID(v string) (oteltrace.TraceID, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if len(v) == 16 {
+		v = strings.Repeat("0", 16) + v
+	}
+	var id oteltrace.TraceID
+	if len(v) != 32 {
+		return id, false
+	}
+	if _, err := hex.Decode(id[:], []byte(v)); err != nil {
+		return id, false
+	}
+	return id, id.IsValid()
+}
+
+type legacyTraceIDKey struct{}
+
+// legacyTraceIDGenerator generates random IDs, except that root spans
+// started from a context carrying a trace ID extracted by
+// traceIDHeaderPropagator use that trace ID.
+type legacyTraceIDGenerator struct{}
+
+var _ trace.IDGenerator = legacyTraceIDGenerator{}
+
+func (g legacyTraceIDGenerator) NewIDs(ctx context.Context) (oteltrace.TraceID, oteltrace.SpanID) {
+	traceID, ok := ctx.Value(legacyTraceIDKey{}).(oteltrace.TraceID)
+	if !ok {
+		rand.Read(traceID[:])
+	}
+	return traceID, g.NewSpanID(ctx, traceID)
+}
+
+func (g legacyTraceIDGenerator) NewSpanID(context.Context, oteltrace.TraceID) oteltrace.SpanID {
+	var spanID oteltrace.SpanID
+	rand.Read(spanID[:])
+	return spanID
+}
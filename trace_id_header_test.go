@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestParseLegacyTraceID(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: testTraceID, want: testTraceID, wantOK: true},
+		{value: "4BF92F3577B34DA6A3CE929D0E0E4736", want: testTraceID, wantOK: true},
+		{value: " " + testTraceID + " ", want: testTraceID, wantOK: true},
+		{value: "a3ce929d0e0e4736", want: "0000000000000000a3ce929d0e0e4736", wantOK: true},
+		{value: ""},
+		{value: "00000000000000000000000000000000"},
+		{value: "4bf92f3577b34da6a3ce929d0e0e473"},
+		{value: "4bf92f3577b34da6a3ce929d0e0e4736ff"},
+		{value: "zbf92f3577b34da6a3ce929d0e0e4736"},
+		{value: "4bf92f35-77b3-4da6-a3ce-929d0e0e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseLegacyTraceID(tt.value)
+			if ok != tt.wantOK {
+				t.Fatalf("parseLegacyTraceID(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+			if ok && got.String() != tt.want {
+				t.Errorf("parseLegacyTraceID(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceIDHeaderPropagator(t *testing.T) {
+	const (
+		legacyID      = "0af7651916cd43dd8448eb211c80319c"
+		traceparentID = testTraceID
+	)
+	tests := []struct {
+		name   string
+		header map[string]string
+		// wantTraceID is the trace ID of the local span, "" for a fresh
+		// random one.
+		wantTraceID string
+		wantRemote  bool
+	}{
+		{
+			name:        "custom header without traceparent",
+			header:      map[string]string{"X-Trace-Id": legacyID},
+			wantTraceID: legacyID,
+		},
+		{
+			name:        "short custom header",
+			header:      map[string]string{"X-Trace-Id": "8448eb211c80319c"},
+			wantTraceID: "00000000000000008448eb211c80319c",
+		},
+		{
+			name: "traceparent wins",
+			header: map[string]string{
+				"traceparent": "00-" + traceparentID + "-" + testSpanID + "-01",
+				"X-Trace-Id":  legacyID,
+			},
+			wantTraceID: traceparentID,
+			wantRemote:  true,
+		},
+		{name: "invalid custom header", header: map[string]string{"X-Trace-Id": "not-a-trace-id"}},
+		{name: "no headers", header: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := useTestTracerProvider(t, trace.WithIDGenerator(legacyTraceIDGenerator{}))
+			prop := propagation.NewCompositeTextMapPropagator(
+				propagation.TraceContext{},
+				traceIDHeaderPropagator{header: "X-Trace-Id"},
+			)
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(header))
+			_, span := tracer("test").Start(ctx, "/fibonacci")
+			span.End()
+
+			ended := rec.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			s := ended[0]
+			got := s.SpanContext().TraceID().String()
+			if tt.wantTraceID != "" && got != tt.wantTraceID {
+				t.Errorf("trace ID = %s, want %s", got, tt.wantTraceID)
+			}
+			if tt.wantTraceID == "" && (got == legacyID || !s.SpanContext().TraceID().IsValid()) {
+				t.Errorf("trace ID = %s, want a fresh random one", got)
+			}
+			if s.Parent().IsRemote() != tt.wantRemote {
+				t.Errorf("remote parent = %v, want %v", s.Parent().IsRemote(), tt.wantRemote)
+			}
+			if !tt.wantRemote && s.Parent().IsValid() {
+				t.Errorf("parent = %s, want a root span", s.Parent().SpanID())
+			}
+		})
+	}
+}
+
+func TestTraceIDHeaderPropagatorInject(t *testing.T) {
+	tests := []struct {
+		name string
+		sc   oteltrace.SpanContext
+		want string
+	}{
+		{name: "span in context", sc: testSpanContext(1, 1), want: "01000000000000000000000000000000"},
+		{name: "no span", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := oteltrace.ContextWithSpanContext(context.Background(), tt.sc)
+			header := http.Header{}
+			traceIDHeaderPropagator{header: "X-Trace-Id"}.Inject(ctx, propagation.HeaderCarrier(header))
+			if got := header.Get("X-Trace-Id"); got != tt.want {
+				t.Errorf("X-Trace-Id = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationVersion is reported as the scope version of every tracer
+// created by this service.
+const instrumentationVersion = "0.1.0"
+
+// tracer returns the named tracer with this service's instrumentation
+// version attached.
+func tracer(name string) oteltrace.Tracer {
+	return otel.Tracer(name, oteltrace.WithInstrumentationVersion(instrumentationVersion))
+}
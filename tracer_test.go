@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracerScopeInNDJSON(t *testing.T) {
+	tests := []string{"fibonacci", "nested", "http"}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			useTestTracerProvider(t, trace.WithSyncer(newNDJSONExporter(&buf)))
+
+			_, span := tracer(name).Start(context.Background(), "span")
+			span.End()
+
+			var got ndjsonSpan
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("%v: %s", err, buf.Bytes())
+			}
+			want := ndjsonScope{Name: name, Version: instrumentationVersion}
+			if got.Scope != want {
+				t.Errorf("scope = %+v, want %+v", got.Scope, want)
+			}
+		})
+	}
+}
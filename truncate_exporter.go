@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// truncatingExporter shortens string attributes longer than limit
+// characters to limit characters plus an ellipsis before handing spans to
+// next, and records the original length in a companion
+// "<key>.original_length" attribute. String slices have each element
+// shortened and the original lengths recorded as a list. It works at export
+// time, so attributes set at any point of a span's life are covered, as are
+// event attributes.
+type truncatingExporter struct {
+	next  trace.SpanExporter
+	limit int
+}
+
+var _ trace.SpanExporter = (*truncatingExporter)(nil)
+
+func newTruncatingExporter(next trace.SpanExporter, limit int) *truncatingExporter {
+	return &truncatingExporter{next: next, limit: limit}
+}
+
+func (e *truncatingExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	out := spans
+	for i, s := range spans {
+		t, ok := e.truncate(s)
+		if !ok {
+			continue
+		}
+		if &out[0] == &spans[0] {
+			// The batch processor owns spans, work on a copy.
+			out = append([]trace.ReadOnlySpan(nil), spans...)
+		}
+		out[i] = t
+	}
+	return e.next.ExportSpans(ctx, out)
+}
+
+func (e *truncatingExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
+
+// truncate returns s with its attributes truncated, and false when none
+// needed it.
+func (e *truncatingExporter) truncate(s trace.ReadOnlySpan) (trace.ReadOnlySpan, bool) {
+	attrs, changed := truncateAttributes(s.Attributes(), e.limit)
+	events := s.Events()
+	var eventsChanged bool
+	for i, ev := range events {
+		evAttrs, ok := truncateAttributes(ev.Attributes, e.limit)
+		if !ok {
+			continue
+		}
+		if !eventsChanged {
+			events = append([]trace.Event(nil), events...)
+			eventsChanged = true
+		}
+		events[i].Attributes = evAttrs
+	}
+	if !changed && !eventsChanged {
+		return s, false
+	}
+	return truncatedSpan{ReadOnlySpan: s, attrs: attrs, events: events}, true
+}
+
+// truncatedSpan is a span with replaced attributes and events.
+type truncatedSpan struct {
+	trace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []trace.Event
+}
+
+func (s truncatedSpan) Attributes() []attribute.KeyValue { return s.attrs }
+
+func (s truncatedSpan) Events() []trace.Event { return s.events }
+
+// truncateAttributes returns attrs with overlong strings truncated, and
+// false, with attrs unchanged, when none were.
+func truncateAttributes(attrs []attribute.KeyValue, limit int) ([]attribute.KeyValue, bool) {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		var replaced []attribute.KeyValue
+		switch kv.Value.Type() {
+		case attribute.STRING:
+			if v, n, ok := truncateString(kv.Value.AsString(), limit); ok {
+				replaced = []attribute.KeyValue{
+					kv.Key.String(v),
+					attribute.Int(string(kv.Key)+".original_length", n),
+				}
+			}
+		case attribute.STRINGSLICE:
+			values := kv.Value.AsStringSlice()
+			lengths := make([]int, len(values))
+			var truncated bool
+			for j, v := range values {
+				var ok bool
+				values[j], lengths[j], ok = truncateString(v, limit)
+				truncated = truncated || ok
+			}
+			if truncated {
+				replaced = []attribute.KeyValue{
+					kv.Key.StringSlice(values),
+					attribute.IntSlice(string(kv.Key)+".original_length", lengths),
+				}
+			}
+		}
+		if replaced == nil {
+			if out != nil {
+				out = append(out, kv)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]attribute.KeyValue, 0, len(attrs)+1), attrs[:i]...)
+		}
+		out = append(out, replaced...)
+	}
+	if out == nil {
+		return attrs, false
+	}
+	return out, true
+}
+
+// truncateString shortens v to limit characters plus an ellipsis. It
+// returns v's length in characters and whether it was shortened.
+func truncateString(v string, limit int) (string, int, bool) {
+	n := utf8.RuneCountInString(v)
+	if n <= limit {
+		return v, n, false
+	}
+	return string([]rune(v)[:limit]) + "…", n, true
+}
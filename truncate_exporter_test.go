@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestTruncatingExporter(t *testing.T) {
+	tests := []struct {
+		name string
+		// set is applied to the span after it starts, like attributes
+		// sourced from the request.
+		set  attribute.KeyValue
+		want []attribute.KeyValue
+	}{
+		{
+			name: "short string kept",
+			set:  attribute.String("user_agent.original", "curl/8.0"),
+			want: []attribute.KeyValue{attribute.String("user_agent.original", "curl/8.0")},
+		},
+		{
+			name: "long string",
+			set:  attribute.String("user_agent.original", strings.Repeat("a", 20)),
+			want: []attribute.KeyValue{
+				attribute.String("user_agent.original", "aaaaaaaa…"),
+				attribute.Int("user_agent.original.original_length", 20),
+			},
+		},
+		{
+			name: "counts characters not bytes",
+			set:  attribute.String("name", strings.Repeat("é", 10)),
+			want: []attribute.KeyValue{
+				attribute.String("name", "éééééééé…"),
+				attribute.Int("name.original_length", 10),
+			},
+		},
+		{
+			name: "string slice",
+			set:  attribute.StringSlice("hops", []string{"short", strings.Repeat("b", 12)}),
+			want: []attribute.KeyValue{
+				attribute.StringSlice("hops", []string{"short", "bbbbbbbb…"}),
+				attribute.IntSlice("hops.original_length", []int{5, 12}),
+			},
+		},
+		{
+			name: "non-string kept",
+			set:  attribute.Int64("fib.n", 123456789012),
+			want: []attribute.KeyValue{attribute.Int64("fib.n", 123456789012)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubExporter{}
+			useTestTracerProvider(t, trace.WithSyncer(newTruncatingExporter(stub, 8)))
+			_, span := tracer("test").Start(context.Background(), "span")
+			span.SetAttributes(tt.set)
+			span.AddEvent("event", oteltrace.WithAttributes(tt.set))
+			span.End()
+
+			spans := stub.spans()
+			if len(spans) != 1 {
+				t.Fatalf("exported %d spans, want 1", len(spans))
+			}
+			if got := spans[0].Attributes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attributes = %v, want %v", got, tt.want)
+			}
+			if got := spans[0].Events()[0].Attributes; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("event attributes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
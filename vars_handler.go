@@ -0,0 +1,51 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// varsSource is what the variables published by publishVars read.
+type varsSource struct {
+	// spans is nil when span counting is disabled.
+	spans    *countingExporter
+	requests *requestMetrics
+}
+
+var (
+	publishedVars   atomic.Pointer[varsSource]
+	publishVarsOnce sync.Once
+)
+
+// publishVars publishes the internal counters as expvar variables and
+// returns expvar's handler, which serves them next to the standard cmdline
+// and memstats variables for tooling that scrapes expvar. The variables
+// read the same atomics as /debug/spans/count and the request metrics, so
+// nothing is counted twice; the span ones are null while span counting is
+// disabled. A variable can be published only once per process, so later
+// calls repoint the published variables at spans and requests. expvar also
+// registers /debug/vars on http.DefaultServeMux, which is never served, so
+// the variables are only reachable where the handler is mounted.
+func publishVars(spans *countingExporter, requests *requestMetrics) http.Handler {
+	publishedVars.Store(&varsSource{spans: spans, requests: requests})
+	publishVarsOnce.Do(func() {
+		expvar.Publish("http_requests_handled", expvar.Func(func() interface{} {
+			return publishedVars.Load().requests.Handled()
+		}))
+		spanVar := func(count func(spanCounts) uint64) expvar.Func {
+			return func() interface{} {
+				spans := publishedVars.Load().spans
+				if spans == nil {
+					return nil
+				}
+				return count(spans.Counts(false))
+			}
+		}
+		expvar.Publish("spans_exported", spanVar(func(c spanCounts) uint64 { return c.Exported }))
+		expvar.Publish("spans_dropped", spanVar(func(c spanCounts) uint64 { return c.Dropped }))
+		expvar.Publish("span_export_errors", spanVar(func(c spanCounts) uint64 { return c.ExportErrors }))
+	})
+	return expvar.Handler()
+}
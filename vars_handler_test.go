@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestVarsHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		// counting is whether span counting is enabled.
+		counting bool
+		// batches are exported in order; a negative size fails the export.
+		batches  []int
+		requests int
+		want     map[string]float64
+		// disabled are published as null.
+		disabled []string
+	}{
+		{
+			name:     "requests only",
+			requests: 3,
+			want:     map[string]float64{"http_requests_handled": 3},
+			disabled: []string{"spans_exported", "spans_dropped", "span_export_errors"},
+		},
+		{
+			name:     "span counters",
+			counting: true,
+			batches:  []int{4, -2, 1},
+			requests: 2,
+			want: map[string]float64{
+				"http_requests_handled": 2,
+				"spans_exported":        5,
+				"spans_dropped":         2,
+				"span_export_errors":    1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, err := newRequestMetrics(metric.NewNoopMeterProvider().Meter("test"), newRouteMux())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.requests; i++ {
+				requests.Observe(context.Background(), "/fibonacci", http.MethodGet, http.StatusOK, time.Millisecond)
+			}
+			var spans *countingExporter
+			if tt.counting {
+				next := &stubExporter{}
+				spans = newCountingExporter(next)
+				for _, n := range tt.batches {
+					next.setErr(nil)
+					if n < 0 {
+						n = -n
+						next.setErr(errors.New("export failed"))
+					}
+					spans.ExportSpans(context.Background(), testSpans(n))
+				}
+			}
+			h := publishVars(spans, requests)
+
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+			var vars map[string]interface{}
+			if err := json.Unmarshal(resp.Body.Bytes(), &vars); err != nil {
+				t.Fatalf("%v: %s", err, resp.Body)
+			}
+			// The standard expvar variables are there for tooling
+			// expecting them.
+			for _, key := range []string{"cmdline", "memstats"} {
+				if _, ok := vars[key]; !ok {
+					t.Errorf("no %s in %s", key, resp.Body)
+				}
+			}
+			for key, want := range tt.want {
+				if got, ok := vars[key].(float64); !ok || got != want {
+					t.Errorf("%s = %v, want %v", key, vars[key], want)
+				}
+			}
+			for _, key := range tt.disabled {
+				if v, ok := vars[key]; !ok || v != nil {
+					t.Errorf("%s = %v, want null", key, v)
+				}
+			}
+		})
+	}
+}
+
+func TestVarsHandlerMatchesSpanCount(t *testing.T) {
+	requests, err := newRequestMetrics(metric.NewNoopMeterProvider().Meter("test"), newRouteMux())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := newCountingExporter(&stubExporter{})
+	counter.ExportSpans(context.Background(), testSpans(3))
+	counter.AddDropped(2)
+
+	// Reading /debug/vars must not reset the counts /debug/spans/count
+	// reports, as both read the same counters.
+	resp := httptest.NewRecorder()
+	publishVars(counter, requests).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if got, want := counter.Counts(false), (spanCounts{Exported: 3, Dropped: 2}); got != want {
+		t.Errorf("counts after /debug/vars = %+v, want %+v", got, want)
+	}
+}